@@ -0,0 +1,322 @@
+package ttlcache
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SetMany sets all of the given items to the cache with the same expiration
+// duration, replacing any existing items, while taking the cache's lock only
+// once. The duration is interpreted as in Set.
+func (c *cache[K, V]) SetMany(items map[K]V, d time.Duration) {
+	c.mu.Lock()
+	for k, x := range items {
+		c.set(k, x, d)
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+}
+
+// Entry is a value to store with SetManyWithTTL, together with when it
+// expires.
+type Entry[V any] struct {
+	Value V
+
+	// Duration is how long the value is stored for, interpreted as in Set.
+	// It is ignored if Deadline is set.
+	Duration time.Duration
+
+	// Deadline, if it isn't zero, is when the value expires, as with
+	// SetWithDeadline.
+	Deadline time.Time
+}
+
+// entryExpiration returns the Item.Expiration of e stored now.
+func (c *cache[K, V]) entryExpiration(e Entry[V]) int64 {
+	if !e.Deadline.IsZero() {
+		return e.Deadline.UnixNano()
+	}
+	return c.valueExpiration(e.Value, e.Duration)
+}
+
+// SetManyWithTTL is like SetMany, but each item is stored with the duration
+// or deadline of its own Entry, e.g. to load a page of records that each carry
+// their own expiry. The cache's lock is taken only once.
+func (c *cache[K, V]) SetManyWithTTL(items map[K]Entry[V]) {
+	c.mu.Lock()
+	for k, e := range items {
+		c.setItem(k, e.Value, c.entryExpiration(e))
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+}
+
+// GetMany looks up all of the given keys while taking the cache's lock only
+// once, and returns the values of the ones that were found (and haven't
+// expired). Each key is counted as a lookup, as by Get: in Stats, by the
+// eviction policy and as a use of an item stored with SetWithUses, including
+// a key that appears more than once in keys.
+func (c *cache[K, V]) GetMany(keys []K) map[K]V {
+	m := make(map[K]V, len(keys))
+	locked := c.lockedReads()
+	if locked {
+		c.mu.Lock()
+	} else {
+		c.mu.RLock()
+	}
+	var hits, misses uint64
+	var exhausted []keyAndValue[K, V]
+	now := c.now()
+	for _, k := range keys {
+		item, found := c.items[k]
+		if !found || (item.Expiration > 0 && now > item.Expiration) {
+			misses++
+			continue
+		}
+		hits++
+		if c.policy != nil {
+			c.policy.access(k)
+		}
+		if locked && c.use(k) {
+			exhausted = append(exhausted, keyAndValue[K, V]{k, item.Object})
+		}
+		m[k] = c.decoded(item.Object)
+	}
+	f := c.onEvicted
+	if locked {
+		c.mu.Unlock()
+	} else {
+		c.mu.RUnlock()
+	}
+	c.stats.hits.Add(hits)
+	c.stats.misses.Add(misses)
+	for _, v := range exhausted {
+		c.notifyExhausted(f, v.key, v.value)
+	}
+	return m
+}
+
+// GetBatch is like GetMany, but also returns the keys that weren't found (or
+// have expired), in the order they appear in keys, e.g. to load just those
+// afterwards.
+func (c *cache[K, V]) GetBatch(keys []K) (found map[K]V, missing []K) {
+	found = c.GetMany(keys)
+	return found, missingKeys(keys, found)
+}
+
+// missingKeys returns the keys that aren't in found, in order.
+func missingKeys[K comparable, V any](keys []K, found map[K]V) []K {
+	var missing []K
+	for _, k := range keys {
+		if _, ok := found[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// DeleteMany deletes all of the given keys from the cache while taking the
+// cache's lock only once. Keys that are not in the cache are ignored.
+func (c *cache[K, V]) DeleteMany(keys []K) {
+	var evictedItems []keyAndValue[K, V]
+	var n uint64
+	c.mu.Lock()
+	for _, k := range keys {
+		v, found := c.delete(k)
+		if !found {
+			continue
+		}
+		n++
+		if c.onEvicted != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, v})
+		}
+	}
+	f := c.onEvicted
+	c.mu.Unlock()
+	c.stats.evicted(ReasonDeleted, n)
+	notifyEvicted(f, evictedItems, ReasonDeleted)
+}
+
+// DeleteFunc deletes every unexpired item for which pred returns true, calling
+// the eviction callback with ReasonDeleted for each of them, and returns the
+// number of items deleted, e.g. to drop all the sessions of a user. It looks
+// at every item in the cache, while holding the cache's write lock: pred must
+// be fast and must not call back into the cache. The eviction callback is
+// called once the lock has been released. See also DeletePrefix.
+func (c *cache[K, V]) DeleteFunc(pred func(k K, v V) bool) int {
+	var evictedItems []keyAndValue[K, V]
+	n := 0
+	c.mu.Lock()
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		if !pred(k, c.decoded(v.Object)) {
+			continue
+		}
+		c.delete(k)
+		n++
+		if c.onEvicted != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, v.Object})
+		}
+	}
+	ef := c.onEvicted
+	c.mu.Unlock()
+	c.stats.evicted(ReasonDeleted, uint64(n))
+	notifyEvicted(ef, evictedItems, ReasonDeleted)
+	return n
+}
+
+// SetMany sets all of the given items to the cache, taking the lock of each
+// shard involved only once.
+func (sc *shardedCache[K, V]) SetMany(items map[K]V, d time.Duration) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	for i, g := range groupItems(sc, items) {
+		if g != nil {
+			sc.cs[i].SetMany(g, d)
+		}
+	}
+}
+
+// SetManyWithTTL sets all of the given items to the cache, each with the
+// expiration of its own Entry, taking the lock of each shard involved only
+// once. See Cache.SetManyWithTTL.
+func (sc *shardedCache[K, V]) SetManyWithTTL(items map[K]Entry[V]) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	for i, g := range groupItems(sc, items) {
+		if g != nil {
+			sc.cs[i].SetManyWithTTL(g)
+		}
+	}
+}
+
+// groupItems splits items by the index of the shard holding their keys.
+func groupItems[K comparable, V, T any](sc *shardedCache[K, V], items map[K]T) []map[K]T {
+	groups := make([]map[K]T, len(sc.cs))
+	for k, x := range items {
+		i := sc.index(k)
+		if groups[i] == nil {
+			groups[i] = make(map[K]T)
+		}
+		groups[i][k] = x
+	}
+	return groups
+}
+
+// parallelGetManyMin is the number of keys from which the sharded GetMany
+// looks up the shards in parallel; below it, starting goroutines costs more
+// than it saves. See BenchmarkShardedCacheGetMany.
+const parallelGetManyMin = 512
+
+// GetMany looks up all of the given keys, taking the lock of each shard
+// involved only once, and returns the values of the ones that were found. For
+// large batches the shards are looked up in parallel, by up to GOMAXPROCS
+// goroutines.
+func (sc *shardedCache[K, V]) GetMany(keys []K) map[K]V {
+	sc.mu.RLock()
+	defer sc.unlock()
+	groups := sc.groupKeys(keys)
+	if len(keys) < parallelGetManyMin || len(sc.cs) == 1 || runtime.GOMAXPROCS(0) == 1 {
+		return sc.getManySequential(groups, len(keys))
+	}
+	return sc.getManyParallel(groups, len(keys))
+}
+
+// getManySequential looks up the groups of keys returned by groupKeys one
+// shard after the other.
+func (sc *shardedCache[K, V]) getManySequential(groups [][]K, n int) map[K]V {
+	m := make(map[K]V, n)
+	for i, g := range groups {
+		if g == nil {
+			continue
+		}
+		for k, v := range sc.cs[i].GetMany(g) {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// getManyParallel looks up the groups of keys returned by groupKeys in
+// parallel, by up to GOMAXPROCS goroutines taking the shards in turn.
+func (sc *shardedCache[K, V]) getManyParallel(groups [][]K, n int) map[K]V {
+	results := make([]map[K]V, len(groups))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := min(runtime.GOMAXPROCS(0), len(groups)); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(groups) {
+					return
+				}
+				if groups[i] != nil {
+					results[i] = sc.cs[i].GetMany(groups[i])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	m := make(map[K]V, n)
+	for _, r := range results {
+		for k, v := range r {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// GetBatch looks up all of the given keys like GetMany, and also returns the
+// keys that weren't found, in the order they appear in keys. See
+// Cache.GetBatch.
+func (sc *shardedCache[K, V]) GetBatch(keys []K) (found map[K]V, missing []K) {
+	found = sc.GetMany(keys)
+	return found, missingKeys(keys, found)
+}
+
+// DeleteMany deletes all of the given keys from the cache, taking the lock of
+// each shard involved only once.
+func (sc *shardedCache[K, V]) DeleteMany(keys []K) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for i, g := range sc.groupKeys(keys) {
+		if g != nil {
+			sc.cs[i].DeleteMany(g)
+		}
+	}
+}
+
+// DeleteFunc deletes every unexpired item for which pred returns true, one
+// shard at a time, holding the lock of each shard while it is scanned, and
+// returns the number of items deleted. See Cache.DeleteFunc.
+func (sc *shardedCache[K, V]) DeleteFunc(pred func(k K, v V) bool) int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	n := 0
+	for _, v := range sc.cs {
+		n += v.DeleteFunc(pred)
+	}
+	return n
+}
+
+// groupKeys splits keys by the index of the shard holding them.
+func (sc *shardedCache[K, V]) groupKeys(keys []K) [][]K {
+	groups := make([][]K, len(sc.cs))
+	for _, k := range keys {
+		i := sc.index(k)
+		groups[i] = append(groups[i], k)
+	}
+	return groups
+}
@@ -0,0 +1,311 @@
+package ttlcache
+
+import (
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetManyGetManyDeleteMany(t *testing.T) {
+	caches := map[string]interface {
+		SetMany(map[string]int, time.Duration)
+		GetMany([]string) map[string]int
+		DeleteMany([]string)
+		ItemCount() int
+	}{
+		"standard": New[string, int](DefaultExpiration, 0),
+		"sharded":  NewSharded[string, int](DefaultExpiration, 0, 4),
+	}
+	for name, tc := range caches {
+		items := map[string]int{}
+		for i, k := range shardedKeys {
+			items[k] = i
+		}
+		tc.SetMany(items, DefaultExpiration)
+		if n := tc.ItemCount(); n != len(shardedKeys) {
+			t.Errorf("%s: item count is not %d: %d", name, len(shardedKeys), n)
+		}
+
+		m := tc.GetMany(append([]string{"missing"}, shardedKeys...))
+		if len(m) != len(shardedKeys) {
+			t.Errorf("%s: GetMany returned %d items instead of %d", name, len(m), len(shardedKeys))
+		}
+		for i, k := range shardedKeys {
+			if m[k] != i {
+				t.Errorf("%s: %s is not %d: %d", name, k, i, m[k])
+			}
+		}
+		if _, found := m["missing"]; found {
+			t.Errorf("%s: GetMany returned a missing key", name)
+		}
+
+		tc.DeleteMany(shardedKeys[:5])
+		if n := tc.ItemCount(); n != len(shardedKeys)-5 {
+			t.Errorf("%s: item count is not %d after deleting: %d", name, len(shardedKeys)-5, n)
+		}
+		if m := tc.GetMany(shardedKeys[:5]); len(m) != 0 {
+			t.Errorf("%s: deleted keys were found: %v", name, m)
+		}
+	}
+}
+
+func TestGetManyExpired(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.SetMany(map[string]int{"a": 1, "b": 2}, time.Nanosecond)
+	tc.Set("c", 3, DefaultExpiration)
+	<-time.After(time.Millisecond)
+	if m := tc.GetMany([]string{"a", "b", "c"}); len(m) != 1 || m["c"] != 3 {
+		t.Error("GetMany returned expired items:", m)
+	}
+}
+
+func TestGetManyCountsLookups(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	tc.Set("a", "1", DefaultExpiration)
+	tc.SetWithUses("token", "secret", 2)
+	m := tc.GetMany([]string{"a", "a", "missing", "missing", "token"})
+	if len(m) != 2 {
+		t.Error("Unexpected items:", m)
+	}
+	if s := tc.Stats(); s.Hits != 3 || s.Misses != 2 {
+		t.Errorf("GetMany counted %d hits and %d misses instead of 3 and 2", s.Hits, s.Misses)
+	}
+	var reasons []EvictionReason
+	tc.OnEvicted(func(k string, v string, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	})
+	if m := tc.GetMany([]string{"token", "token"}); m["token"] != "secret" {
+		t.Error("The last read of token didn't return it:", m)
+	}
+	if tc.Has("token") || len(reasons) != 1 || reasons[0] != ReasonExhausted {
+		t.Error("GetMany didn't use up the reads of token:", reasons)
+	}
+	if s := tc.Stats(); s.Hits != 4 || s.Misses != 3 {
+		t.Errorf("%d hits and %d misses were counted instead of 4 and 3", s.Hits, s.Misses)
+	}
+}
+
+func TestGetBatch(t *testing.T) {
+	caches := map[string]interface {
+		SetMany(map[string]int, time.Duration)
+		GetBatch([]string) (map[string]int, []string)
+	}{
+		"standard": New[string, int](DefaultExpiration, 0),
+		"sharded":  NewSharded[string, int](DefaultExpiration, 0, 4),
+	}
+	for name, tc := range caches {
+		items := map[string]int{}
+		for i, k := range shardedKeys[:5] {
+			items[k] = i
+		}
+		tc.SetMany(items, DefaultExpiration)
+
+		keys := append([]string{"z"}, shardedKeys...)
+		found, missing := tc.GetBatch(append(keys, "a"))
+		if len(found) != 5 {
+			t.Errorf("%s: GetBatch found %d items instead of 5", name, len(found))
+		}
+		for i, k := range shardedKeys[:5] {
+			if found[k] != i {
+				t.Errorf("%s: %s is not %d: %d", name, k, i, found[k])
+			}
+		}
+		want := append(append([]string{"z"}, shardedKeys[5:]...), "a")
+		if !slices.Equal(missing, want) {
+			t.Errorf("%s: GetBatch returned the missing keys %v instead of %v", name, missing, want)
+		}
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	caches := map[string]interface {
+		Set(string, int, time.Duration)
+		DeleteFunc(func(string, int) bool) int
+		OnEvicted(func(string, int, EvictionReason))
+		ItemCount() int
+	}{
+		"standard": New[string, int](DefaultExpiration, 0),
+		"sharded":  NewSharded[string, int](DefaultExpiration, 0, 4),
+	}
+	for name, tc := range caches {
+		for i, k := range shardedKeys {
+			tc.Set(k, i, DefaultExpiration)
+		}
+		tc.Set("expired", 0, time.Nanosecond)
+		<-time.After(time.Millisecond)
+		evicted := map[string]EvictionReason{}
+		tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+			evicted[k] = reason
+		})
+		n := tc.DeleteFunc(func(k string, v int) bool {
+			if k == "expired" {
+				t.Errorf("%s: DeleteFunc called pred with an expired item", name)
+			}
+			return v%2 == 0
+		})
+		want := (len(shardedKeys) + 1) / 2
+		if n != want || len(evicted) != want {
+			t.Errorf("%s: DeleteFunc deleted %d items and reported %d instead of %d", name, n, len(evicted), want)
+		}
+		for k, reason := range evicted {
+			if reason != ReasonDeleted {
+				t.Errorf("%s: %s was evicted with reason %v", name, k, reason)
+			}
+		}
+		if c := tc.ItemCount(); c != len(shardedKeys)-want {
+			t.Errorf("%s: %d items are left instead of %d", name, c, len(shardedKeys)-want)
+		}
+	}
+}
+
+func TestSetManyWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	caches := map[string]interface {
+		SetManyWithTTL(map[string]Entry[int])
+		TTL(string) (time.Duration, bool)
+		Get(string) (int, bool)
+	}{
+		"standard": New[string, int](time.Hour, 0, WithClock[string, int](clock)),
+		"sharded":  NewSharded[string, int](time.Hour, 0, 4, WithClock[string, int](clock)),
+	}
+	for name, tc := range caches {
+		tc.SetManyWithTTL(map[string]Entry[int]{
+			"default":  {Value: 1},
+			"minute":   {Value: 2, Duration: time.Minute},
+			"forever":  {Value: 3, Duration: NoExpiration},
+			"deadline": {Value: 4, Duration: time.Minute, Deadline: clock.Now().Add(time.Second)},
+		})
+		for k, want := range map[string]time.Duration{
+			"default":  time.Hour,
+			"minute":   time.Minute,
+			"forever":  NoExpiration,
+			"deadline": time.Second,
+		} {
+			if ttl, found := tc.TTL(k); !found || ttl != want {
+				t.Errorf("%s: TTL of %s is %v, want %v", name, k, ttl, want)
+			}
+		}
+		if v, _ := tc.Get("deadline"); v != 4 {
+			t.Errorf("%s: deadline is not 4: %d", name, v)
+		}
+	}
+}
+
+func TestDeleteManyOnEvicted(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.SetMany(map[string]int{"a": 1, "b": 2}, DefaultExpiration)
+	var evicted []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		if reason != ReasonDeleted {
+			t.Errorf("%s was evicted with reason %v instead of %v", k, reason, ReasonDeleted)
+		}
+		evicted = append(evicted, k)
+	})
+	tc.DeleteMany([]string{"a", "b", "c"})
+	if len(evicted) != 2 {
+		t.Error("Unexpected evictions:", evicted)
+	}
+}
+
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "foo" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func BenchmarkCacheSetManyConcurrent(b *testing.B) {
+	b.StopTimer()
+	tc := New[string, string](DefaultExpiration, 0)
+	items := map[string]string{}
+	for _, k := range benchmarkKeys(1000) {
+		items[k] = "bar"
+	}
+	workers := runtime.NumCPU()
+	each := b.N / workers
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	b.StartTimer()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < each; j++ {
+				tc.SetMany(items, DefaultExpiration)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCacheSetLoopConcurrent(b *testing.B) {
+	// Compare against BenchmarkCacheSetManyConcurrent.
+	b.StopTimer()
+	tc := New[string, string](DefaultExpiration, 0)
+	keys := benchmarkKeys(1000)
+	workers := runtime.NumCPU()
+	each := b.N / workers
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	b.StartTimer()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < each; j++ {
+				for _, k := range keys {
+					tc.Set(k, "bar", DefaultExpiration)
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestShardedGetManyParallel(t *testing.T) {
+	sc := NewSharded[string, int](DefaultExpiration, 0, 8)
+	keys := benchmarkKeys(2 * parallelGetManyMin)
+	for i, k := range keys {
+		if i%2 == 0 {
+			sc.Set(k, i, DefaultExpiration)
+		}
+	}
+	// GetMany only looks up the shards in parallel if GOMAXPROCS > 1, so the
+	// parallel lookup is also tested directly.
+	for name, m := range map[string]map[string]int{
+		"GetMany":  sc.GetMany(keys),
+		"parallel": sc.getManyParallel(sc.groupKeys(keys), len(keys)),
+	} {
+		if len(m) != parallelGetManyMin {
+			t.Fatalf("The %s lookup found %d keys instead of %d", name, len(m), parallelGetManyMin)
+		}
+		for i, k := range keys {
+			if x, found := m[k]; found != (i%2 == 0) || (found && x != i) {
+				t.Errorf("%s is %d, %v in the result of the %s lookup", k, x, found, name)
+			}
+		}
+	}
+}
+
+func BenchmarkShardedCacheGetMany(b *testing.B) {
+	for _, n := range []int{16, 128, 512, 4096} {
+		keys := benchmarkKeys(n)
+		sc := NewSharded[string, string](DefaultExpiration, 0, 16)
+		for _, k := range keys {
+			sc.Set(k, "bar", DefaultExpiration)
+		}
+		groups := sc.groupKeys(keys)
+		b.Run("Sequential/"+strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sc.getManySequential(groups, n)
+			}
+		})
+		b.Run("Parallel/"+strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sc.getManyParallel(groups, n)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package ttlcache
 
 import (
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,13 +18,84 @@ const (
 
 	// DefaultExpiration is used with functions that take an expiration time. Equivalent to
 	// passing in the same expiration duration as was given to New() or
-	// NewFrom() when the cache was created (e.g. 5 minutes.)
+	// NewFrom() when the cache was created (e.g. 5 minutes.) Given to a
+	// constructor as the default expiration itself, it means the same as
+	// NoExpiration: there is no default, and items never expire unless they
+	// are stored with an explicit duration.
 	DefaultExpiration time.Duration = 0
 )
 
+var (
+	// ErrKeyExists is returned, wrapped, by Add when an unexpired item is
+	// already stored at the key.
+	ErrKeyExists = errors.New("ttlcache: item already exists")
+
+	// ErrKeyNotFound is returned, wrapped, by Replace, Increment and the like
+	// when no unexpired item is stored at the key.
+	ErrKeyNotFound = errors.New("ttlcache: item not found")
+
+	// ErrValueTooLarge is returned, wrapped, by Add and Replace when the
+	// cost of the value exceeds the limit set with WithMaxValueCost; see
+	// there for how the other methods treat such a value.
+	ErrValueTooLarge = errors.New("ttlcache: value too large")
+)
+
+// EvictionReason describes why an item was removed from the cache.
+type EvictionReason int
+
+const (
+	// ReasonExpired means the item was removed because its expiration time
+	// had passed.
+	ReasonExpired EvictionReason = iota + 1
+
+	// ReasonDeleted means the item was removed with Delete.
+	ReasonDeleted
+
+	// ReasonReplaced means the item's value was overwritten by Replace.
+	ReasonReplaced
+
+	// ReasonCapacity means the item was removed to make room for another one.
+	ReasonCapacity
+
+	// ReasonFlushed means the item was removed by Flush, FlushWith or
+	// FlushExpirable.
+	ReasonFlushed
+
+	// ReasonExhausted means the item was removed because it had been read
+	// as many times as allowed by SetWithUses.
+	ReasonExhausted
+
+	numEvictionReasons = iota + 1
+)
+
+// String returns a human-readable name for the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonFlushed:
+		return "flushed"
+	case ReasonExhausted:
+		return "exhausted"
+	}
+	return fmt.Sprintf("EvictionReason(%d)", int(r))
+}
+
 type Item[V any] struct {
 	Object     V
 	Expiration int64
+
+	// Version is assigned each time a value is stored at the item's key,
+	// and left alone when only the expiration time changes. Versions are
+	// strictly increasing within a cache (or a shard of a sharded cache),
+	// but not consecutive. See GetWithVersion.
+	Version uint64
 }
 
 // Expired returns true if the item has expired.
@@ -39,19 +112,20 @@ type Cache[K comparable, V any] struct {
 }
 
 // New returns a new cache with a given default expiration duration and cleanup
-// interval. If the expiration duration is less than one (or NoExpiration),
-// the items in the cache never expire (by default), and must be deleted
-// manually. If the cleanup interval is less than one, expired items are not
-// deleted from the cache before calling c.DeleteExpired().
-func New[K comparable, V any](defaultExpiration, cleanupInterval time.Duration) *Cache[K, V] {
+// interval. If the expiration duration is less than one (NoExpiration or
+// DefaultExpiration), the items in the cache never expire (by default), and
+// must be deleted manually. If the cleanup interval is less than one, expired items are not
+// deleted from the cache before calling c.DeleteExpired(). Further behaviour,
+// such as a cap on the number of items, can be configured with opts.
+func New[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, opts ...Option[K, V]) *Cache[K, V] {
 	items := make(map[K]Item[V])
-	return newCacheWithJanitor[K, V](defaultExpiration, cleanupInterval, items)
+	return newCacheWithJanitor[K, V](defaultExpiration, cleanupInterval, items, newConfig(opts))
 }
 
 // NewFrom returns a new cache with a given default expiration duration and cleanup
-// interval. If the expiration duration is less than one (or NoExpiration),
-// the items in the cache never expire (by default), and must be deleted
-// manually. If the cleanup interval is less than one, expired items are not
+// interval. If the expiration duration is less than one (NoExpiration or
+// DefaultExpiration), the items in the cache never expire (by default), and
+// must be deleted manually. If the cleanup interval is less than one, expired items are not
 // deleted from the cache before calling c.DeleteExpired().
 //
 // NewFrom() also accepts an items map which will serve as the underlying map
@@ -62,69 +136,202 @@ func New[K comparable, V any](defaultExpiration, cleanupInterval time.Duration)
 //
 // Only the cache's methods synchronize access to this map, so it is not
 // recommended to keep any references to the map around after creating a cache.
-// If need be, the map can be accessed at a later point using c.Items() (subject
-// to the same caveat.)
+// If need be, the map can be accessed at a later point using c.ItemsRef()
+// (subject to the same caveat), or copied safely using c.Items().
 //
 // Note regarding serialization: When using e.g. gob, make sure to
 // gob.Register() the individual types stored in the cache before encoding a
 // map retrieved with c.Items(), and to register those same types before
 // decoding a blob containing an items map.
-func NewFrom[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, items map[K]Item[V]) *Cache[K, V] {
-	return newCacheWithJanitor[K, V](defaultExpiration, cleanupInterval, items)
+func NewFrom[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, items map[K]Item[V], opts ...Option[K, V]) *Cache[K, V] {
+	return newCacheWithJanitor[K, V](defaultExpiration, cleanupInterval, items, newConfig(opts))
 }
 
 type cache[K comparable, V any] struct {
 	defaultExpiration time.Duration
 	items             map[K]Item[V]
-	mu                sync.RWMutex
-	onEvicted         func(K, V)
+	peak              int // the most items held before a deletion since items was made; see Compact
+	mu                rwMutex
+	onEvicted         func(K, V, EvictionReason) // evictedFunc and subs combined; see updateOnEvicted
+	evictedFunc       func(K, V, EvictionReason) // set by OnEvicted
+	onEvictedBatch    func([]Event[K, V])
+	beforeEvict       func(K, V) bool // set by OnBeforeEvict
+	onExpired         func(K, V)      // set by OnExpired
+	onCleanup         atomic.Pointer[func(int, time.Duration)]
+	outbox            *outbox            // of the sharded cache c is a shard of, if any
+	subs              *subscribers[K, V] // nil until Subscribe is first called
+	janitorMu         sync.Mutex         // guards janitor
 	janitor           *janitor[K, V]
+	sweepKeys         []K       // keys left to visit in the janitor's current cycle; see tick
+	closeOnce         sync.Once // drains the cache on the first Close
+	loads             loadGroup[K, V]
+	negatives         map[K]int64    // expiration times of cached ErrNotFound results
+	uses              map[K]int      // reads left of items stored with SetWithUses
+	limitedUses       atomic.Bool    // set once uses is first written to
+	pinned            map[K]struct{} // keys exempt from eviction; see Pin
+	tags              *tagIndex[K]   // the keys of each tag, shared by shards; see SetWithTags
+	keyTags           map[K][]string // the tags of each key
+	deleteOnGet       bool           // see WithDeleteOnGet
+	maxItems          int
+	policy            evictionPolicy[K] // nil if the cache is unbounded
+	sharedAccess      bool              // whether policy implements sharedAccessor
+	expirer           bool              // whether values may implement Expirer
+	costFunc          func(V) int64
+	maxCost           int64
+	maxValueCost      int64 // see WithMaxValueCost
+	cost              int64
+	costs             map[K]int64
+	totalCost         *atomic.Int64       // the sharded cache's total cost, if c is a shard
+	tracking          bool                // whether writes need to call track
+	pending           []keyAndValue[K, V] // evicted by track, not yet returned by evictOverflow
+	clock             Clock               // nil means the real clock
+	jitter            time.Duration       // see WithExpirationJitter
+	encode            func(V) V           // see WithCodec
+	decode            func(V) V           // see WithCodec
+	version           uint64              // the last Item.Version assigned
+	cfg               config[K, V]        // the options the cache was created with
+	stats             stats
 }
 
-func newCache[K comparable, V any](de time.Duration, m map[K]Item[V]) *cache[K, V] {
-	if de == 0 {
-		de = -1
+// resolveDefaultExpiration resolves the default expiration duration given to a
+// constructor. Passing DefaultExpiration there can't mean "use the default",
+// so it is taken to mean that there is no default: like NoExpiration or any
+// other negative duration, items stored with DefaultExpiration then never
+// expire. The result is therefore either positive or NoExpiration.
+func resolveDefaultExpiration(de time.Duration) time.Duration {
+	if de <= 0 {
+		return NoExpiration
 	}
+	return de
+}
+
+// newCache returns a cache holding the items of m. totalCost is the total cost
+// counter of the sharded cache it is a shard of, or nil for a standard cache.
+func newCache[K comparable, V any](de time.Duration, m map[K]Item[V], cfg config[K, V], totalCost *atomic.Int64) *cache[K, V] {
 	c := &cache[K, V]{
-		defaultExpiration: de,
+		defaultExpiration: resolveDefaultExpiration(de),
 		items:             m,
+		clock:             cfg.clock,
+		cfg:               cfg,
+		deleteOnGet:       !cfg.keepExpiredOnGet,
+		totalCost:         totalCost,
+	}
+	if cfg.jitter > 0 {
+		c.jitter = cfg.jitter
+	}
+	c.encode, c.decode = cfg.encode, cfg.decode
+	c.expirer = mayImplement[V, Expirer]()
+	if cfg.contention {
+		c.mu.contention = new(contention)
 	}
+	if cfg.costFunc != nil {
+		c.costFunc = cfg.costFunc
+		c.costs = make(map[K]int64, len(m))
+		if cfg.maxCost > 0 {
+			c.maxCost = cfg.maxCost
+		}
+		if cfg.maxValueCost > 0 {
+			c.maxValueCost = cfg.maxValueCost
+		}
+	}
+	if cfg.maxItems > 0 {
+		c.maxItems = cfg.maxItems
+	}
+	// WithMaxTotalCost only bounds shards, and needs the eviction policy
+	// to pick the items trimTotalCost evicts.
+	if c.maxItems > 0 || c.maxCost > 0 || (c.costFunc != nil && totalCost != nil && cfg.maxTotalCost > 0) {
+		c.policy = newEvictionPolicy[K](cfg.policy, cfg.sampleSize)
+		_, c.sharedAccess = c.policy.(sharedAccessor)
+	}
+	c.tracking = c.policy != nil || c.costFunc != nil
+	c.loadItems(m)
 	return c
 }
 
-func newCacheWithJanitor[K comparable, V any](de time.Duration, ci time.Duration, m map[K]Item[V]) *Cache[K, V] {
-	c := newCache[K, V](de, m)
+// loadItems makes the items of m, with values not encoded yet, the items of c,
+// which must be empty, and evicts those over c's limits. Pins must be set
+// before, so that pinned items aren't evicted.
+func (c *cache[K, V]) loadItems(m map[K]Item[V]) {
+	for k, v := range m {
+		c.version = max(c.version, v.Version)
+		if c.encode != nil {
+			v.Object = c.encode(v.Object)
+			m[k] = v
+		}
+	}
+	c.items = m
+	if c.tracking {
+		for k, v := range m {
+			c.track(k, v.Object)
+		}
+		c.evictOverflow()
+	}
+}
+
+func newCacheWithJanitor[K comparable, V any](de time.Duration, ci time.Duration, m map[K]Item[V], cfg config[K, V]) *Cache[K, V] {
+	c := newCache[K, V](de, m, cfg, nil)
 	// This trick ensures that the janitor goroutine (which--granted it
 	// was enabled--is running DeleteExpired on c forever) does not keep
 	// the returned C object from being garbage collected. When it is
 	// garbage collected, the finalizer stops the janitor goroutine, after
-	// which c can be collected.
+	// which c can be collected. The finalizer is set even without a
+	// janitor, since one can be started later by SetCleanupInterval.
 	C := &Cache[K, V]{
 		cache: c,
 	}
 	if ci > 0 {
 		runJanitor(c, ci)
+	}
+	if !cfg.noFinalizer {
 		runtime.SetFinalizer(C, stopJanitor[K, V])
 	}
 	return C
 }
 
 // Set an item to the cache, replacing any existing item. If the duration is 0
-// (DefaultExpiration), the cache's default expiration time is used. If it is -1
-// (NoExpiration), the item never expires.
+// (DefaultExpiration), the cache's default expiration time is used, unless x
+// implements Expirer. If it is -1 (NoExpiration), the item never expires.
 func (c *cache[K, V]) Set(k K, x V, d time.Duration) {
 	// "Inlining" of set
 	var e int64
 	if d == DefaultExpiration {
 		d = c.defaultExpiration
+		if c.expirer {
+			if e = expireAt(x); e != 0 {
+				d = 0
+			}
+		}
 	}
 	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+		e = c.now() + int64(d)
+		if c.jitter > 0 {
+			e += c.jitterOffset()
+		}
+	}
+	if c.encode != nil {
+		x = c.encode(x)
 	}
 	c.mu.Lock()
+	c.version++
 	c.items[k] = Item[V]{
 		Object:     x,
 		Expiration: e,
+		Version:    c.version,
+	}
+	if c.uses != nil {
+		delete(c.uses, k)
+	}
+	if c.keyTags != nil {
+		c.untag(k)
+	}
+	c.stats.insertions.Add(1)
+	if c.tracking {
+		c.track(k, x)
+		evicted := c.evictOverflow()
+		f := c.onEvicted
+		c.mu.Unlock()
+		notifyEvicted(f, evicted, ReasonCapacity)
+		return
 	}
 	// TODO: Calls to mu.Unlock are currently not deferred because defer
 	// adds ~200 ns (as of go1.)
@@ -132,101 +339,446 @@ func (c *cache[K, V]) Set(k K, x V, d time.Duration) {
 }
 
 func (c *cache[K, V]) set(k K, x V, d time.Duration) {
-	var e int64
-	if d == DefaultExpiration {
-		d = c.defaultExpiration
+	c.setItem(k, x, c.valueExpiration(x, d))
+}
+
+// prepare returns x encoded with the codec of WithCodec, and its
+// Item.Expiration if it is stored with the duration d, so that it can be
+// passed to storeItem; it is called before taking the lock, to encode x
+// outside of it. It also returns the error of checkValueCost for the encoded
+// value.
+func (c *cache[K, V]) prepare(k K, x V, d time.Duration) (V, int64, error) {
+	e := c.valueExpiration(x, d)
+	x = c.encoded(x)
+	return x, e, c.checkValueCost(k, x)
+}
+
+// encoded returns x as it is stored in the cache: encoded by the encode
+// function of WithCodec, if one is set.
+func (c *cache[K, V]) encoded(x V) V {
+	if c.encode != nil {
+		return c.encode(x)
 	}
-	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+	return x
+}
+
+// decoded returns x, as stored in the cache, as it is returned to callers:
+// decoded by the decode function of WithCodec, if one is set.
+func (c *cache[K, V]) decoded(x V) V {
+	if c.decode != nil {
+		return c.decode(x)
 	}
+	return x
+}
+
+// setItem stores x at k with the given Item.Expiration, encoded with the
+// codec of WithCodec. It must be called with c.mu held.
+func (c *cache[K, V]) setItem(k K, x V, e int64) {
+	c.storeItem(k, c.encoded(x), e)
+}
+
+// storeItem stores x, already encoded, at k with the given Item.Expiration. It
+// must be called with c.mu held.
+func (c *cache[K, V]) storeItem(k K, x V, e int64) {
+	c.version++
 	c.items[k] = Item[V]{
 		Object:     x,
 		Expiration: e,
+		Version:    c.version,
+	}
+	if c.uses != nil {
+		delete(c.uses, k)
+	}
+	if c.keyTags != nil {
+		c.untag(k)
+	}
+	c.stats.insertions.Add(1)
+	if c.tracking {
+		c.track(k, x)
 	}
 }
 
-// SetDefault sets an item to the cache, replacing any existing item, using the default
-// expiration.
+// SetDefault sets an item to the cache, replacing any existing item, using the
+// cache's default expiration: the one passed to the constructor, or never if
+// that was less than one. It is the same as Set with DefaultExpiration, but
+// states the intent at the call site.
 func (c *cache[K, V]) SetDefault(k K, x V) {
 	c.Set(k, x, DefaultExpiration)
 }
 
+// SetNoExpire sets an item to the cache, replacing any existing item, so that
+// it never expires, whatever the cache's default expiration. It is the same as
+// Set with NoExpiration.
+func (c *cache[K, V]) SetNoExpire(k K, x V) {
+	c.Set(k, x, NoExpiration)
+}
+
+// SetTTL sets an item to the cache, replacing any existing item, so that it
+// expires after ttl, whatever the cache's default expiration. Unlike the
+// duration passed to Set, ttl has no special values: SetTTL panics if it is
+// not positive.
+func (c *cache[K, V]) SetTTL(k K, x V, ttl time.Duration) {
+	if ttl <= 0 {
+		panic(fmt.Sprintf("ttlcache: non-positive TTL %v for item %v", ttl, k))
+	}
+	c.Set(k, x, ttl)
+}
+
 // Add an item to the cache only if an item doesn't already exist for the given
-// key, or if the existing item has expired. Returns an error otherwise.
+// key, or if the existing item has expired. Returns an error wrapping
+// ErrKeyExists otherwise.
 func (c *cache[K, V]) Add(k K, x V, d time.Duration) error {
+	x, e, err := c.prepare(k, x, d)
+	if err != nil {
+		return err
+	}
+	if !c.setIfAbsent(k, x, e) {
+		return fmt.Errorf("%w: %v", ErrKeyExists, k)
+	}
+	return nil
+}
+
+// SetIfAbsent sets an item to the cache only if an item doesn't already exist
+// for the given key, or if the existing item has expired. It reports whether
+// the item was set.
+func (c *cache[K, V]) SetIfAbsent(k K, x V, d time.Duration) bool {
+	x, e, err := c.prepare(k, x, d)
+	if err != nil {
+		return false
+	}
+	return c.setIfAbsent(k, x, e)
+}
+
+// setIfAbsent stores x, prepared by prepare, at k with the Item.Expiration e if
+// k is missing or has expired.
+func (c *cache[K, V]) setIfAbsent(k K, x V, e int64) bool {
 	c.mu.Lock()
 	_, found := c.get(k)
 	if found {
 		c.mu.Unlock()
-		return fmt.Errorf("item %v already exists", k)
+		return false
+	}
+	c.storeItem(k, x, e)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return true
+}
+
+// SetIfPresent sets a new value for an item only if it already exists for the
+// given key and hasn't expired, and reports whether the item was set. It is
+// the counterpart of SetIfAbsent, and is like Replace without the error; like
+// Set, it doesn't call the eviction callback for the old value.
+func (c *cache[K, V]) SetIfPresent(k K, x V, d time.Duration) bool {
+	x, e, err := c.prepare(k, x, d)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	if _, found := c.get(k); !found {
+		c.mu.Unlock()
+		return false
+	}
+	c.storeItem(k, x, e)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return true
+}
+
+// GetOrSet returns the existing value for k if it is present and hasn't
+// expired, and true. Otherwise, it sets x with the given duration and returns
+// it, and false. Like sync.Map's LoadOrStore, the operation is atomic.
+func (c *cache[K, V]) GetOrSet(k K, x V, d time.Duration) (V, bool) {
+	c.mu.Lock()
+	if v, found := c.get(k); found {
+		if c.policy != nil {
+			c.policy.access(k)
+		}
+		exhausted := c.use(k)
+		f := c.onEvicted
+		c.mu.Unlock()
+		c.stats.hits.Add(1)
+		if exhausted {
+			c.notifyExhausted(f, k, v)
+		}
+		return c.decoded(v), true
 	}
 	c.set(k, x, d)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
 	c.mu.Unlock()
-	return nil
+	c.stats.misses.Add(1)
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return x, false
 }
 
 // Replace sets a new value for the cache key only if it already exists, and the existing
-// item hasn't expired. Returns an error otherwise.
+// item hasn't expired. Returns an error wrapping ErrKeyNotFound otherwise.
 func (c *cache[K, V]) Replace(k K, x V, d time.Duration) error {
+	x, e, err := c.prepare(k, x, d)
+	if err != nil {
+		return err
+	}
 	c.mu.Lock()
-	_, found := c.get(k)
+	ov, found := c.get(k)
 	if !found {
 		c.mu.Unlock()
-		return fmt.Errorf("item %v doesn't exist", k)
+		return fmt.Errorf("%w: %v", ErrKeyNotFound, k)
 	}
-	c.set(k, x, d)
+	c.storeItem(k, x, e)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
 	c.mu.Unlock()
+	c.stats.evicted(ReasonReplaced, 1)
+	if f != nil {
+		f(k, ov, ReasonReplaced)
+	}
+	notifyEvicted(f, evicted, ReasonCapacity)
 	return nil
 }
 
-// Get an item from the cache. Returns the item or nil, and a bool indicating
-// whether the key was found.
+// Get an item from the cache. Returns the item or the zero value of V, and a
+// bool indicating whether the key was found.
 func (c *cache[K, V]) Get(k K) (V, bool) {
+	v, found := c.lookup(k)
+	if found {
+		v = c.decoded(v)
+	}
+	return v, found
+}
+
+// lookup is Get without the decode function of WithCodec: it returns the value
+// as it is stored.
+func (c *cache[K, V]) lookup(k K) (V, bool) {
+	if c.lockedReads() {
+		item, found := c.getAndTrack(k)
+		return item.Object, found
+	}
 	c.mu.RLock()
 	// "Inlining" of get and Expired
 	item, found := c.items[k]
 	if !found {
 		c.mu.RUnlock()
+		c.stats.misses.Add(1)
 		return item.Object, false
 	}
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
+		if c.now() > item.Expiration {
 			c.mu.RUnlock()
+			c.stats.misses.Add(1)
+			if c.deleteOnGet {
+				c.deleteIfExpired(k)
+			}
 			return item.Object, false
 		}
 	}
+	if c.policy != nil {
+		c.policy.access(k)
+	}
 	c.mu.RUnlock()
+	c.stats.hits.Add(1)
 	return item.Object, true
 }
 
 // GetWithExpiration returns an item and its expiration time from the cache.
-// It returns the item or nil, the expiration time if one is set (if the item
-// never expires a zero value for time.Time is returned), and a bool indicating
-// whether the key was found.
-func (c *cache[K, V]) GetWithExpiration(k K) (interface{}, time.Time, bool) {
+// It returns the item or the zero value of V, the expiration time if one is
+// set (if the item never expires a zero value for time.Time is returned), and
+// a bool indicating whether the key was found.
+func (c *cache[K, V]) GetWithExpiration(k K) (V, time.Time, bool) {
+	if c.lockedReads() {
+		item, found := c.getAndTrack(k)
+		if !found {
+			return item.Object, time.Time{}, false
+		}
+		if item.Expiration <= 0 {
+			return c.decoded(item.Object), time.Time{}, true
+		}
+		return c.decoded(item.Object), time.Unix(0, item.Expiration), true
+	}
 	c.mu.RLock()
 	// "Inlining" of get and Expired
 	item, found := c.items[k]
 	if !found {
 		c.mu.RUnlock()
-		return nil, time.Time{}, false
+		c.stats.misses.Add(1)
+		var zero V
+		return zero, time.Time{}, false
 	}
 
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
+		if c.now() > item.Expiration {
 			c.mu.RUnlock()
-			return nil, time.Time{}, false
+			c.stats.misses.Add(1)
+			if c.deleteOnGet {
+				c.deleteIfExpired(k)
+			}
+			var zero V
+			return zero, time.Time{}, false
 		}
 
 		// Return the item and the expiration time
+		if c.policy != nil {
+			c.policy.access(k)
+		}
 		c.mu.RUnlock()
-		return item.Object, time.Unix(0, item.Expiration), true
+		c.stats.hits.Add(1)
+		return c.decoded(item.Object), time.Unix(0, item.Expiration), true
 	}
 
 	// If expiration <= 0 (i.e. no expiration time set) then return the item
 	// and a zeroed time.Time
+	if c.policy != nil {
+		c.policy.access(k)
+	}
 	c.mu.RUnlock()
-	return item.Object, time.Time{}, true
+	c.stats.hits.Add(1)
+	return c.decoded(item.Object), time.Time{}, true
+}
+
+// lockedReads reports whether reads must go through getAndTrack, under the
+// write lock, rather than only take the read lock: that is, if the eviction
+// policy must be told of hits under the write lock, or if some items were
+// stored with SetWithUses.
+func (c *cache[K, V]) lockedReads() bool {
+	return (c.policy != nil && !c.sharedAccess) || c.limitedUses.Load()
+}
+
+// getAndTrack looks up k under the write lock, records the hit with the
+// eviction policy, and uses up one of the item's reads if it was stored with
+// SetWithUses. It returns the zero Item if k is missing or has expired.
+func (c *cache[K, V]) getAndTrack(k K) (Item[V], bool) {
+	c.mu.Lock()
+	item, found := c.items[k]
+	if !found || (item.Expiration > 0 && c.now() > item.Expiration) {
+		c.mu.Unlock()
+		c.stats.misses.Add(1)
+		if found && c.deleteOnGet {
+			c.deleteIfExpired(k)
+		}
+		return Item[V]{}, false
+	}
+	if c.policy != nil {
+		c.policy.access(k)
+	}
+	exhausted := c.use(k)
+	f := c.onEvicted
+	c.mu.Unlock()
+	c.stats.hits.Add(1)
+	if exhausted {
+		c.notifyExhausted(f, k, item.Object)
+	}
+	return item, true
+}
+
+// use uses up one of the reads of k if it was stored with SetWithUses, and
+// reports whether that was its last read, in which case k has been deleted,
+// and should be passed to notifyExhausted once c.mu is released. It must be
+// called with c.mu held, by the reads counted as uses; see SetWithUses.
+func (c *cache[K, V]) use(k K) bool {
+	n, limited := c.uses[k]
+	if !limited {
+		return false
+	}
+	if n <= 1 {
+		c.delete(k)
+		return true
+	}
+	c.uses[k] = n - 1
+	return false
+}
+
+// notifyExhausted records the removal of k, whose reads were used up, and
+// passes it to the eviction callback f, if set.
+func (c *cache[K, V]) notifyExhausted(f func(K, V, EvictionReason), k K, v V) {
+	c.stats.evicted(ReasonExhausted, 1)
+	if f != nil {
+		f(k, v, ReasonExhausted)
+	}
+}
+
+// SetWithUses sets an item to the cache, replacing any existing item, that can
+// only be read maxUses times. It is stored with the default expiration, and is
+// removed by whichever happens first: its expiration, or the read that uses
+// it up. That read still returns the item, after which the eviction callback
+// is called with ReasonExhausted. Reads are counted by the lookups that count
+// as hits in Stats: Get and the methods built on it, such as MustGet and GetOr,
+// GetWithExpiration, GetWithTTL, GetWithVersion, GetAndRefresh, GetMany and
+// GetBatch, and the hits of GetOrSet. Operations like Has, Items, Range or an
+// Iterator, which don't count as lookups, don't use up reads. Storing a new
+// value at k by any other means lifts the limit. If maxUses is less than one,
+// the item is stored without a limit, as by Set.
+//
+// Once an item is stored with SetWithUses, all reads of the cache take its
+// write lock, like the reads of a cache with an eviction policy.
+func (c *cache[K, V]) SetWithUses(k K, x V, maxUses int) {
+	c.mu.Lock()
+	c.set(k, x, DefaultExpiration)
+	if _, found := c.items[k]; found && maxUses > 0 {
+		if c.uses == nil {
+			c.uses = make(map[K]int)
+			c.limitedUses.Store(true)
+		}
+		c.uses[k] = maxUses
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+}
+
+// MustGet returns the value of k like Get, but panics if k is missing or has
+// expired. It is meant for call sites where the absence of k is a bug.
+func (c *cache[K, V]) MustGet(k K) V {
+	v, found := c.Get(k)
+	if !found {
+		panic(fmt.Sprintf("ttlcache: item %v not found", k))
+	}
+	return v
+}
+
+// GetOr returns the value of k like Get, or fallback if k is missing or has
+// expired.
+func (c *cache[K, V]) GetOr(k K, fallback V) V {
+	if v, found := c.Get(k); found {
+		return v
+	}
+	return fallback
+}
+
+// Has reports whether k is in the cache and hasn't expired. Unlike Get, it
+// only takes the read lock, isn't counted as a hit or miss in Stats, and isn't
+// considered a use of the item by the eviction policy.
+func (c *cache[K, V]) Has(k K) bool {
+	c.mu.RLock()
+	_, found := c.get(k)
+	c.mu.RUnlock()
+	return found
+}
+
+// deleteIfExpired deletes k if it has expired, as the janitor would. It is
+// called by lookups that find an expired item, which only take the write lock
+// in that case.
+func (c *cache[K, V]) deleteIfExpired(k K) {
+	c.mu.Lock()
+	item, found := c.items[k]
+	if !found || item.Expiration <= 0 || c.now() <= item.Expiration {
+		// It was deleted or replaced in the meantime.
+		c.mu.Unlock()
+		return
+	}
+	c.delete(k)
+	f, xf := c.onEvicted, c.onExpired
+	c.mu.Unlock()
+	c.stats.evicted(ReasonExpired, 1)
+	if f != nil {
+		f(k, item.Object, ReasonExpired)
+	}
+	if xf != nil {
+		xf(k, item.Object)
+	}
 }
 
 func (c *cache[K, V]) get(k K) (V, bool) {
@@ -236,7 +788,7 @@ func (c *cache[K, V]) get(k K) (V, bool) {
 	}
 	// "Inlining" of Expired
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
+		if c.now() > item.Expiration {
 			return item.Object, false
 		}
 	}
@@ -247,24 +799,39 @@ func (c *cache[K, V]) get(k K) (V, bool) {
 func (c *cache[K, V]) Delete(k K) {
 	c.mu.Lock()
 	v, evicted := c.delete(k)
+	f := c.onEvicted
 	c.mu.Unlock()
 	if evicted {
-		c.onEvicted(k, v)
+		c.stats.evicted(ReasonDeleted, 1)
+		if f != nil {
+			f(k, v, ReasonDeleted)
+		}
 	}
 }
 
+// delete removes k from the cache and returns its value and whether it was
+// present. It must be called with c.mu held.
 func (c *cache[K, V]) delete(k K) (V, bool) {
-	if c.onEvicted != nil {
-		if v, found := c.items[k]; found {
-			delete(c.items, k)
-			return v.Object, true
-		}
+	v, found := c.items[k]
+	if !found {
+		var result V
+		return result, false
 	}
-
+	if c.tracking {
+		c.untrack(k)
+	}
+	if c.uses != nil {
+		delete(c.uses, k)
+	}
+	if c.pinned != nil {
+		delete(c.pinned, k)
+	}
+	if c.keyTags != nil {
+		c.untag(k)
+	}
+	c.peak = max(c.peak, len(c.items))
 	delete(c.items, k)
-
-	var result V
-	return result, false
+	return v.Object, true
 }
 
 type keyAndValue[K comparable, V any] struct {
@@ -272,60 +839,347 @@ type keyAndValue[K comparable, V any] struct {
 	value V
 }
 
-// DeleteExpired deletes all expired items from the cache.
+// now returns the current time in nanoseconds, according to the cache's clock.
+func (c *cache[K, V]) now() int64 {
+	if c.clock == nil {
+		return time.Now().UnixNano()
+	}
+	return c.clock.Now().UnixNano()
+}
+
+// track updates the eviction policy and cost accounting after x was stored
+// under k. It must be called with c.mu held.
+func (c *cache[K, V]) track(k K, x V) {
+	_, pinned := c.pinned[k]
+	if c.policy != nil && !pinned {
+		c.policy.insert(k)
+	}
+	if c.costFunc != nil {
+		cost := c.costFunc(x)
+		c.addCost(cost - c.costs[k])
+		c.costs[k] = cost
+		if !pinned && ((c.maxCost > 0 && cost > c.maxCost) || (c.maxValueCost > 0 && cost > c.maxValueCost)) {
+			// No amount of evicting other items would make room for
+			// this one, so evict it right away instead.
+			ov, _ := c.delete(k)
+			c.stats.evicted(ReasonCapacity, 1)
+			if c.onEvicted != nil {
+				c.pending = append(c.pending, keyAndValue[K, V]{k, ov})
+			}
+		}
+	}
+}
+
+// checkValueCost returns an error wrapping ErrValueTooLarge if the cost of x,
+// to be stored at k, exceeds the limit set with WithMaxValueCost.
+func (c *cache[K, V]) checkValueCost(k K, x V) error {
+	if c.maxValueCost <= 0 {
+		return nil
+	}
+	if cost := c.costFunc(x); cost > c.maxValueCost {
+		return fmt.Errorf("%w: the value for %v costs %d, more than %d", ErrValueTooLarge, k, cost, c.maxValueCost)
+	}
+	return nil
+}
+
+// untrack removes k from the eviction policy and cost accounting. It must be
+// called with c.mu held.
+func (c *cache[K, V]) untrack(k K) {
+	if c.policy != nil {
+		c.policy.remove(k)
+	}
+	if c.costFunc != nil {
+		c.addCost(-c.costs[k])
+		delete(c.costs, k)
+	}
+}
+
+// addCost adds delta to the cost of the cache, and to the total cost of the
+// sharded cache it is a shard of, if any. It must be called with c.mu held.
+func (c *cache[K, V]) addCost(delta int64) {
+	c.cost += delta
+	if c.totalCost != nil {
+		c.totalCost.Add(delta)
+	}
+}
+
+func (c *cache[K, V]) overCapacity() bool {
+	return (c.maxItems > 0 && len(c.items) > c.maxItems) ||
+		(c.maxCost > 0 && c.cost > c.maxCost)
+}
+
+// evictOverflow evicts items in the order chosen by the eviction policy until
+// the cache is back within its capacity. It must be called with c.mu held; the
+// returned items should be passed to notifyEvicted once the lock is released.
+func (c *cache[K, V]) evictOverflow() []keyAndValue[K, V] {
+	evictedItems := c.pending
+	c.pending = nil
+	evictedItems, _ = c.evictWhile(evictedItems, c.overCapacity)
+	return evictedItems
+}
+
+// evictWhile evicts items in the order chosen by the eviction policy for as
+// long as over returns true, and returns evictedItems with the evicted items
+// appended (if there is an eviction callback to pass them to) and the number
+// of items evicted. It must be called with c.mu held.
+func (c *cache[K, V]) evictWhile(evictedItems []keyAndValue[K, V], over func() bool) ([]keyAndValue[K, V], int) {
+	if c.policy == nil {
+		return evictedItems, 0
+	}
+	n := 0
+	var vetoed []K
+	for over() {
+		k, ok := c.policy.victim()
+		if !ok {
+			if len(vetoed) == 0 {
+				break
+			}
+			// Every candidate was vetoed, so evict them anyway, in
+			// the order they were vetoed in.
+			k, vetoed = vetoed[0], vetoed[1:]
+		} else if c.beforeEvict != nil && !c.beforeEvict(k, c.items[k].Object) {
+			c.policy.remove(k)
+			vetoed = append(vetoed, k)
+			continue
+		}
+		ov, _ := c.delete(k)
+		n++
+		c.stats.evicted(ReasonCapacity, 1)
+		if c.onEvicted != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov})
+		}
+	}
+	for _, k := range vetoed {
+		c.policy.insert(k)
+	}
+	return evictedItems, n
+}
+
+// notifyEvicted calls f, if set, for each of the evicted items.
+func notifyEvicted[K comparable, V any](f func(K, V, EvictionReason), items []keyAndValue[K, V], reason EvictionReason) {
+	if f == nil {
+		return
+	}
+	for _, v := range items {
+		f(v.key, v.value, reason)
+	}
+}
+
+// DeleteExpired deletes all expired items from the cache. It is what the
+// janitor runs at every cleanup interval, and can be called directly for
+// manual cleanup, e.g. by a cache created with a cleanup interval less than
+// one, which doesn't start a janitor goroutine at all. See also CleanupNow.
 func (c *cache[K, V]) DeleteExpired() {
+	c.deleteExpired(false)
+}
+
+// CleanupNow runs a sweep right away, as the janitor does: it deletes all
+// expired items, calls the OnCleanup hook, if set, and returns the number of
+// items deleted. It always sweeps the whole cache, even if the janitor sweeps
+// it incrementally (see WithCleanupBatchSize). Unlike DeleteExpired, it lets a
+// cache without a janitor be cleaned up on the caller's own schedule and still
+// report to OnCleanup.
+func (c *cache[K, V]) CleanupNow() int {
+	return c.sweep()
+}
+
+// sweep deletes all expired items, and calls the OnCleanup hook, if set, with
+// the number of items deleted and how long it took. It is run by CleanupNow,
+// and by the janitor unless the cache was created with WithCleanupBatchSize.
+func (c *cache[K, V]) sweep() int {
+	start := time.Now()
+	_, n := c.deleteExpired(false)
+	if f := c.onCleanup.Load(); f != nil {
+		(*f)(n, time.Since(start))
+	}
+	return n
+}
+
+// OnCleanup sets an (optional) function that is called by the janitor (or
+// CleanupNow) after each of its sweeps with the number of expired items it
+// deleted and how long the sweep took, e.g. to confirm that the janitor is
+// running. It is safe to call OnCleanup at any time, including while a sweep
+// is running. Set to nil to disable.
+func (c *cache[K, V]) OnCleanup(f func(removed int, duration time.Duration)) {
+	if f == nil {
+		c.onCleanup.Store(nil)
+		return
+	}
+	c.onCleanup.Store(&f)
+}
+
+// DeleteExpiredKeys deletes all expired items from the cache like
+// DeleteExpired, and returns the keys of the deleted items.
+func (c *cache[K, V]) DeleteExpiredKeys() []K {
+	keys, _ := c.deleteExpired(true)
+	return keys
+}
+
+// deleteExpired deletes all expired items, and returns their keys if withKeys
+// is set, and their number.
+func (c *cache[K, V]) deleteExpired(withKeys bool) ([]K, int) {
 	var evictedItems []keyAndValue[K, V]
-	now := time.Now().UnixNano()
+	var keys []K
+	var n uint64
+	now := c.now()
 	c.mu.Lock()
 	for k, v := range c.items {
 		// "Inlining" of expired
 		if v.Expiration > 0 && now > v.Expiration {
-			ov, evicted := c.delete(k)
-			if evicted {
-				evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov})
+			c.delete(k)
+			n++
+			if withKeys {
+				keys = append(keys, k)
 			}
+			if c.onEvicted != nil || c.onEvictedBatch != nil || c.onExpired != nil {
+				evictedItems = append(evictedItems, keyAndValue[K, V]{k, v.Object})
+			}
+		}
+	}
+	for k, e := range c.negatives {
+		if now > e {
+			delete(c.negatives, k)
 		}
 	}
+	f, bf, xf := c.onEvicted, c.onEvictedBatch, c.onExpired
 	c.mu.Unlock()
-	for _, v := range evictedItems {
-		c.onEvicted(v.key, v.value)
+	c.stats.evicted(ReasonExpired, n)
+	notifyExpired(f, bf, xf, evictedItems)
+	return keys, int(n)
+}
+
+// notifyExpired calls the eviction callback f and then the expiration callback
+// xf for each of the items removed by an expiration sweep, and then the batch
+// callback bf with all of them. Any of the callbacks may be nil.
+func notifyExpired[K comparable, V any](f func(K, V, EvictionReason), bf func([]Event[K, V]), xf func(K, V), items []keyAndValue[K, V]) {
+	notifyEvicted(f, items, ReasonExpired)
+	if xf != nil {
+		for _, v := range items {
+			xf(v.key, v.value)
+		}
+	}
+	if bf != nil && len(items) > 0 {
+		batch := make([]Event[K, V], len(items))
+		for i, v := range items {
+			batch[i] = Event[K, V]{Key: v.key, Value: v.value, Reason: ReasonExpired}
+		}
+		bf(batch)
+	}
+}
+
+// OnEvictedBatch sets an (optional) function that is called once per
+// expiration sweep (DeleteExpired, DeleteExpiredKeys or the janitor) with all
+// of the items the sweep removed, rather than once per item. It is not called
+// for sweeps that remove nothing, nor for evictions of any other reason, which
+// are only reported to the OnEvicted callback. If both callbacks are set, the
+// OnEvicted callback is called for each item first, followed by the batch
+// callback. The slice is not used by the cache afterwards, so f may retain
+// it. Set to nil to disable.
+func (c *cache[K, V]) OnEvictedBatch(f func([]Event[K, V])) {
+	if f != nil && c.decode != nil {
+		bf := f
+		f = func(events []Event[K, V]) {
+			for i := range events {
+				events[i].Value = c.decode(events[i].Value)
+			}
+			bf(events)
+		}
+	}
+	if bf, o := f, c.outbox; f != nil && o != nil {
+		f = func(events []Event[K, V]) {
+			o.push(func() { bf(events) })
+		}
 	}
+	c.mu.Lock()
+	c.onEvictedBatch = f
+	c.mu.Unlock()
 }
 
-// OnEvicted sets an (optional) function that is called with the key and value when an
-// item is evicted from the cache. (Including when it is deleted manually, but
-// not when it is overwritten.) Set to nil to disable.
-func (c *cache[K, V]) OnEvicted(f func(K, V)) {
+// OnExpired sets an (optional) function that is called with the key and value
+// of each item removed because it expired, whether by an expiration sweep
+// (DeleteExpired, CleanupNow or the janitor) or by a lookup that found it
+// expired. It is called exactly once per expired item, however many sweeps
+// and lookups race to remove it, and is not called for removals of any other
+// reason. If the eviction callback is also set, it is called first. f is
+// never called while the cache's lock is held, so it may safely call back
+// into the cache, e.g. to renew the item. Set to nil to disable.
+func (c *cache[K, V]) OnExpired(f func(k K, v V)) {
+	if f != nil && c.decode != nil {
+		xf := f
+		f = func(k K, v V) {
+			xf(k, c.decode(v))
+		}
+	}
+	if xf, o := f, c.outbox; f != nil && o != nil {
+		f = func(k K, v V) {
+			o.push(func() { xf(k, v) })
+		}
+	}
 	c.mu.Lock()
-	c.onEvicted = f
+	c.onExpired = f
 	c.mu.Unlock()
 }
 
-// Save writes the cache's items (using Gob) to an io.Writer.
+// OnBeforeEvict sets an (optional) function that can veto the eviction of an
+// item to bring the cache back within the limits of WithMaxItems, WithCost or
+// WithMaxTotalCost. It is called with the key and value of each candidate, in
+// the order the eviction policy picks them in; if it returns false the
+// candidate is skipped, and the next one is considered. Skipped items are then
+// treated as if they had just been stored, e.g. as the most recently used by
+// PolicyLRU. The limits are enforced regardless: if every candidate is vetoed,
+// they are evicted anyway, in the order they were vetoed in. Pin an item to
+// keep it whatever the limits.
 //
-// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
-// documentation for NewFrom().)
+// Unlike the eviction callback, f is called with the cache's write lock held,
+// so it must be fast and must not call back into the cache. It is not called
+// for removals of any other reason, nor for an item evicted as soon as it is
+// stored because its own cost is too large. Set to nil to disable.
+func (c *cache[K, V]) OnBeforeEvict(f func(k K, v V) bool) {
+	if f != nil && c.decode != nil {
+		bf := f
+		f = func(k K, v V) bool {
+			return bf(k, c.decode(v))
+		}
+	}
+	c.mu.Lock()
+	c.beforeEvict = f
+	c.mu.Unlock()
+}
+
+// OnEvicted sets an (optional) function that is called with the key, value and
+// reason when an item is evicted from the cache. (Including when it is deleted
+// manually, flushed or its value is replaced with Replace, but not when it is
+// overwritten with Set.) The function is never called while the cache's lock
+// is held, so it may safely call back into the cache. Set to nil to disable.
+func (c *cache[K, V]) OnEvicted(f func(K, V, EvictionReason)) {
+	c.mu.Lock()
+	c.evictedFunc = f
+	c.updateOnEvicted()
+	c.mu.Unlock()
+}
+
+// Save writes the cache's unexpired items (their keys, values and expiration
+// times) to an io.Writer using Gob. The values must be encodable by Gob: if V
+// is an interface type, the concrete types stored in the cache are registered
+// with gob.Register automatically, and types Gob can't handle, such as
+// channels and functions, make Save return an error.
 func (c *cache[K, V]) Save(w io.Writer) (err error) {
 	enc := gob.NewEncoder(w)
 	defer func() {
 		if x := recover(); x != nil {
-			err = fmt.Errorf("Error registering item types with Gob library")
+			err = fmt.Errorf("Error registering item types with Gob library: %v", x)
 		}
 	}()
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	for _, v := range c.items {
+	items := c.Items()
+	for _, v := range items {
 		gob.Register(v.Object)
 	}
-	err = enc.Encode(&c.items)
+	err = enc.Encode(&items)
 	return
 }
 
 // SaveFile saves the cache's items to the given filename, creating the file if it
 // doesn't exist, and overwriting it if it does.
-//
-// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
-// documentation for NewFrom().)
 func (c *cache[K, V]) SaveFile(fname string) error {
 	fp, err := os.Create(fname)
 	if err != nil {
@@ -340,32 +1194,36 @@ func (c *cache[K, V]) SaveFile(fname string) error {
 }
 
 // Load adds (Gob-serialized) cache items from an io.Reader, excluding any items with
-// keys that already exist (and haven't expired) in the current cache.
-//
-// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
-// documentation for NewFrom().)
+// keys that already exist (and haven't expired) in the current cache, and any
+// items that have expired since they were saved. When V is an interface type,
+// the concrete types of the saved values must have been registered with
+// gob.Register before calling Load.
 func (c *cache[K, V]) Load(r io.Reader) error {
 	dec := gob.NewDecoder(r)
 	items := map[K]Item[V]{}
 	err := dec.Decode(&items)
 	if err == nil {
+		now := c.now()
 		c.mu.Lock()
-		defer c.mu.Unlock()
 		for k, v := range items {
+			if v.Expiration > 0 && now > v.Expiration {
+				continue
+			}
 			ov, found := c.items[k]
-			if !found || ov.Expired() {
-				c.items[k] = v
+			if !found || (ov.Expiration > 0 && now > ov.Expiration) {
+				c.setItem(k, v.Object, v.Expiration)
 			}
 		}
+		evicted := c.evictOverflow()
+		f := c.onEvicted
+		c.mu.Unlock()
+		notifyEvicted(f, evicted, ReasonCapacity)
 	}
 	return err
 }
 
 // LoadFile loads and add cache items from the given filename, excluding any items with
 // keys that already exist in the current cache.
-//
-// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
-// documentation for NewFrom().)
 func (c *cache[K, V]) LoadFile(fname string) error {
 	fp, err := os.Open(fname)
 	if err != nil {
@@ -380,11 +1238,18 @@ func (c *cache[K, V]) LoadFile(fname string) error {
 }
 
 // Items copies all unexpired items in the cache into a new map and returns it.
+// The copy is taken under the read lock and is the caller's to modify; see
+// ItemsRef to avoid the copy.
 func (c *cache[K, V]) Items() map[K]Item[V] {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.copyItems()
+}
+
+// copyItems is Items for callers that hold c.mu already.
+func (c *cache[K, V]) copyItems() map[K]Item[V] {
 	m := make(map[K]Item[V], len(c.items))
-	now := time.Now().UnixNano()
+	now := c.now()
 	for k, v := range c.items {
 		// "Inlining" of Expired
 		if v.Expiration > 0 {
@@ -392,54 +1257,299 @@ func (c *cache[K, V]) Items() map[K]Item[V] {
 				continue
 			}
 		}
+		v.Object = c.decoded(v.Object)
 		m[k] = v
 	}
 	return m
 }
 
-// ItemCount returns the number of items in the cache. This may include items that have
-// expired, but have not yet been cleaned up.
+// Keys returns the keys of all unexpired items in the cache, in no particular
+// order. Like Items it is a point-in-time snapshot taken under the read lock,
+// but it doesn't copy the values, so it is much cheaper when only the keys are
+// needed. Items set or deleted afterwards are not reflected.
+func (c *cache[K, V]) Keys() []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.appendKeys(make([]K, 0, len(c.items)))
+}
+
+// appendKeys appends the keys of all unexpired items to keys. It must be called
+// with c.mu held.
+func (c *cache[K, V]) appendKeys(keys []K) []K {
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ItemsRef returns the cache's underlying items map, including expired items
+// that haven't been deleted yet, without copying it. Its values are stored as
+// they are encoded by WithCodec.
+//
+// Warning: the map is shared with the cache, which keeps modifying it. Reading
+// it while the cache is in use by other goroutines is a data race, and
+// modifying it bypasses the cache's bookkeeping (cost, eviction policy and
+// callbacks). Use Items unless the cache is known to be idle, e.g. right
+// before encoding it on shutdown.
+func (c *cache[K, V]) ItemsRef() map[K]Item[V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.items
+}
+
+// Range calls f for each unexpired item in the cache, in no particular order,
+// until f returns false. The cache's read lock is held for the duration of the
+// iteration, so f must not call back into the cache, or it may deadlock; use
+// Items to iterate over a copy instead.
+func (c *cache[K, V]) Range(f func(k K, v V) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 {
+			if now > v.Expiration {
+				continue
+			}
+		}
+		if !f(k, c.decoded(v.Object)) {
+			return
+		}
+	}
+}
+
+// ItemCount returns the number of unexpired items in the cache, i.e. the
+// number of items Get would find. It has to look at every item; see
+// ItemCountIncludingExpired for a constant-time alternative.
 func (c *cache[K, V]) ItemCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	n := 0
+	now := c.now()
+	for _, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// ItemCountIncludingExpired returns the number of items in the cache. This may
+// include items that have expired, but have not yet been cleaned up.
+func (c *cache[K, V]) ItemCountIncludingExpired() int {
 	c.mu.RLock()
 	n := len(c.items)
 	c.mu.RUnlock()
 	return n
 }
 
-// Flush deletes all items from the cache.
+// Cost returns the summed cost of the items in the cache, as computed by the
+// function given to WithCost. It is always 0 if no cost function was set. This
+// may include items that have expired, but have not yet been cleaned up.
+func (c *cache[K, V]) Cost() int64 {
+	c.mu.RLock()
+	n := c.cost
+	c.mu.RUnlock()
+	return n
+}
+
+// Flush deletes all items from the cache, passing each of them to the eviction
+// callback, if one is set, with ReasonFlushed.
 func (c *cache[K, V]) Flush() {
+	c.FlushWith(nil)
+}
+
+// FlushWith deletes all items from the cache like Flush, and also calls f, if
+// it isn't nil, with the key and value of each deleted item, including expired
+// ones. f and the eviction callback are called once the cache has been
+// emptied and its lock released, so they never observe a partially flushed
+// cache and may safely call back into it.
+func (c *cache[K, V]) FlushWith(f func(K, V)) {
 	c.mu.Lock()
-	c.items = map[K]Item[V]{}
+	items := c.clear()
+	ef := c.onEvicted
+	c.mu.Unlock()
+	c.stats.evicted(ReasonFlushed, uint64(len(items)))
+	if f == nil && ef == nil {
+		return
+	}
+	if ff, o := f, c.outbox; f != nil && o != nil {
+		f = func(k K, v V) {
+			o.push(func() { ff(k, v) })
+		}
+	}
+	for k, v := range items {
+		if f != nil {
+			f(k, c.decoded(v.Object))
+		}
+		if ef != nil {
+			ef(k, v.Object, ReasonFlushed)
+		}
+	}
+}
+
+// FlushExpirable deletes the items of the cache that are subject to
+// expiration or eviction, and returns how many it deleted. Items stored with
+// NoExpiration and pinned items that haven't expired are kept, so that a
+// cache can be dropped for a forced refresh without losing its permanent
+// entries. The eviction callback, if one is set, is called with ReasonFlushed
+// for the deleted items only, once the cache's lock has been released.
+func (c *cache[K, V]) FlushExpirable() int {
+	var flushed []keyAndValue[K, V]
+	n := 0
+	c.mu.Lock()
+	now := c.now()
+	for k, item := range c.items {
+		if item.Expiration == 0 {
+			continue
+		}
+		if _, pinned := c.pinned[k]; pinned && now <= item.Expiration {
+			continue
+		}
+		c.delete(k)
+		n++
+		if c.onEvicted != nil {
+			flushed = append(flushed, keyAndValue[K, V]{k, item.Object})
+		}
+	}
+	ef := c.onEvicted
 	c.mu.Unlock()
+	c.stats.evicted(ReasonFlushed, uint64(n))
+	notifyEvicted(ef, flushed, ReasonFlushed)
+	return n
+}
+
+// clear empties the cache and returns the items it held. It must be called
+// with c.mu held.
+func (c *cache[K, V]) clear() map[K]Item[V] {
+	items := c.items
+	c.items = map[K]Item[V]{}
+	c.peak = 0
+	if c.policy != nil {
+		c.policy.reset()
+	}
+	if c.costFunc != nil {
+		c.addCost(-c.cost)
+		c.costs = map[K]int64{}
+	}
+	c.negatives = nil
+	c.pinned = nil
+	c.untagAll()
+	if c.uses != nil {
+		c.uses = map[K]int{}
+	}
+	return items
+}
+
+// drain empties the cache and calls the WithDrainOnClose function with each
+// unexpired item it held.
+func (c *cache[K, V]) drain() {
+	c.drainItems(c.takeItems())
+}
+
+// takeItems empties the cache and returns the items it held, for drainItems.
+func (c *cache[K, V]) takeItems() map[K]Item[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.clear()
+}
+
+// drainItems calls the WithDrainOnClose function with each unexpired item of
+// items, taken from the cache by takeItems.
+func (c *cache[K, V]) drainItems(items map[K]Item[V]) {
+	now := c.now()
+	for k, v := range items {
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		c.cfg.drain(k, c.decoded(v.Object))
+	}
 }
 
 type janitor[K comparable, V any] struct {
 	Interval time.Duration
+	ticker   Ticker
 	stop     chan bool
+	done     chan struct{} // closed when Run returns
+	once     sync.Once
 }
 
 func (j *janitor[K, V]) Run(c *cache[K, V]) {
-	ticker := time.NewTicker(j.Interval)
+	defer close(j.done)
+	defer j.ticker.Stop()
+	if c.cfg.cleanupOnStart {
+		c.sweep()
+	}
 	for {
 		select {
-		case <-ticker.C:
-			c.DeleteExpired()
+		case <-j.ticker.C():
+			c.tick()
 		case <-j.stop:
-			ticker.Stop()
 			return
 		}
 	}
 }
 
+// close signals the janitor to stop, and waits until it has. It may be called
+// more than once.
+func (j *janitor[K, V]) close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
 func stopJanitor[K comparable, V any](c *Cache[K, V]) {
-	c.janitor.stop <- true
+	c.Close()
 }
 
 func runJanitor[K comparable, V any](c *cache[K, V], ci time.Duration) {
 	j := &janitor[K, V]{
 		Interval: ci,
-		stop:     make(chan bool),
+		// The ticker is created before the goroutine starts so that
+		// ticks of a fake clock can't be missed.
+		ticker: clockOrReal(c.clock).NewTicker(ci),
+		stop:   make(chan bool),
+		done:   make(chan struct{}),
 	}
 	c.janitor = j
 	go j.Run(c)
 }
+
+// Close stops the janitor goroutine, if the cache has one, and waits for it to
+// exit. It is safe to call Close more than once, and the cache remains usable
+// afterwards, but expired items are then only removed by DeleteExpired (or by
+// a janitor started again with SetCleanupInterval). If the cache was created
+// with WithDrainOnClose, the first Close empties it and drains its items
+// first, and concurrent calls wait for that to finish.
+func (c *cache[K, V]) Close() {
+	if c.cfg.drain != nil {
+		c.closeOnce.Do(c.drain)
+	}
+	c.SetCleanupInterval(0)
+}
+
+// SetCleanupInterval changes the interval at which the janitor deletes expired
+// items. If d is less than one, the janitor is stopped, as if by Close.
+// Otherwise the running janitor, if any, is stopped and waited for before a new
+// one is started with the interval d, so no two cleanups ever overlap. The
+// first cleanup happens d after SetCleanupInterval returns. Neither it nor
+// Close may be called from an eviction callback run by the janitor.
+func (c *cache[K, V]) SetCleanupInterval(d time.Duration) {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	if c.janitor != nil {
+		c.janitor.close()
+		c.janitor = nil
+	}
+	if d > 0 {
+		runJanitor(c, d)
+	}
+}
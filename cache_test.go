@@ -2,10 +2,17 @@ package ttlcache
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"io"
 	"io/ioutil"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -134,6 +141,116 @@ func TestNewFrom(t *testing.T) {
 	}
 }
 
+func TestKeys(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	for _, k := range []string{"a", "b", "c"} {
+		tc.Set(k, 1, DefaultExpiration)
+		sc.Set(k, 1, DefaultExpiration)
+	}
+	tc.Set("expired", 1, time.Nanosecond)
+	sc.Set("expired", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	for name, keys := range map[string][]string{"standard": tc.Keys(), "sharded": sc.Keys()} {
+		sort.Strings(keys)
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("%s: Keys returned %v, want %v", name, keys, want)
+		}
+	}
+}
+
+func TestMustGetAndGetOr(t *testing.T) {
+	tc := New[string, *int](DefaultExpiration, 0)
+	one := 1
+	tc.Set("one", &one, DefaultExpiration)
+	tc.Set("nil", nil, DefaultExpiration)
+	if v := tc.MustGet("one"); v != &one {
+		t.Error("MustGet didn't return the stored pointer")
+	}
+	if v := tc.MustGet("nil"); v != nil {
+		t.Error("MustGet didn't return the stored nil")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("MustGet of a missing key didn't panic")
+			}
+		}()
+		tc.MustGet("missing")
+	}()
+
+	two := 2
+	if v := tc.GetOr("missing", &two); v != &two {
+		t.Error("GetOr didn't return the fallback for a missing key")
+	}
+	if v := tc.GetOr("nil", &two); v != nil {
+		t.Error("GetOr returned the fallback for a stored nil")
+	}
+}
+
+func TestItemsCopy(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	items := tc.Items()
+	items["b"] = Item[int]{Object: 2}
+	delete(items, "a")
+	if !tc.Has("a") || tc.Has("b") {
+		t.Error("Modifying the map returned by Items changed the cache")
+	}
+
+	ref := tc.ItemsRef()
+	tc.Set("c", 3, DefaultExpiration)
+	if ref["c"].Object != 3 {
+		t.Error("ItemsRef didn't return the cache's underlying map")
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+	tc.SetDefault("a", 1)
+	if _, e, found := tc.GetWithExpiration("a"); !found || e.IsZero() || time.Until(e) > time.Minute {
+		t.Error("SetDefault didn't use the default expiration of a minute:", e, found)
+	}
+
+	tc = New[string, int](DefaultExpiration, 0)
+	tc.SetDefault("a", 1)
+	if _, e, found := tc.GetWithExpiration("a"); !found || !e.IsZero() {
+		t.Error("SetDefault set an expiration without a default:", e, found)
+	}
+}
+
+func TestSetNoExpireAndTTL(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+	sc := NewSharded[string, int](time.Minute, 0, 4)
+	tc.SetNoExpire("a", 1)
+	sc.SetNoExpire("a", 1)
+	if _, e, found := tc.GetWithExpiration("a"); !found || !e.IsZero() {
+		t.Error("SetNoExpire set an expiration:", e, found)
+	}
+	if _, e, found := sc.GetWithExpiration("a"); !found || !e.IsZero() {
+		t.Error("Sharded SetNoExpire set an expiration:", e, found)
+	}
+
+	tc = New[string, int](DefaultExpiration, 0)
+	tc.SetTTL("b", 2, time.Hour)
+	if _, e, found := tc.GetWithExpiration("b"); !found || time.Until(e) <= time.Minute {
+		t.Error("SetTTL didn't expire b in an hour:", e, found)
+	}
+	for _, ttl := range []time.Duration{DefaultExpiration, NoExpiration, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("SetTTL didn't panic with a TTL of", ttl)
+				}
+			}()
+			sc.SetTTL("c", 3, ttl)
+		}()
+	}
+	if _, found := sc.Get("c"); found {
+		t.Error("SetTTL stored c with a non-positive TTL")
+	}
+}
+
 func TestStorePointerToStruct(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	tc.Set("foo", &TestStruct{Num: 1}, DefaultExpiration)
@@ -154,6 +271,33 @@ func TestStorePointerToStruct(t *testing.T) {
 	}
 }
 
+func TestHas(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("expired", 3, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if !tc.Has("a") {
+		t.Error("a wasn't found")
+	}
+	if tc.Has("expired") || tc.Has("missing") {
+		t.Error("An expired or missing key was found")
+	}
+	if s := tc.Stats(); s.Hits != 0 || s.Misses != 0 {
+		t.Errorf("Has was counted in the stats: %+v", s)
+	}
+
+	// a is still the least recently used item, since Has doesn't count as
+	// a use.
+	tc = New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](2))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Has("a")
+	tc.Set("c", 3, DefaultExpiration)
+	if tc.Has("a") || !tc.Has("b") {
+		t.Error("Has changed the eviction order")
+	}
+}
+
 func TestAdd(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	err := tc.Add("foo", "bar", DefaultExpiration)
@@ -164,6 +308,173 @@ func TestAdd(t *testing.T) {
 	if err == nil {
 		t.Error("Successfully added another foo when it should have returned an error")
 	}
+	if !errors.Is(err, ErrKeyExists) {
+		t.Error("Add didn't return ErrKeyExists:", err)
+	}
+}
+
+func TestSetIfAbsent(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	if !tc.SetIfAbsent("foo", "bar", DefaultExpiration) {
+		t.Error("Couldn't set foo even though it shouldn't exist")
+	}
+	if tc.SetIfAbsent("foo", "baz", DefaultExpiration) {
+		t.Error("Set another foo when it already existed")
+	}
+	if x, _ := tc.Get("foo"); x != "bar" {
+		t.Error("foo was overwritten:", x)
+	}
+	tc.Set("expired", "a", time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if !tc.SetIfAbsent("expired", "b", DefaultExpiration) {
+		t.Error("Couldn't set expired even though the existing item has expired")
+	}
+}
+
+func TestSetIfPresent(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	sc := NewSharded[string, string](DefaultExpiration, 0, 4)
+	for name, c := range map[string]interface {
+		Set(string, string, time.Duration)
+		Get(string) (string, bool)
+		SetIfPresent(string, string, time.Duration) bool
+	}{"standard": tc, "sharded": sc} {
+		if c.SetIfPresent("foo", "bar", DefaultExpiration) {
+			t.Errorf("%s: set foo even though it doesn't exist", name)
+		}
+		if _, found := c.Get("foo"); found {
+			t.Errorf("%s: foo was stored by SetIfPresent", name)
+		}
+		c.Set("foo", "bar", DefaultExpiration)
+		if !c.SetIfPresent("foo", "baz", DefaultExpiration) {
+			t.Errorf("%s: couldn't set foo even though it exists", name)
+		}
+		if x, _ := c.Get("foo"); x != "baz" {
+			t.Errorf("%s: foo wasn't updated: %s", name, x)
+		}
+		c.Set("expired", "a", time.Nanosecond)
+		<-time.After(time.Millisecond)
+		if c.SetIfPresent("expired", "b", DefaultExpiration) {
+			t.Errorf("%s: set expired even though the existing item has expired", name)
+		}
+	}
+}
+
+func TestCodec(t *testing.T) {
+	encode := func(v []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(v)
+		w.Close()
+		return buf.Bytes()
+	}
+	decode := func(v []byte) []byte {
+		r, err := gzip.NewReader(bytes.NewReader(v))
+		if err != nil {
+			t.Fatal("Couldn't decompress a value:", err)
+		}
+		b, _ := io.ReadAll(r)
+		return b
+	}
+	tc := New[string, []byte](DefaultExpiration, 0, WithCodec[string, []byte](encode, decode))
+	sc := NewSharded[string, []byte](DefaultExpiration, 0, 4, WithCodec[string, []byte](encode, decode))
+	data := bytes.Repeat([]byte("ttlcache"), 100)
+	tc.Set("a", data, DefaultExpiration)
+	sc.SetDefault("a", data)
+
+	if stored := tc.ItemsRef()["a"].Object; len(stored) >= len(data) {
+		t.Error("The value wasn't compressed when stored:", len(stored))
+	}
+	if x, found := tc.Get("a"); !found || !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by Get")
+	}
+	if x := sc.GetOr("a", nil); !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by the sharded GetOr")
+	}
+	if x := sc.AllItems()["a"].Object; !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by the sharded AllItems")
+	}
+	for _, m := range sc.ItemsRef() {
+		if item, found := m["a"]; found && len(item.Object) >= len(data) {
+			t.Error("The sharded value wasn't compressed when stored:", len(item.Object))
+		}
+	}
+	if _, found := tc.Get("missing"); found {
+		t.Error("A missing key was found")
+	}
+
+	if err := tc.Add("b", data, DefaultExpiration); err != nil {
+		t.Fatal("Couldn't add b:", err)
+	}
+	if stored := tc.ItemsRef()["b"].Object; len(stored) >= len(data) {
+		t.Error("The value wasn't compressed when added:", len(stored))
+	}
+	if x, found := tc.Get("b"); !found || !bytes.Equal(x, data) {
+		t.Error("The added value didn't round-trip through Get")
+	}
+	if x, _, found := tc.GetWithExpiration("b"); !found || !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by GetWithExpiration")
+	}
+	if x := tc.GetMany([]string{"b"})["b"]; !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by GetMany")
+	}
+	if x := tc.Items()["b"].Object; !bytes.Equal(x, data) {
+		t.Error("The value wasn't decompressed by Items")
+	}
+	if old, _ := tc.Swap("b", data, DefaultExpiration); !bytes.Equal(old, data) {
+		t.Error("The old value wasn't decompressed by Swap")
+	}
+	var evicted []byte
+	tc.OnEvicted(func(_ string, v []byte, _ EvictionReason) {
+		evicted = v
+	})
+	tc.Delete("b")
+	if !bytes.Equal(evicted, data) {
+		t.Error("The value wasn't decompressed for the eviction callback")
+	}
+
+	plain := New[string, []byte](DefaultExpiration, 0, WithCodec[string, []byte](nil, nil))
+	plain.Set("a", data, DefaultExpiration)
+	if x, _ := plain.Get("a"); !bytes.Equal(x, data) {
+		t.Error("Nil codec functions changed the value")
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	if v, loaded := tc.GetOrSet("foo", "bar", DefaultExpiration); loaded || v != "bar" {
+		t.Errorf("GetOrSet on a missing key returned %q, %v", v, loaded)
+	}
+	if v, loaded := tc.GetOrSet("foo", "baz", DefaultExpiration); !loaded || v != "bar" {
+		t.Errorf("GetOrSet on an existing key returned %q, %v", v, loaded)
+	}
+	tc.Set("expired", "a", time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if v, loaded := tc.GetOrSet("expired", "b", DefaultExpiration); loaded || v != "b" {
+		t.Errorf("GetOrSet on an expired key returned %q, %v", v, loaded)
+	}
+	if s := tc.Stats(); s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("Unexpected stats after GetOrSet: %+v", s)
+	}
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	var stored atomic.Int32
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, loaded := tc.GetOrSet("foo", i, DefaultExpiration); !loaded {
+				stored.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := stored.Load(); n != 1 {
+		t.Errorf("GetOrSet stored %d values instead of 1", n)
+	}
 }
 
 func TestReplace(t *testing.T) {
@@ -172,6 +483,9 @@ func TestReplace(t *testing.T) {
 	if err == nil {
 		t.Error("Replaced foo when it shouldn't exist")
 	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Error("Replace didn't return ErrKeyNotFound:", err)
+	}
 	tc.Set("foo", "bar", DefaultExpiration)
 	err = tc.Replace("foo", "bar", DefaultExpiration)
 	if err != nil {
@@ -202,6 +516,19 @@ func TestItemCount(t *testing.T) {
 	}
 }
 
+func TestItemCountExcludesExpired(t *testing.T) {
+	tc := New[string, interface{}](DefaultExpiration, 0)
+	tc.Set("foo", "1", DefaultExpiration)
+	tc.Set("bar", "2", time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is not 1: %d", n)
+	}
+	if n := tc.ItemCountIncludingExpired(); n != 2 {
+		t.Errorf("Item count including expired items is not 2: %d", n)
+	}
+}
+
 func TestFlush(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	tc.Set("foo", "bar", DefaultExpiration)
@@ -223,6 +550,128 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestDeleteExpiredKeys(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("foo", 1, DefaultExpiration)
+	tc.Set("bar", 2, time.Nanosecond)
+	tc.Set("baz", 3, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	keys := tc.DeleteExpiredKeys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "bar" || keys[1] != "baz" {
+		t.Error("Unexpected expired keys:", keys)
+	}
+	if keys := tc.DeleteExpiredKeys(); len(keys) != 0 {
+		t.Error("Keys were deleted a second time:", keys)
+	}
+}
+
+func TestOnEvictedBatch(t *testing.T) {
+	tc := New[int, int](DefaultExpiration, 0)
+	var calls []string
+	tc.OnEvicted(func(k int, v int, reason EvictionReason) {
+		calls = append(calls, "item")
+	})
+	var batches [][]Event[int, int]
+	tc.OnEvictedBatch(func(batch []Event[int, int]) {
+		calls = append(calls, "batch")
+		batches = append(batches, batch)
+	})
+	for i := 0; i < 3; i++ {
+		tc.Set(i, i, time.Nanosecond)
+	}
+	tc.Set(3, 3, DefaultExpiration)
+	<-time.After(time.Millisecond)
+	tc.DeleteExpired()
+	tc.DeleteExpired()
+	tc.Delete(3)
+
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatal("Unexpected batches:", batches)
+	}
+	for _, e := range batches[0] {
+		if e.Key != e.Value || e.Key > 2 || e.Reason != ReasonExpired {
+			t.Error("Unexpected event in the batch:", e)
+		}
+	}
+	want := []string{"item", "item", "item", "batch", "item"}
+	if len(calls) != len(want) {
+		t.Fatal("Unexpected callback order:", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatal("Unexpected callback order:", calls)
+		}
+	}
+}
+
+func TestFlushWith(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("foo", 1, DefaultExpiration)
+	tc.Set("bar", 2, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	evicted := map[string]EvictionReason{}
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted[k] = reason
+	})
+	flushed := map[string]int{}
+	tc.FlushWith(func(k string, v int) {
+		if n := tc.ItemCountIncludingExpired(); n != 0 {
+			t.Errorf("FlushWith called f with %d items still in the cache", n)
+		}
+		flushed[k] = v
+	})
+	if len(flushed) != 2 || flushed["foo"] != 1 || flushed["bar"] != 2 {
+		t.Error("FlushWith didn't call f with every item:", flushed)
+	}
+	if len(evicted) != 2 || evicted["foo"] != ReasonFlushed || evicted["bar"] != ReasonFlushed {
+		t.Error("FlushWith didn't call the eviction callback with every item:", evicted)
+	}
+
+	tc.Set("baz", 3, DefaultExpiration)
+	tc.Flush()
+	if evicted["baz"] != ReasonFlushed {
+		t.Error("Flush didn't call the eviction callback")
+	}
+	if n := tc.Stats().Evictions[ReasonFlushed]; n != 3 {
+		t.Errorf("%d flushed items were counted instead of 3", n)
+	}
+}
+
+func TestFlushExpirable(t *testing.T) {
+	tc := New[string, int](time.Hour, 0)
+	tc.Set("permanent", 1, NoExpiration)
+	tc.Set("pinned", 2, DefaultExpiration)
+	tc.Pin("pinned")
+	tc.Set("expiring", 3, DefaultExpiration)
+	tc.Set("pinnedExpired", 4, time.Nanosecond)
+	tc.Pin("pinnedExpired")
+	<-time.After(time.Millisecond)
+
+	evicted := map[string]EvictionReason{}
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted[k] = reason
+	})
+	if n := tc.FlushExpirable(); n != 2 {
+		t.Error("FlushExpirable deleted", n, "items instead of 2")
+	}
+	if len(evicted) != 2 || evicted["expiring"] != ReasonFlushed || evicted["pinnedExpired"] != ReasonFlushed {
+		t.Error("FlushExpirable didn't call the eviction callback with the deleted items only:", evicted)
+	}
+	for _, k := range []string{"permanent", "pinned"} {
+		if _, found := tc.Get(k); !found {
+			t.Error(k, "was deleted by FlushExpirable")
+		}
+	}
+	if tc.ItemCountIncludingExpired() != 2 {
+		t.Error("Unexpected items are left after FlushExpirable:", tc.Keys())
+	}
+	if n := tc.Stats().Evictions[ReasonFlushed]; n != 2 {
+		t.Errorf("%d flushed items were counted instead of 2", n)
+	}
+}
+
 func TestOnEvicted(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	tc.Set("foo", 3, DefaultExpiration)
@@ -230,8 +679,8 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatal("tc.onEvicted is not nil")
 	}
 	works := false
-	tc.OnEvicted(func(k string, v interface{}) {
-		if k == "foo" && v.(int) == 3 {
+	tc.OnEvicted(func(k string, v interface{}, reason EvictionReason) {
+		if k == "foo" && v.(int) == 3 && reason == ReasonDeleted {
 			works = true
 		}
 		tc.Set("bar", 4, DefaultExpiration)
@@ -246,6 +695,97 @@ func TestOnEvicted(t *testing.T) {
 	}
 }
 
+func TestOnEvictedReason(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	reasons := map[string]EvictionReason{}
+	values := map[string]int{}
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		reasons[k] = reason
+		values[k] = v
+		// Calling back into the cache must not deadlock.
+		tc.Get(k)
+	})
+	tc.Set("deleted", 1, DefaultExpiration)
+	tc.Set("replaced", 2, DefaultExpiration)
+	tc.Set("expired", 3, time.Nanosecond)
+	tc.Delete("deleted")
+	tc.Replace("replaced", 20, DefaultExpiration)
+	<-time.After(time.Millisecond)
+	tc.DeleteExpired()
+
+	want := map[string]EvictionReason{
+		"deleted":  ReasonDeleted,
+		"replaced": ReasonReplaced,
+		"expired":  ReasonExpired,
+	}
+	for k, r := range want {
+		if reasons[k] != r {
+			t.Errorf("%s was evicted with reason %v instead of %v", k, reasons[k], r)
+		}
+	}
+	if values["replaced"] != 2 {
+		t.Error("replaced was not evicted with its old value:", values["replaced"])
+	}
+}
+
+func TestOnExpired(t *testing.T) {
+	caches := map[string]interface {
+		Set(string, int, time.Duration)
+		Get(string) (int, bool)
+		Delete(string)
+		DeleteExpired()
+		OnExpired(func(string, int))
+	}{
+		"standard": New[string, int](DefaultExpiration, 0),
+		"sharded":  NewSharded[string, int](DefaultExpiration, 0, 4),
+	}
+	for name, tc := range caches {
+		var mu sync.Mutex
+		expired := map[string]int{}
+		tc.OnExpired(func(k string, v int) {
+			mu.Lock()
+			expired[k]++
+			mu.Unlock()
+		})
+		tc.Set("deleted", 1, time.Nanosecond)
+		tc.Delete("deleted")
+		tc.Set("lazy", 2, time.Nanosecond)
+		for _, k := range shardedKeys {
+			tc.Set(k, 3, time.Nanosecond)
+		}
+		<-time.After(time.Millisecond)
+		if _, found := tc.Get("lazy"); found {
+			t.Errorf("%s: lazy was found after it expired", name)
+		}
+
+		// Sweeps and lookups racing to remove the same items must report each
+		// of them once.
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				tc.DeleteExpired()
+			}()
+			go func() {
+				defer wg.Done()
+				for _, k := range shardedKeys {
+					tc.Get(k)
+				}
+			}()
+		}
+		wg.Wait()
+		if len(expired) != len(shardedKeys)+1 || expired["lazy"] != 1 {
+			t.Errorf("%s: unexpected expirations: %v", name, expired)
+		}
+		for k, n := range expired {
+			if n != 1 {
+				t.Errorf("%s: the expiration of %s was reported %d times", name, k, n)
+			}
+		}
+	}
+}
+
 func TestCacheSerialization(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	testFillAndSerialize(t, tc)
@@ -373,6 +913,36 @@ func testFillAndSerialize(t *testing.T, tc *Cache[string, interface{}]) {
 	}
 }
 
+func TestSerializationSkipsExpired(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("live", 1, DefaultExpiration)
+	tc.Set("expired", 2, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	fp := &bytes.Buffer{}
+	if err := tc.Save(fp); err != nil {
+		t.Fatal("Couldn't save cache to fp:", err)
+	}
+
+	items := map[string]Item[int]{}
+	if err := gob.NewDecoder(bytes.NewReader(fp.Bytes())).Decode(&items); err != nil {
+		t.Fatal("Couldn't decode saved items:", err)
+	}
+	if _, found := items["expired"]; found {
+		t.Error("expired was saved")
+	}
+
+	oc := New[string, int](DefaultExpiration, 0)
+	if err := oc.Load(fp); err != nil {
+		t.Fatal("Couldn't load cache from fp:", err)
+	}
+	if x, found := oc.Get("live"); !found || x != 1 {
+		t.Error("live was not loaded")
+	}
+	if n := oc.ItemCount(); n != 1 {
+		t.Errorf("Item count is not 1 after loading: %d", n)
+	}
+}
+
 func TestFileSerialization(t *testing.T) {
 	tc := New[string, interface{}](DefaultExpiration, 0)
 	tc.Add("a", "a", DefaultExpiration)
@@ -758,3 +1328,287 @@ func TestGetWithExpiration(t *testing.T) {
 		t.Error("expiration for e is in the past")
 	}
 }
+
+func TestRange(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	seen := map[string]int{}
+	tc.Range(func(k string, v int) bool {
+		seen[k] = v
+		return true
+	})
+	if len(seen) != 2 || seen["a"] != 1 || seen["b"] != 2 {
+		t.Error("Range did not visit exactly the unexpired items:", seen)
+	}
+
+	n := 0
+	tc.Range(func(k string, v int) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Error("Range did not stop when f returned false; calls:", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, time.Millisecond)
+	tc.Set("a", 1, DefaultExpiration)
+	j := tc.janitor
+	tc.Close()
+	tc.Close()
+	select {
+	case <-j.done:
+	default:
+		t.Error("The janitor is still running after Close")
+	}
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Error("The cache is not usable after Close")
+	}
+
+	// A cache without a janitor can be closed too.
+	New[string, int](DefaultExpiration, 0).Close()
+}
+
+func TestDrainOnClose(t *testing.T) {
+	var mu sync.Mutex
+	drained := map[string]int{}
+	drain := WithDrainOnClose[string, int](func(k string, v int) {
+		mu.Lock()
+		drained[k] += v
+		mu.Unlock()
+	})
+	tc := New[string, int](DefaultExpiration, time.Millisecond, drain)
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4, drain)
+	var evicted int32
+	tc.OnEvicted(func(string, int, EvictionReason) {
+		atomic.AddInt32(&evicted, 1)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("expired", 100, time.Nanosecond)
+	sc.Set("c", 3, DefaultExpiration)
+	sc.Set("d", 4, DefaultExpiration)
+	time.Sleep(time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tc.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			sc.Close()
+		}()
+	}
+	wg.Wait()
+	want := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}
+	if !reflect.DeepEqual(drained, want) {
+		t.Errorf("Drained %v, want %v", drained, want)
+	}
+	if tc.ItemCount() != 0 || sc.ItemCount() != 0 {
+		t.Error("Items are left after draining")
+	}
+	if n := atomic.LoadInt32(&evicted); n > 1 {
+		t.Error("The eviction callback was called for drained items:", n)
+	}
+
+	tc.Set("e", 5, DefaultExpiration)
+	tc.Close()
+	if _, found := drained["e"]; found {
+		t.Error("The cache was drained twice")
+	}
+	if x, found := tc.Get("e"); !found || x != 5 {
+		t.Error("The cache is not usable after being drained")
+	}
+}
+
+func TestZeroDefaultExpiration(t *testing.T) {
+	for name, de := range map[string]time.Duration{
+		"DefaultExpiration": DefaultExpiration,
+		"NoExpiration":      NoExpiration,
+		"negative":          -time.Hour,
+	} {
+		caches := map[string]*cache[string, int]{
+			"New":        New[string, int](de, 0).cache,
+			"NewFrom":    NewFrom[string, int](de, 0, map[string]Item[int]{}).cache,
+			"NewSharded": NewSharded[string, int](de, 0, 2).cs[0],
+		}
+		for cname, c := range caches {
+			if c.defaultExpiration != NoExpiration {
+				t.Errorf("%s with %s: default expiration is %v, not NoExpiration", cname, name, c.defaultExpiration)
+			}
+			c.Set("a", 1, DefaultExpiration)
+			if _, expiration, _ := c.GetWithExpiration("a"); !expiration.IsZero() {
+				t.Errorf("%s with %s: an item stored with DefaultExpiration expires at %v", cname, name, expiration)
+			}
+		}
+	}
+}
+
+func TestSetWithUses(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	var reasons []EvictionReason
+	tc.OnEvicted(func(k string, v string, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	})
+	tc.SetWithUses("token", "secret", 3)
+	for i := 0; i < 3; i++ {
+		if x, found := tc.Get("token"); !found || x != "secret" {
+			t.Fatalf("Read %d of token failed", i+1)
+		}
+		if i < 2 && len(reasons) != 0 {
+			t.Fatalf("token was evicted after %d reads", i+1)
+		}
+	}
+	if tc.Has("token") {
+		t.Error("token is still in the cache after its last read")
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonExhausted {
+		t.Error("Unexpected evictions:", reasons)
+	}
+	if n := tc.Stats().Evictions[ReasonExhausted]; n != 1 {
+		t.Errorf("%d exhausted items were counted instead of 1", n)
+	}
+
+	// Has doesn't use up reads, and a plain Set lifts the limit.
+	tc.SetWithUses("a", "1", 1)
+	tc.Has("a")
+	tc.Set("a", "2", DefaultExpiration)
+	tc.Get("a")
+	tc.Get("a")
+	if !tc.Has("a") {
+		t.Error("a was evicted even though Set lifted its limit")
+	}
+}
+
+func TestSetWithUsesExpiration(t *testing.T) {
+	tc := New[string, string](time.Nanosecond, 0)
+	tc.SetWithUses("token", "secret", 100)
+	<-time.After(time.Millisecond)
+	if _, found := tc.Get("token"); found {
+		t.Error("token was found after it expired")
+	}
+}
+
+func TestSetWithUsesReads(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	reads := map[string]func(){
+		"GetWithTTL":    func() { tc.GetWithTTL("token") },
+		"GetAndRefresh": func() { tc.GetAndRefresh("token", DefaultExpiration) },
+		"GetOrSet":      func() { tc.GetOrSet("token", "other", DefaultExpiration) },
+	}
+	for name, read := range reads {
+		tc.SetWithUses("token", "secret", 2)
+		read()
+		read()
+		if tc.Has("token") {
+			t.Error(name, "doesn't use up the reads of an item stored with SetWithUses")
+		}
+	}
+}
+
+func TestLoadReplacesUses(t *testing.T) {
+	src := New[string, string](DefaultExpiration, 0)
+	src.Set("token", "new", DefaultExpiration)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal("Couldn't save the cache:", err)
+	}
+	tc := New[string, string](DefaultExpiration, 0)
+	tc.SetWithUses("token", "old", 1)
+	tc.ExpireAt("token", time.Now().Add(-time.Second))
+	if err := tc.Load(&buf); err != nil {
+		t.Fatal("Couldn't load the cache:", err)
+	}
+	tc.Get("token")
+	if x, found := tc.Get("token"); !found || x != "new" {
+		t.Error("The loaded token was removed by the read limit of the expired one:", x, found)
+	}
+}
+
+func TestSetWithUsesRenameKey(t *testing.T) {
+	tc := NewSharded[string, string](DefaultExpiration, 0, 13)
+	tc.SetWithUses("tmp", "secret", 2)
+	tc.Get("tmp")
+	tc.RenameKey("tmp", "token")
+	tc.Get("token")
+	if tc.Has("token") {
+		t.Error("token kept its value beyond the reads left before renaming")
+	}
+}
+
+// janitorOfCollected returns the janitor of a cache created with opts that has
+// been garbage collected, and whether the janitor stopped within wait.
+func janitorOfCollected(wait time.Duration, opts ...Option[string, int]) (*janitor[string, int], bool) {
+	j := func() *janitor[string, int] {
+		tc := New[string, int](DefaultExpiration, time.Hour, opts...)
+		return tc.janitor
+	}()
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		select {
+		case <-j.done:
+			return j, true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return j, false
+}
+
+func TestFinalizer(t *testing.T) {
+	if _, stopped := janitorOfCollected(time.Second); !stopped {
+		t.Error("The janitor of a collected cache wasn't stopped by the finalizer")
+	}
+}
+
+func TestWithoutFinalizer(t *testing.T) {
+	j, stopped := janitorOfCollected(100*time.Millisecond, WithoutFinalizer[string, int]())
+	if stopped {
+		t.Error("The janitor was stopped without a finalizer")
+	}
+	j.close()
+}
+
+func TestDeleteOnGet(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		for name, opts := range map[string][]Option[string, int]{
+			"unbounded": {WithDeleteOnGet[string, int](enabled)},
+			"bounded":   {WithDeleteOnGet[string, int](enabled), WithMaxItems[string, int](10)},
+		} {
+			tc := New[string, int](DefaultExpiration, 0, opts...)
+			var reasons []EvictionReason
+			tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+				reasons = append(reasons, reason)
+			})
+			tc.Set("a", 1, time.Nanosecond)
+			tc.Set("b", 2, time.Nanosecond)
+			<-time.After(time.Millisecond)
+			tc.Get("a")
+			tc.GetWithExpiration("b")
+
+			want := 0
+			if enabled {
+				want = 2
+			}
+			if n := tc.ItemCountIncludingExpired(); n != 2-want {
+				t.Errorf("%s, enabled %v: %d items are left instead of %d", name, enabled, n, 2-want)
+			}
+			if len(reasons) != want {
+				t.Errorf("%s, enabled %v: unexpected evictions %v", name, enabled, reasons)
+			}
+			for _, r := range reasons {
+				if r != ReasonExpired {
+					t.Errorf("%s: an item was evicted with reason %v", name, r)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ttlcache
+
+import (
+	"time"
+)
+
+// swapper is implemented by both *Cache and *ShardedCache.
+type swapper[K comparable, V any] interface {
+	// swapIf sets x, with the given duration, as the value of item k if k
+	// is found (and hasn't expired) and cond returns true for its current
+	// item, all under the lock guarding k. It reports whether x was set.
+	swapIf(k K, x V, d time.Duration, cond func(Item[V]) bool) bool
+}
+
+// CompareAndSwap sets new as the value of item k, with the given duration,
+// only if k is in the cache, hasn't expired, and its current value equals old.
+// It reports whether the swap happened. c may be a *Cache or a *ShardedCache
+// whose values are comparable. As with Replace, the old value is passed to the
+// eviction callback with ReasonReplaced.
+func CompareAndSwap[K comparable, V comparable](c swapper[K, V], k K, old, new V, d time.Duration) bool {
+	return c.swapIf(k, new, d, func(item Item[V]) bool {
+		return item.Object == old
+	})
+}
+
+func (c *cache[K, V]) swapIf(k K, x V, d time.Duration, cond func(Item[V]) bool) bool {
+	c.mu.Lock()
+	ov, found := c.get(k)
+	if !found {
+		c.mu.Unlock()
+		return false
+	}
+	item := c.items[k]
+	item.Object = c.decoded(item.Object)
+	if !cond(item) {
+		c.mu.Unlock()
+		return false
+	}
+	c.set(k, x, d)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	c.stats.evicted(ReasonReplaced, 1)
+	if f != nil {
+		f(k, ov, ReasonReplaced)
+	}
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return true
+}
+
+func (sc *shardedCache[K, V]) swapIf(k K, x V, d time.Duration, cond func(Item[V]) bool) bool {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).swapIf(k, x, d, cond)
+}
@@ -0,0 +1,66 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if CompareAndSwap(tc, "foo", 0, 1, DefaultExpiration) {
+		t.Error("Swapped foo when it doesn't exist")
+	}
+	tc.Set("foo", 1, DefaultExpiration)
+	if CompareAndSwap(tc, "foo", 2, 3, DefaultExpiration) {
+		t.Error("Swapped foo when its value doesn't match")
+	}
+	if !CompareAndSwap(tc, "foo", 1, 2, DefaultExpiration) {
+		t.Error("Couldn't swap foo when its value matches")
+	}
+	if x, _ := tc.Get("foo"); x != 2 {
+		t.Error("foo is not 2:", x)
+	}
+
+	tc.Set("expired", 1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if CompareAndSwap(tc, "expired", 1, 2, DefaultExpiration) {
+		t.Error("Swapped expired when it has expired")
+	}
+}
+
+func TestCompareAndSwapOnEvicted(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("foo", 1, DefaultExpiration)
+	var reason EvictionReason
+	var old int
+	tc.OnEvicted(func(k string, v int, r EvictionReason) {
+		old, reason = v, r
+	})
+	CompareAndSwap(tc, "foo", 1, 2, DefaultExpiration)
+	if reason != ReasonReplaced || old != 1 {
+		t.Errorf("Eviction callback got %d with %v", old, reason)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	tc.Set("foo", 0, DefaultExpiration)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, _ := tc.Get("foo")
+				if CompareAndSwap(tc, "foo", v, v+1, DefaultExpiration) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("foo"); x != 50 {
+		t.Error("foo is not 50:", x)
+	}
+}
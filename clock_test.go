@@ -0,0 +1,361 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called. Tickers
+// created by it tick whenever Advance moves the time past their next tick.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	d       time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), d: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(c.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestFakeClockExpiration(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, 2*time.Minute)
+	tc.Set("c", 3, NoExpiration)
+
+	clock.Advance(59 * time.Second)
+	if _, found := tc.Get("a"); !found {
+		t.Error("a expired before its expiration time")
+	}
+	_, expiration, _ := tc.GetWithExpiration("b")
+	if want := clock.Now().Add(61 * time.Second); !expiration.Equal(want) {
+		t.Errorf("Expiration of b is %v, not %v", expiration, want)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, found := tc.Get("a"); found {
+		t.Error("a was found after its expiration time")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b expired before its expiration time")
+	}
+
+	clock.Advance(time.Hour)
+	tc.DeleteExpired()
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is not 1: %d", n)
+	}
+}
+
+func TestFakeClockJanitor(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, time.Minute, WithClock[string, int](clock))
+	evicted := make(chan string, 1)
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted <- k
+	})
+	tc.Set("a", 1, DefaultExpiration)
+
+	clock.Advance(2 * time.Minute)
+	select {
+	case k := <-evicted:
+		if k != "a" {
+			t.Error("Unexpected eviction:", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The janitor didn't run when the fake clock ticked")
+	}
+}
+
+func TestShardedFakeClockJanitor(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, time.Minute, 4, WithClock[string, int](clock))
+	evicted := make(chan string, len(shardedKeys))
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted <- k
+	})
+	for i, k := range shardedKeys {
+		tc.Set(k, i, DefaultExpiration)
+	}
+
+	clock.Advance(2 * time.Minute)
+	for range shardedKeys {
+		select {
+		case <-evicted:
+		case <-time.After(time.Second):
+			t.Fatal("The janitor didn't run when the fake clock ticked")
+		}
+	}
+}
+
+func TestSetCleanupInterval(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	evicted := make(chan string, 1)
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted <- k
+	})
+	tc.Set("a", 1, DefaultExpiration)
+
+	tc.SetCleanupInterval(time.Minute)
+	clock.Advance(2 * time.Minute)
+	select {
+	case <-evicted:
+	case <-time.After(time.Second):
+		t.Fatal("The janitor didn't run after enabling cleanup")
+	}
+
+	tc.SetCleanupInterval(time.Hour)
+	tc.Set("b", 2, DefaultExpiration)
+	clock.Advance(2 * time.Minute)
+	select {
+	case k := <-evicted:
+		t.Fatal("The janitor ran at the old interval and evicted", k)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Hour)
+	select {
+	case <-evicted:
+	case <-time.After(time.Second):
+		t.Fatal("The janitor didn't run at the new interval")
+	}
+
+	tc.SetCleanupInterval(0)
+	if tc.janitor != nil {
+		t.Error("The janitor wasn't stopped by a zero cleanup interval")
+	}
+	tc.Set("c", 3, DefaultExpiration)
+	clock.Advance(2 * time.Hour)
+	select {
+	case k := <-evicted:
+		t.Fatal("The janitor ran after cleanup was disabled and evicted", k)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestShardedSetCleanupInterval(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, time.Hour, 4, WithClock[string, int](clock))
+	evicted := make(chan string, 1)
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted <- k
+	})
+	tc.Set("a", 1, DefaultExpiration)
+
+	tc.SetCleanupInterval(time.Minute)
+	clock.Advance(2 * time.Minute)
+	select {
+	case <-evicted:
+	case <-time.After(time.Second):
+		t.Fatal("The janitor didn't run at the new interval")
+	}
+	tc.SetCleanupInterval(-1)
+	if tc.janitor != nil {
+		t.Error("The janitor wasn't stopped by a negative cleanup interval")
+	}
+}
+
+func TestJanitorStopsTicker(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, time.Minute, WithClock[string, int](clock))
+	sc := NewSharded[string, int](time.Minute, time.Minute, 4, WithClock[string, int](clock))
+	tc.Close()
+	sc.Close()
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	for i, ticker := range clock.tickers {
+		if !ticker.stopped {
+			t.Errorf("Ticker %d is still running after its janitor exited", i)
+		}
+	}
+}
+
+func TestOnCleanup(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, time.Minute, WithClock[string, int](clock))
+	sc := NewSharded[string, int](time.Minute, time.Minute, 4, WithClock[string, int](clock))
+	defer tc.Close()
+	defer sc.Close()
+	removed := make(chan int, 2)
+	tc.OnCleanup(func(n int, d time.Duration) {
+		removed <- n
+	})
+	sc.OnCleanup(func(n int, d time.Duration) {
+		if d < 0 {
+			t.Error("Negative sweep duration:", d)
+		}
+		removed <- n
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	for _, k := range shardedKeys {
+		sc.Set(k, 1, DefaultExpiration)
+	}
+
+	clock.Advance(2 * time.Minute)
+	got := map[int]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-removed:
+			got[n] = true
+		case <-time.After(time.Second):
+			t.Fatal("The cleanup hook wasn't called")
+		}
+	}
+	if !got[1] || !got[len(shardedKeys)] {
+		t.Error("Unexpected removal counts:", got)
+	}
+
+	tc.OnCleanup(nil)
+	sc.OnCleanup(nil)
+	clock.Advance(time.Minute)
+	select {
+	case n := <-removed:
+		t.Error("The cleanup hook was called after it was unset:", n)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestCleanupNow(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	sc := NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock))
+	var calls, total int
+	hook := func(n int, d time.Duration) {
+		calls++
+		total += n
+	}
+	tc.OnCleanup(hook)
+	sc.OnCleanup(hook)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	for _, k := range shardedKeys {
+		sc.Set(k, 1, DefaultExpiration)
+	}
+
+	if n := tc.CleanupNow(); n != 0 {
+		t.Error("CleanupNow deleted unexpired items:", n)
+	}
+	clock.Advance(2 * time.Minute)
+	if n := tc.CleanupNow(); n != 1 {
+		t.Error("CleanupNow deleted", n, "items instead of 1")
+	}
+	if n := sc.CleanupNow(); n != len(shardedKeys) {
+		t.Error("CleanupNow deleted", n, "items instead of", len(shardedKeys))
+	}
+	if calls != 3 || total != 1+len(shardedKeys) {
+		t.Errorf("The cleanup hook was called %d times with %d items in all", calls, total)
+	}
+	if tc.ItemCountIncludingExpired() != 1 || sc.ItemCountIncludingExpired() != 0 {
+		t.Error("Expired items are left after CleanupNow")
+	}
+}
+
+func TestCleanupOnStart(t *testing.T) {
+	clock := newFakeClock()
+	items := map[string]Item[int]{
+		"expired": {Object: 1, Expiration: clock.Now().Add(-time.Second).UnixNano()},
+		"live":    {Object: 2},
+	}
+	tc := NewFrom[string, int](DefaultExpiration, time.Hour, items,
+		WithClock[string, int](clock), WithCleanupOnStart[string, int](true))
+	defer tc.Close()
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4,
+		WithClock[string, int](clock), WithCleanupOnStart[string, int](true))
+	defer sc.Close()
+	sc.Set("expired", 1, time.Second)
+	clock.Advance(2 * time.Second)
+	sc.SetCleanupInterval(time.Hour)
+
+	// The initial sweeps run in the janitors' goroutines.
+	deadline := time.Now().Add(time.Second)
+	for tc.ItemCountIncludingExpired() != 1 || sc.ItemCountIncludingExpired() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The expired items weren't deleted when the janitors started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStaggeredCleanup(t *testing.T) {
+	clock := newFakeClock()
+	sc := NewSharded[string, int](time.Second, 4*time.Minute, 4,
+		WithClock[string, int](clock), WithStaggeredCleanup[string, int](true))
+	defer sc.Close()
+	removed := make(chan int, 4)
+	sc.OnCleanup(func(n int, d time.Duration) {
+		removed <- n
+	})
+	for _, k := range shardedKeys {
+		sc.Set(k, 1, DefaultExpiration)
+	}
+
+	total := 0
+	for i := 0; i < 4; i++ {
+		// Each tick a quarter of the interval in sweeps a single shard.
+		clock.Advance(time.Minute)
+		select {
+		case n := <-removed:
+			total += n
+		case <-time.After(time.Second):
+			t.Fatal("The janitor didn't sweep a shard at tick", i)
+		}
+		select {
+		case n := <-removed:
+			t.Fatal("The janitor swept more than one shard at tick", i, n)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if total != len(shardedKeys) || sc.ItemCountIncludingExpired() != 0 {
+		t.Errorf("The staggered sweeps deleted %d items of %d", total, len(shardedKeys))
+	}
+}
@@ -0,0 +1,121 @@
+package ttlcache
+
+import (
+	"slices"
+	"time"
+)
+
+// Clone returns a new, independent cache holding copies of the unexpired items
+// of c, with their expiration times and versions, and with the reads left to
+// them by SetWithUses, their pins and their tags. The new cache has the same
+// default expiration, options and cleanup interval as c, and a janitor of its
+// own if c has one. Values are copied by assignment, so values of reference
+// types (such as pointers, slices and maps) are shared between the two caches.
+// Neither the eviction callbacks, subscribers nor stats of c are copied, and
+// the eviction policy of the clone starts out without any record of how the
+// items were used.
+func (c *Cache[K, V]) Clone() *Cache[K, V] {
+	items, states := c.snapshot()
+	cl := newCacheWithJanitor(c.defaultExpiration, c.cleanupInterval(), map[K]Item[V]{}, c.cfg)
+	cl.restore(items, states)
+	return cl
+}
+
+// itemState is what Items leaves out of an item: the reads it has left if it
+// was stored with SetWithUses, whether it is pinned, and its tags.
+type itemState struct {
+	uses    int
+	limited bool
+	pinned  bool
+	tags    []string
+}
+
+// snapshot returns the unexpired items of c, as Items does, and the states of
+// those that have any, taken together under the read lock.
+func (c *cache[K, V]) snapshot() (map[K]Item[V], map[K]itemState) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	items := c.copyItems()
+	states := map[K]itemState{}
+	for k := range items {
+		n, limited := c.uses[k]
+		_, pinned := c.pinned[k]
+		tags := c.keyTags[k]
+		if limited || pinned || len(tags) > 0 {
+			states[k] = itemState{n, limited, pinned, slices.Clone(tags)}
+		}
+	}
+	return items, states
+}
+
+// restore fills c, which must be empty, with the items and states taken by
+// snapshot. The items are pinned before they are stored, so that only unpinned
+// ones are evicted if c is over its limits.
+func (c *cache[K, V]) restore(items map[K]Item[V], states map[K]itemState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, s := range states {
+		if s.pinned {
+			if c.pinned == nil {
+				c.pinned = make(map[K]struct{})
+			}
+			c.pinned[k] = struct{}{}
+		}
+	}
+	c.loadItems(items)
+	for k, s := range states {
+		if _, found := c.items[k]; !found {
+			continue
+		}
+		if s.limited {
+			if c.uses == nil {
+				c.uses = make(map[K]int)
+				c.limitedUses.Store(true)
+			}
+			c.uses[k] = s.uses
+		}
+		c.tag(k, s.tags)
+	}
+}
+
+// cleanupInterval returns the interval of the janitor, or 0 if there is none.
+func (c *cache[K, V]) cleanupInterval() time.Duration {
+	c.janitorMu.Lock()
+	defer c.janitorMu.Unlock()
+	if c.janitor == nil {
+		return 0
+	}
+	return c.janitor.Interval
+}
+
+// Clone returns a new, independent sharded cache holding copies of the
+// unexpired items of sc. The clone has the same number of shards and the same
+// seed, so each item stays in the shard of the same index. Shards are copied
+// one at a time, so with WithMaxTotalCost the copies may add up to more than
+// the maximum if sc is written to meanwhile; the clone is then trimmed back
+// within it before it is returned. See Cache.Clone for what else is and isn't
+// copied.
+func (sc *ShardedCache[K, V]) Clone() *ShardedCache[K, V] {
+	sc.mu.RLock()
+	defer sc.unlock()
+	src := sc.cs[0]
+	nsc := newShardedCacheWithSeed(len(sc.cs), src.defaultExpiration, src.cfg, sc.seed)
+	for i, v := range sc.cs {
+		items, states := v.snapshot()
+		nsc.cs[i] = nsc.newShard(v.defaultExpiration, map[K]Item[V]{}, v.cfg)
+		nsc.cs[i].restore(items, states)
+	}
+	nsc.mu.RLock()
+	nsc.unlockAfterWrite()
+	return newShardedCacheWithJanitor(nsc, sc.cleanupInterval())
+}
+
+// cleanupInterval returns the interval of the janitor, or 0 if there is none.
+func (sc *shardedCache[K, V]) cleanupInterval() time.Duration {
+	sc.janitorMu.Lock()
+	defer sc.janitorMu.Unlock()
+	if sc.janitor == nil {
+		return 0
+	}
+	return sc.janitor.Interval
+}
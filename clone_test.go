@@ -0,0 +1,133 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	tc := New[string, int](time.Hour, time.Minute, WithMaxItems[string, int](10))
+	defer tc.Close()
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	tc.Set("expired", 3, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	cl := tc.Clone()
+	defer cl.Close()
+	if n := cl.ItemCountIncludingExpired(); n != 2 {
+		t.Errorf("The clone has %d items instead of 2", n)
+	}
+	_, e1, _ := tc.GetWithExpiration("a")
+	if x, e2, found := cl.GetWithExpiration("a"); !found || x != 1 || !e1.Equal(e2) {
+		t.Errorf("a was not copied with its expiration time: %d, %v", x, e2)
+	}
+	if cl.defaultExpiration != time.Hour || cl.maxItems != 10 {
+		t.Error("The clone wasn't configured like the original")
+	}
+	if cl.janitor == nil || cl.janitor == tc.janitor || cl.janitor.Interval != time.Minute {
+		t.Error("The clone doesn't have its own janitor")
+	}
+
+	cl.Set("a", 10, DefaultExpiration)
+	cl.Delete("b")
+	if x, _ := tc.Get("a"); x != 1 {
+		t.Error("Changing the clone changed the original")
+	}
+	if !tc.Has("b") {
+		t.Error("Deleting from the clone deleted from the original")
+	}
+}
+
+func TestCloneItemState(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](2))
+	tc.SetWithUses("limited", 1, 2)
+	tc.Get("limited")
+	tc.SetWithTags("tagged", 2, DefaultExpiration, "t")
+	tc.Pin("tagged")
+
+	cl := tc.Clone()
+	if !cl.IsPinned("tagged") {
+		t.Error("The pin wasn't copied")
+	}
+	cl.Set("new", 3, DefaultExpiration)
+	if !cl.Has("tagged") {
+		t.Error("The pinned item was evicted from the clone")
+	}
+	if n := cl.InvalidateTag("t"); n != 1 {
+		t.Error("The tag wasn't copied:", n)
+	}
+	cl = tc.Clone()
+	if _, found := cl.Get("limited"); !found {
+		t.Fatal("limited wasn't copied")
+	}
+	if _, found := cl.Get("limited"); found {
+		t.Error("limited was read more often than SetWithUses allows")
+	}
+}
+
+func TestClonePinnedOverLimit(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0,
+		WithCost[string, int](func(v int) int64 { return int64(v) }, 10))
+	tc.Set("a", 4, DefaultExpiration)
+	tc.Set("b", 4, DefaultExpiration)
+	tc.Set("c", 2, DefaultExpiration)
+	tc.Pin("a")
+	tc.Pin("b")
+	// The pinned items alone now exceed the limit, so c is evicted and the
+	// cache stays over it.
+	tc.Set("a", 8, DefaultExpiration)
+
+	for i := 0; i < 10; i++ {
+		cl := tc.Clone()
+		if !cl.Has("a") || !cl.Has("b") {
+			t.Fatal("A pinned item was evicted from the clone")
+		}
+		if !cl.IsPinned("a") || !cl.IsPinned("b") {
+			t.Fatal("The pins weren't copied")
+		}
+	}
+}
+
+func TestShardedCloneMaxTotalCost(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 4,
+		WithCost[int, int](func(v int) int64 { return int64(v) }, 0),
+		WithMaxTotalCost[int, int](100))
+	for i := 0; i < 9; i++ {
+		tc.Set(i, 10, DefaultExpiration)
+	}
+	// As if the shards had been written to while they were copied.
+	for _, c := range tc.cs {
+		c.cfg.maxTotalCost = 50
+	}
+	cl := tc.Clone()
+	if got := cl.TotalCost(); got > 50 {
+		t.Error("The clone is over its maximum total cost:", got)
+	}
+}
+
+func TestShardedClone(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 7)
+	for i, k := range shardedKeys {
+		tc.Set(k, i, DefaultExpiration)
+	}
+	cl := tc.Clone()
+	if len(cl.cs) != 7 || cl.seed != tc.seed {
+		t.Error("The clone's shards don't match the original's")
+	}
+	if cl.janitor != nil {
+		t.Error("The clone has a janitor even though the original doesn't")
+	}
+	for i, k := range shardedKeys {
+		if x, found := cl.Get(k); !found || x != i {
+			t.Errorf("%s was not copied: %d", k, x)
+		}
+		if n := cl.bucket(k).ItemCount(); n != tc.bucket(k).ItemCount() {
+			t.Errorf("The shard of %s has %d items instead of %d", k, n, tc.bucket(k).ItemCount())
+		}
+	}
+	cl.Flush()
+	if n := tc.ItemCount(); n != len(shardedKeys) {
+		t.Error("Flushing the clone flushed the original")
+	}
+}
@@ -0,0 +1,58 @@
+package ttlcache
+
+// Compact rebuilds the maps holding the cache's items, under the write lock,
+// so that the memory they grew to hold is released. Go maps never shrink, so
+// a cache that once held many more items than it does now, e.g. after a burst
+// of traffic has expired, otherwise keeps that memory for as long as it
+// lives. The new maps hold every item still in the cache, including expired
+// ones that haven't been deleted yet, so it is best to call DeleteExpired
+// first.
+//
+// It returns the number of items by which the cache has shrunk from the most
+// it held since its map was last made (by New, Flush or Compact); this is how
+// many items' worth of room is reclaimed. A map passed to NewFrom or
+// returned by ItemsRef is no longer used by the cache afterwards.
+func (c *cache[K, V]) Compact() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.items)
+	reclaimed := max(c.peak, n) - n
+	c.items = rebuild(c.items)
+	c.peak = 0
+	if c.costs != nil {
+		c.costs = rebuild(c.costs)
+	}
+	if c.uses != nil {
+		c.uses = rebuild(c.uses)
+	}
+	if c.pinned != nil {
+		c.pinned = rebuild(c.pinned)
+	}
+	if c.keyTags != nil {
+		c.keyTags = rebuild(c.keyTags)
+	}
+	return reclaimed
+}
+
+// Compact rebuilds the maps of each shard in turn, and returns the total room
+// reclaimed. See Cache.Compact.
+func (sc *shardedCache[K, V]) Compact() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	n := 0
+	for _, c := range sc.cs {
+		n += c.Compact()
+	}
+	return n
+}
+
+// rebuild returns a copy of m sized for its current contents. Unlike
+// maps.Clone, which keeps the size of m's hash table, it releases the room m
+// grew to hold.
+func rebuild[K comparable, T any](m map[K]T) map[K]T {
+	r := make(map[K]T, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
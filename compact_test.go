@@ -0,0 +1,48 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0, WithCost[string, int](func(int) int64 { return 1 }, 0))
+	for i := 0; i < 1000; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	for i := 10; i < 1000; i++ {
+		tc.Delete(strconv.Itoa(i))
+	}
+	if n := tc.Compact(); n != 990 {
+		t.Error("Compact reclaimed room for", n, "items instead of 990")
+	}
+	if n := tc.ItemCount(); n != 10 {
+		t.Error("Compact changed the number of items:", n)
+	}
+	if x, found := tc.Get("5"); !found || x != 5 {
+		t.Error("5 was lost by Compact:", x, found)
+	}
+	if c := tc.Cost(); c != 10 {
+		t.Error("Compact changed the total cost:", c)
+	}
+	if n := tc.Compact(); n != 0 {
+		t.Error("A second Compact reclaimed room for", n, "items")
+	}
+}
+
+func TestShardedCompact(t *testing.T) {
+	sc := NewSharded[string, int](time.Millisecond, 0, 4)
+	for i, k := range shardedKeys {
+		sc.Set(k, i, DefaultExpiration)
+	}
+	sc.Set("kept", 1, NoExpiration)
+	<-time.After(5 * time.Millisecond)
+	sc.DeleteExpired()
+	if n := sc.Compact(); n != len(shardedKeys) {
+		t.Error("Compact reclaimed room for", n, "items instead of", len(shardedKeys))
+	}
+	if _, found := sc.Get("kept"); !found {
+		t.Error("kept was lost by Compact")
+	}
+}
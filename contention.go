@@ -0,0 +1,78 @@
+package ttlcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardStat describes how contended the write lock of one shard has been, as
+// returned by ShardContention.
+type ShardStat struct {
+	// Acquisitions is the number of times the write lock was taken.
+	Acquisitions uint64
+
+	// Contended is the number of those acquisitions that had to wait for
+	// the lock to be released by another goroutine.
+	Contended uint64
+
+	// Wait is the total time spent waiting for the lock.
+	Wait time.Duration
+}
+
+// contention holds the counters behind a ShardStat.
+type contention struct {
+	acquisitions atomic.Uint64
+	contended    atomic.Uint64
+	wait         atomic.Int64
+}
+
+func (ct *contention) stat() ShardStat {
+	return ShardStat{
+		Acquisitions: ct.acquisitions.Load(),
+		Contended:    ct.contended.Load(),
+		Wait:         time.Duration(ct.wait.Load()),
+	}
+}
+
+// rwMutex is a sync.RWMutex whose write lock acquisitions are counted if
+// contention is set, which must be done before the mutex is first used.
+type rwMutex struct {
+	sync.RWMutex
+	contention *contention
+}
+
+// Lock locks m for writing, recording whether and for how long it had to wait
+// if contention tracking is enabled.
+func (m *rwMutex) Lock() {
+	ct := m.contention
+	if ct == nil {
+		m.RWMutex.Lock()
+		return
+	}
+	ct.acquisitions.Add(1)
+	if m.RWMutex.TryLock() {
+		return
+	}
+	start := time.Now()
+	m.RWMutex.Lock()
+	ct.contended.Add(1)
+	ct.wait.Add(int64(time.Since(start)))
+}
+
+// ShardContention returns the contention of each shard's write lock, indexed
+// like the shards, e.g. to tell with ShardSizes whether some shards are hot
+// spots. It returns nil unless the cache was created WithContentionTracking.
+// The counters of a shard start from zero when Reshard creates it.
+func (sc *shardedCache[K, V]) ShardContention() []ShardStat {
+	sc.mu.RLock()
+	defer sc.unlock()
+	if sc.cs[0].mu.contention == nil {
+		return nil
+	}
+	stats := make([]ShardStat, len(sc.cs))
+	for i, c := range sc.cs {
+		stats[i] = c.mu.contention.stat()
+	}
+	return stats
+}
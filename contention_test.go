@@ -0,0 +1,56 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardContention(t *testing.T) {
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	sc.Set("a", 1, DefaultExpiration)
+	if stats := sc.ShardContention(); stats != nil {
+		t.Error("ShardContention returned stats without tracking:", stats)
+	}
+
+	sc = NewSharded[string, int](DefaultExpiration, 0, 4, WithContentionTracking[string, int](true))
+	for i, k := range shardedKeys {
+		sc.Set(k, i, DefaultExpiration)
+	}
+	stats := sc.ShardContention()
+	if len(stats) != 4 {
+		t.Fatalf("ShardContention returned %d stats instead of 4", len(stats))
+	}
+	var total uint64
+	for _, s := range stats {
+		total += s.Acquisitions
+		if s.Contended != 0 || s.Wait != 0 {
+			t.Error("Uncontended writes were counted as contended:", s)
+		}
+	}
+	if total != uint64(len(shardedKeys)) {
+		t.Errorf("%d acquisitions were counted instead of %d", total, len(shardedKeys))
+	}
+
+	// Hold the lock of a's shard so that the next write to it has to wait.
+	c := sc.bucket("a")
+	c.mu.Lock()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sc.Set("a", 2, DefaultExpiration)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Unlock()
+	wg.Wait()
+	var contended uint64
+	var wait time.Duration
+	for _, s := range sc.ShardContention() {
+		contended += s.Contended
+		wait += s.Wait
+	}
+	if contended != 1 || wait <= 0 {
+		t.Errorf("%d contended acquisitions waiting %v were counted instead of 1", contended, wait)
+	}
+}
@@ -0,0 +1,186 @@
+package ttlcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Event describes the eviction of an item from the cache.
+type Event[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Reason EvictionReason
+}
+
+// subscriberBuffer is the capacity of the channels returned by Subscribe.
+const subscriberBuffer = 128
+
+// subscribers is a set of channels that eviction events are published to. It
+// is shared by all shards of a sharded cache.
+type subscribers[K comparable, V any] struct {
+	mu    sync.RWMutex
+	chans map[<-chan Event[K, V]]chan Event[K, V]
+}
+
+func newSubscribers[K comparable, V any]() *subscribers[K, V] {
+	return &subscribers[K, V]{
+		chans: map[<-chan Event[K, V]]chan Event[K, V]{},
+	}
+}
+
+func (s *subscribers[K, V]) subscribe() <-chan Event[K, V] {
+	ch := make(chan Event[K, V], subscriberBuffer)
+	s.mu.Lock()
+	s.chans[ch] = ch
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *subscribers[K, V]) unsubscribe(ch <-chan Event[K, V]) {
+	s.mu.Lock()
+	if c, found := s.chans[ch]; found {
+		delete(s.chans, ch)
+		close(c)
+	}
+	s.mu.Unlock()
+}
+
+// publish sends the event to every subscriber whose buffer isn't full.
+func (s *subscribers[K, V]) publish(k K, v V, reason EvictionReason) {
+	e := Event[K, V]{Key: k, Value: v, Reason: reason}
+	s.mu.RLock()
+	for _, c := range s.chans {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+	s.mu.RUnlock()
+}
+
+// updateOnEvicted combines the eviction callback and the subscribers, if any,
+// into c.onEvicted, which is called with values as they are stored and decodes
+// them for both. It must be called with c.mu held.
+func (c *cache[K, V]) updateOnEvicted() {
+	f, subs := c.evictedFunc, c.subs
+	switch {
+	case subs == nil:
+		c.onEvicted = f
+	case f == nil:
+		c.onEvicted = subs.publish
+	default:
+		c.onEvicted = func(k K, v V, reason EvictionReason) {
+			f(k, v, reason)
+			subs.publish(k, v, reason)
+		}
+	}
+	if ef := c.onEvicted; ef != nil && c.decode != nil {
+		c.onEvicted = func(k K, v V, reason EvictionReason) {
+			ef(k, c.decode(v), reason)
+		}
+	}
+	if ef, o := c.onEvicted, c.outbox; ef != nil && o != nil {
+		c.onEvicted = func(k K, v V, reason EvictionReason) {
+			o.push(func() { ef(k, v, reason) })
+		}
+	}
+}
+
+// outbox queues the callbacks of the shards of a sharded cache, so that they
+// run once the operation that triggered them has released the layout lock,
+// sc.mu, rather than while holding it: a callback may then call back into the
+// cache even while Reshard is waiting for the lock. The zero value is ready to
+// use.
+type outbox struct {
+	mu    sync.Mutex
+	queue []func()
+	n     atomic.Int32 // len(queue), to skip deliver cheaply when it is empty
+
+	// delivering is held by the goroutine running the queued callbacks.
+	delivering sync.Mutex
+}
+
+// push queues f to be run by the next call to deliver.
+func (o *outbox) push(f func()) {
+	o.mu.Lock()
+	o.queue = append(o.queue, f)
+	o.n.Store(int32(len(o.queue)))
+	o.mu.Unlock()
+}
+
+// deliver runs the queued callbacks, in the order they were queued, until the
+// queue is empty. If another goroutine is running them already, including a
+// callback of the calling goroutine that called back into the cache, deliver
+// leaves the rest to it instead, so callbacks never run concurrently or
+// nested. It must be called without sc.mu held.
+func (o *outbox) deliver() {
+	for o.n.Load() > 0 && o.delivering.TryLock() {
+		o.run()
+	}
+}
+
+func (o *outbox) run() {
+	defer o.delivering.Unlock()
+	o.mu.Lock()
+	queue := o.queue
+	o.queue = nil
+	o.n.Store(0)
+	o.mu.Unlock()
+	for _, f := range queue {
+		f()
+	}
+}
+
+// useSubscribers makes c publish its events to s, unless it already publishes
+// to other subscribers, and returns the subscribers in use.
+func (c *cache[K, V]) useSubscribers(s *subscribers[K, V]) *subscribers[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subs == nil {
+		c.subs = s
+		c.updateOnEvicted()
+	}
+	return c.subs
+}
+
+// Subscribe returns a channel on which an Event is sent whenever an item is
+// evicted from the cache, for any of the reasons passed to the OnEvicted
+// callback, and after that callback has returned.
+//
+// Events are sent without blocking: the channel is buffered, and if a
+// subscriber falls behind so that its buffer is full, further events are
+// dropped for it until it catches up. Subscribers are therefore not guaranteed
+// to see every event, and events of concurrent evictions may arrive in any
+// order. The channel is closed by Unsubscribe.
+func (c *cache[K, V]) Subscribe() <-chan Event[K, V] {
+	return c.useSubscribers(newSubscribers[K, V]()).subscribe()
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe, and
+// closes it. Events already buffered can still be received. It does nothing if
+// the channel is not subscribed.
+func (c *cache[K, V]) Unsubscribe(ch <-chan Event[K, V]) {
+	c.mu.RLock()
+	subs := c.subs
+	c.mu.RUnlock()
+	if subs != nil {
+		subs.unsubscribe(ch)
+	}
+}
+
+// Subscribe returns a channel on which an Event is sent whenever an item is
+// evicted from any shard. See Cache.Subscribe.
+func (sc *shardedCache[K, V]) Subscribe() <-chan Event[K, V] {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, c := range sc.cs {
+		c.useSubscribers(sc.subs)
+	}
+	return sc.subs.subscribe()
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe, and
+// closes it. See Cache.Unsubscribe.
+func (sc *shardedCache[K, V]) Unsubscribe(ch <-chan Event[K, V]) {
+	sc.subs.unsubscribe(ch)
+}
@@ -0,0 +1,99 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func receiveEvent[K comparable, V any](t *testing.T, ch <-chan Event[K, V]) Event[K, V] {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("No event was received")
+	}
+	return Event[K, V]{}
+}
+
+func TestSubscribe(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](2))
+	var called []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		called = append(called, k)
+	})
+	ch := tc.Subscribe()
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Delete("a")
+	if e := receiveEvent(t, ch); e.Key != "a" || e.Value != 1 || e.Reason != ReasonDeleted {
+		t.Error("Unexpected event after Delete:", e)
+	}
+
+	tc.Set("b", 2, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	tc.DeleteExpired()
+	if e := receiveEvent(t, ch); e.Key != "b" || e.Reason != ReasonExpired {
+		t.Error("Unexpected event after DeleteExpired:", e)
+	}
+
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Set("d", 4, DefaultExpiration)
+	tc.Set("e", 5, DefaultExpiration)
+	if e := receiveEvent(t, ch); e.Key != "c" || e.Reason != ReasonCapacity {
+		t.Error("Unexpected event after exceeding the capacity:", e)
+	}
+
+	if len(called) != 3 {
+		t.Error("The eviction callback wasn't called alongside the subscribers:", called)
+	}
+
+	tc.Unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Error("The channel wasn't closed by Unsubscribe")
+	}
+	tc.Delete("d")
+	tc.Unsubscribe(ch)
+}
+
+func TestSubscribeDrops(t *testing.T) {
+	tc := New[int, int](DefaultExpiration, 0)
+	ch := tc.Subscribe()
+	for i := 0; i < 2*subscriberBuffer; i++ {
+		tc.Set(i, i, DefaultExpiration)
+		tc.Delete(i)
+	}
+	if n := len(ch); n != subscriberBuffer {
+		t.Errorf("%d events were buffered instead of %d", n, subscriberBuffer)
+	}
+	if e := <-ch; e.Key != 0 {
+		t.Error("The oldest event was dropped instead of the newest:", e)
+	}
+}
+
+func TestShardedSubscribe(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	ch1 := tc.Subscribe()
+	ch2 := tc.Subscribe()
+	for i, k := range shardedKeys {
+		tc.Set(k, i, DefaultExpiration)
+	}
+	for _, k := range shardedKeys {
+		tc.Delete(k)
+	}
+	for _, ch := range []<-chan Event[string, int]{ch1, ch2} {
+		seen := map[string]bool{}
+		for range shardedKeys {
+			seen[receiveEvent(t, ch).Key] = true
+		}
+		if len(seen) != len(shardedKeys) {
+			t.Errorf("Events were received for %d keys instead of %d", len(seen), len(shardedKeys))
+		}
+	}
+	tc.Unsubscribe(ch1)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Delete("a")
+	if e := receiveEvent(t, ch2); e.Key != "a" {
+		t.Error("Unexpected event:", e)
+	}
+}
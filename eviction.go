@@ -0,0 +1,487 @@
+package ttlcache
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// EvictionPolicy selects which item is evicted when a cache created with
+// WithMaxItems is full.
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evicts the least-recently-used item. This is the default.
+	PolicyLRU EvictionPolicy = iota
+
+	// PolicyLFU evicts the least-frequently-used item, breaking ties by
+	// evicting the least-recently-used one. Access counters are halved
+	// periodically so that items which are no longer hot can be evicted.
+	PolicyLFU
+
+	// PolicySampledLRU approximates PolicyLRU the way Redis does: each hit
+	// only stamps the item with an access counter, and on eviction a few
+	// items are sampled at random (see WithEvictSampleSize) and the least
+	// recently used of them is evicted. Since stamping needs no exclusive
+	// access, Get and the other reads only take the cache's read lock, as
+	// for an unbounded cache, at the price of sometimes evicting an item that
+	// was used more recently than others left in the cache.
+	PolicySampledLRU
+)
+
+// defaultEvictSampleSize is the number of items PolicySampledLRU samples on
+// eviction unless WithEvictSampleSize says otherwise.
+const defaultEvictSampleSize = 5
+
+func newEvictionPolicy[K comparable](p EvictionPolicy, sampleSize int) evictionPolicy[K] {
+	switch p {
+	case PolicyLFU:
+		return newLFUPolicy[K]()
+	case PolicySampledLRU:
+		if sampleSize <= 0 {
+			sampleSize = defaultEvictSampleSize
+		}
+		return newSampledLRUPolicy[K](sampleSize)
+	}
+	return newLRUPolicy[K]()
+}
+
+// evictionPolicy tracks the keys stored in a cache and decides which one
+// should be removed when the cache is over capacity. Its methods are called
+// with the cache's write lock held.
+type evictionPolicy[K comparable] interface {
+	// insert records that k was stored (or overwritten).
+	insert(k K)
+
+	// access records a read hit on k. If the policy implements
+	// sharedAccessor, it may be called with only the cache's read lock held.
+	access(k K)
+
+	// remove forgets k.
+	remove(k K)
+
+	// victim returns the key that should be evicted next.
+	victim() (K, bool)
+
+	// reset forgets all keys.
+	reset()
+
+	// each calls f for each key in the order they would be evicted in,
+	// until f returns false, without changing that order.
+	each(f func(K) bool)
+}
+
+// sharedAccessor is implemented by the eviction policies whose access method
+// is safe to call concurrently, with only the cache's read lock held.
+type sharedAccessor interface {
+	sharedAccess()
+}
+
+// LRUKeys returns up to n keys of unexpired items, from the least- to the
+// most-recently used, i.e. in the order the eviction policy would evict them
+// in (for PolicyLFU, the least-frequently used first). If the cache has no
+// eviction policy, because it was created without WithMaxItems or WithCost,
+// usage isn't tracked and the keys are returned in no particular order. It
+// only takes the read lock and doesn't count as a use of the items, so it
+// can be used to inspect the eviction order, e.g. in tests or from an admin
+// endpoint.
+func (c *cache[K, V]) LRUKeys(n int) []K {
+	if n <= 0 {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]K, 0, min(n, len(c.items)))
+	now := c.now()
+	visit := func(k K) bool {
+		if item := c.items[k]; item.Expiration <= 0 || now <= item.Expiration {
+			keys = append(keys, k)
+		}
+		return len(keys) < n
+	}
+	if c.policy != nil {
+		c.policy.each(visit)
+	} else {
+		for k := range c.items {
+			if !visit(k) {
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// LRUKeys returns up to n keys from the least- to the most-recently used. Usage
+// is only tracked within each shard, so the keys of the shards are taken in
+// turn: first the least-recently-used key of every shard, then the next one of
+// each, and so on. See Cache.LRUKeys.
+func (sc *shardedCache[K, V]) LRUKeys(n int) []K {
+	sc.mu.RLock()
+	defer sc.unlock()
+	perShard := make([][]K, len(sc.cs))
+	for i, c := range sc.cs {
+		perShard[i] = c.LRUKeys(n)
+	}
+	var keys []K
+	for j := 0; len(keys) < n; j++ {
+		more := false
+		for _, ks := range perShard {
+			if j < len(ks) && len(keys) < n {
+				keys = append(keys, ks[j])
+				more = true
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return keys
+}
+
+type lruEntry[K comparable] struct {
+	key        K
+	prev, next *lruEntry[K]
+}
+
+// lruPolicy evicts the least-recently-used key. It keeps a doubly linked list
+// ordered from most- to least-recently used, indexed by key.
+type lruPolicy[K comparable] struct {
+	root    lruEntry[K] // sentinel; root.next is the MRU entry, root.prev the LRU one
+	entries map[K]*lruEntry[K]
+}
+
+func newLRUPolicy[K comparable]() *lruPolicy[K] {
+	p := &lruPolicy[K]{}
+	p.reset()
+	return p
+}
+
+func (p *lruPolicy[K]) insert(k K) {
+	if e, ok := p.entries[k]; ok {
+		p.moveToFront(e)
+		return
+	}
+	e := &lruEntry[K]{key: k}
+	p.entries[k] = e
+	p.pushFront(e)
+}
+
+func (p *lruPolicy[K]) access(k K) {
+	if e, ok := p.entries[k]; ok {
+		p.moveToFront(e)
+	}
+}
+
+func (p *lruPolicy[K]) remove(k K) {
+	if e, ok := p.entries[k]; ok {
+		p.unlink(e)
+		delete(p.entries, k)
+	}
+}
+
+func (p *lruPolicy[K]) victim() (K, bool) {
+	if p.root.prev == &p.root {
+		var zero K
+		return zero, false
+	}
+	return p.root.prev.key, true
+}
+
+func (p *lruPolicy[K]) reset() {
+	p.root.next = &p.root
+	p.root.prev = &p.root
+	p.entries = make(map[K]*lruEntry[K])
+}
+
+func (p *lruPolicy[K]) each(f func(K) bool) {
+	for e := p.root.prev; e != &p.root; e = e.prev {
+		if !f(e.key) {
+			return
+		}
+	}
+}
+
+func (p *lruPolicy[K]) pushFront(e *lruEntry[K]) {
+	e.prev = &p.root
+	e.next = p.root.next
+	p.root.next.prev = e
+	p.root.next = e
+}
+
+func (p *lruPolicy[K]) unlink(e *lruEntry[K]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev = nil
+	e.next = nil
+}
+
+func (p *lruPolicy[K]) moveToFront(e *lruEntry[K]) {
+	if p.root.next == e {
+		return
+	}
+	p.unlink(e)
+	p.pushFront(e)
+}
+
+// sampledLRUPolicy approximates an LRU policy by sampling: see
+// PolicySampledLRU. Each key is stamped with the value of a counter that is
+// incremented at every insertion and access, and the victim is the key with
+// the smallest stamp among size keys taken from the start of an iteration
+// over entries, which Go randomizes.
+type sampledLRUPolicy[K comparable] struct {
+	entries map[K]*atomic.Uint64
+	tick    atomic.Uint64
+	size    int
+}
+
+func newSampledLRUPolicy[K comparable](size int) *sampledLRUPolicy[K] {
+	p := &sampledLRUPolicy[K]{size: size}
+	p.reset()
+	return p
+}
+
+func (p *sampledLRUPolicy[K]) sharedAccess() {}
+
+func (p *sampledLRUPolicy[K]) insert(k K) {
+	if e, ok := p.entries[k]; ok {
+		e.Store(p.tick.Add(1))
+		return
+	}
+	e := new(atomic.Uint64)
+	e.Store(p.tick.Add(1))
+	p.entries[k] = e
+}
+
+func (p *sampledLRUPolicy[K]) access(k K) {
+	if e, ok := p.entries[k]; ok {
+		e.Store(p.tick.Add(1))
+	}
+}
+
+func (p *sampledLRUPolicy[K]) remove(k K) {
+	delete(p.entries, k)
+}
+
+func (p *sampledLRUPolicy[K]) victim() (K, bool) {
+	var victim K
+	var oldest uint64
+	n := 0
+	for k, e := range p.entries {
+		if t := e.Load(); n == 0 || t < oldest {
+			victim, oldest = k, t
+		}
+		if n++; n == p.size {
+			break
+		}
+	}
+	return victim, n > 0
+}
+
+func (p *sampledLRUPolicy[K]) reset() {
+	p.entries = make(map[K]*atomic.Uint64)
+	p.tick.Store(0)
+}
+
+// each visits the keys from the least- to the most-recently used, which is
+// only roughly the order they would be evicted in.
+func (p *sampledLRUPolicy[K]) each(f func(K) bool) {
+	type stamped struct {
+		key  K
+		tick uint64
+	}
+	sorted := make([]stamped, 0, len(p.entries))
+	for k, e := range p.entries {
+		sorted = append(sorted, stamped{k, e.Load()})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].tick < sorted[j].tick
+	})
+	for _, e := range sorted {
+		if !f(e.key) {
+			return
+		}
+	}
+}
+
+// lfuMaxFrequency is the access count at which every counter of an lfuPolicy
+// is halved, so that keys which were hot a long time ago eventually become
+// eligible for eviction and the counters can't grow without bound.
+const lfuMaxFrequency = 1 << 15
+
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  uint32
+	tick  uint64 // value of lfuPolicy.tick at the last access
+	index int    // position in lfuPolicy.heap
+}
+
+// lfuPolicy evicts the least-frequently-used key, breaking ties by evicting
+// the least-recently-used one. Entries are kept in a min-heap ordered by
+// frequency and then by recency.
+type lfuPolicy[K comparable] struct {
+	heap    []*lfuEntry[K]
+	entries map[K]*lfuEntry[K]
+	tick    uint64
+	newest  *lfuEntry[K] // the key inserted by the last call, see victim
+}
+
+func newLFUPolicy[K comparable]() *lfuPolicy[K] {
+	p := &lfuPolicy[K]{}
+	p.reset()
+	return p
+}
+
+func (p *lfuPolicy[K]) insert(k K) {
+	if _, ok := p.entries[k]; ok {
+		p.access(k)
+		return
+	}
+	p.tick++
+	e := &lfuEntry[K]{key: k, freq: 1, tick: p.tick, index: len(p.heap)}
+	p.entries[k] = e
+	p.newest = e
+	p.heap = append(p.heap, e)
+	p.up(e.index)
+}
+
+func (p *lfuPolicy[K]) access(k K) {
+	p.newest = nil
+	e, ok := p.entries[k]
+	if !ok {
+		return
+	}
+	p.tick++
+	e.tick = p.tick
+	e.freq++
+	if e.freq >= lfuMaxFrequency {
+		p.age()
+		return
+	}
+	// The entry can only have become "larger", so it may need to move down.
+	p.down(e.index)
+}
+
+func (p *lfuPolicy[K]) remove(k K) {
+	e, ok := p.entries[k]
+	if !ok {
+		return
+	}
+	delete(p.entries, k)
+	if p.newest == e {
+		p.newest = nil
+	}
+	last := len(p.heap) - 1
+	i := e.index
+	if i != last {
+		p.swap(i, last)
+	}
+	p.heap[last] = nil
+	p.heap = p.heap[:last]
+	if i != last {
+		if !p.down(i) {
+			p.up(i)
+		}
+	}
+}
+
+// victim passes over a key that was inserted by the last call other than
+// victim or remove, as long as there is any other key. The key starts with a
+// frequency of 1, so in a cache whose keys have all been read it would
+// otherwise be evicted by the very store that inserted it. The smaller of its
+// children is then the next candidate.
+func (p *lfuPolicy[K]) victim() (K, bool) {
+	if len(p.heap) == 0 {
+		var zero K
+		return zero, false
+	}
+	if p.heap[0] != p.newest || len(p.heap) == 1 {
+		return p.heap[0].key, true
+	}
+	i := 1
+	if len(p.heap) > 2 && p.less(2, 1) {
+		i = 2
+	}
+	return p.heap[i].key, true
+}
+
+func (p *lfuPolicy[K]) reset() {
+	p.heap = nil
+	p.entries = make(map[K]*lfuEntry[K])
+	p.tick = 0
+	p.newest = nil
+}
+
+// each visits the keys in eviction order. The heap is only partially ordered,
+// so it sorts a copy of it.
+func (p *lfuPolicy[K]) each(f func(K) bool) {
+	sorted := make([]*lfuEntry[K], len(p.heap))
+	copy(sorted, p.heap)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.freq != b.freq {
+			return a.freq < b.freq
+		}
+		return a.tick < b.tick
+	})
+	for _, e := range sorted {
+		if !f(e.key) {
+			return
+		}
+	}
+}
+
+// age halves every frequency counter and restores the heap order.
+func (p *lfuPolicy[K]) age() {
+	for _, e := range p.heap {
+		e.freq /= 2
+	}
+	for i := len(p.heap)/2 - 1; i >= 0; i-- {
+		p.down(i)
+	}
+}
+
+func (p *lfuPolicy[K]) less(i, j int) bool {
+	a, b := p.heap[i], p.heap[j]
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (p *lfuPolicy[K]) swap(i, j int) {
+	p.heap[i], p.heap[j] = p.heap[j], p.heap[i]
+	p.heap[i].index = i
+	p.heap[j].index = j
+}
+
+func (p *lfuPolicy[K]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !p.less(i, parent) {
+			break
+		}
+		p.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the element at i towards the leaves and reports whether it moved.
+func (p *lfuPolicy[K]) down(i int) bool {
+	start := i
+	n := len(p.heap)
+	for {
+		l := 2*i + 1
+		if l >= n {
+			break
+		}
+		j := l
+		if r := l + 1; r < n && p.less(r, l) {
+			j = r
+		}
+		if !p.less(j, i) {
+			break
+		}
+		p.swap(i, j)
+		i = j
+	}
+	return i > start
+}
@@ -0,0 +1,508 @@
+package ttlcache
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUPolicy(t *testing.T) {
+	p := newLRUPolicy[string]()
+	if _, ok := p.victim(); ok {
+		t.Fatal("Empty policy returned a victim")
+	}
+	p.insert("a")
+	p.insert("b")
+	p.insert("c")
+	if k, _ := p.victim(); k != "a" {
+		t.Error("Victim is not a:", k)
+	}
+	p.access("a")
+	if k, _ := p.victim(); k != "b" {
+		t.Error("Victim is not b after accessing a:", k)
+	}
+	p.insert("b")
+	if k, _ := p.victim(); k != "c" {
+		t.Error("Victim is not c after overwriting b:", k)
+	}
+	p.remove("c")
+	if k, _ := p.victim(); k != "a" {
+		t.Error("Victim is not a after removing c:", k)
+	}
+	p.reset()
+	if _, ok := p.victim(); ok {
+		t.Error("Reset policy returned a victim")
+	}
+}
+
+func TestMaxItems(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](3))
+	var evicted []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		if reason != ReasonCapacity {
+			t.Errorf("%s was evicted with reason %v instead of %v", k, reason, ReasonCapacity)
+		}
+		evicted = append(evicted, k)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Get("a")
+	tc.Set("d", 4, DefaultExpiration)
+	if n := tc.ItemCount(); n != 3 {
+		t.Errorf("Item count is not 3: %d", n)
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("b was found, but it should have been evicted as least recently used")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was not found", k)
+		}
+	}
+	if err := tc.Add("e", 5, DefaultExpiration); err != nil {
+		t.Error("Couldn't add e:", err)
+	}
+	if len(evicted) != 2 || evicted[0] != "b" || evicted[1] != "a" {
+		t.Error("Unexpected evictions:", evicted)
+	}
+}
+
+func TestMaxItemsNewFrom(t *testing.T) {
+	m := map[string]Item[int]{}
+	for i := 0; i < 10; i++ {
+		m[strconv.Itoa(i)] = Item[int]{Object: i}
+	}
+	tc := NewFrom[string, int](DefaultExpiration, 0, m, WithMaxItems[string, int](5))
+	if n := tc.ItemCount(); n != 5 {
+		t.Errorf("Item count is not 5: %d", n)
+	}
+}
+
+func BenchmarkCacheGetLRUExpiring(b *testing.B) {
+	benchmarkCacheGetLRU(b, 5*time.Minute)
+}
+
+func BenchmarkCacheGetLRUNotExpiring(b *testing.B) {
+	benchmarkCacheGetLRU(b, NoExpiration)
+}
+
+func BenchmarkCacheGetSampledLRUExpiring(b *testing.B) {
+	benchmarkCacheGetLRU(b, 5*time.Minute, WithEvictionPolicy[string, interface{}](PolicySampledLRU))
+}
+
+func BenchmarkCacheGetSampledLRUNotExpiring(b *testing.B) {
+	benchmarkCacheGetLRU(b, NoExpiration, WithEvictionPolicy[string, interface{}](PolicySampledLRU))
+}
+
+func benchmarkCacheGetLRU(b *testing.B, exp time.Duration, opts ...Option[string, interface{}]) {
+	// Compare against BenchmarkCacheGet in cache_test.go.
+	b.StopTimer()
+	tc := New[string, interface{}](exp, 0, append(opts, WithMaxItems[string, interface{}](1000))...)
+	tc.Set("foo", "bar", DefaultExpiration)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tc.Get("foo")
+	}
+}
+
+func BenchmarkCacheGetLRUConcurrent(b *testing.B) {
+	benchmarkCacheGetLRUConcurrent(b)
+}
+
+func BenchmarkCacheGetSampledLRUConcurrent(b *testing.B) {
+	benchmarkCacheGetLRUConcurrent(b, WithEvictionPolicy[string, interface{}](PolicySampledLRU))
+}
+
+func benchmarkCacheGetLRUConcurrent(b *testing.B, opts ...Option[string, interface{}]) {
+	// The exact LRU policy serializes reads on the write lock, which
+	// PolicySampledLRU avoids; compare with GOMAXPROCS > 1.
+	tc := New[string, interface{}](NoExpiration, 0, append(opts, WithMaxItems[string, interface{}](1000))...)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "foo" + strconv.Itoa(i)
+		tc.Set(keys[i], "bar", DefaultExpiration)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			tc.Get(keys[i%len(keys)])
+		}
+	})
+}
+
+func BenchmarkCacheSetLRU(b *testing.B) {
+	b.StopTimer()
+	tc := New[string, interface{}](DefaultExpiration, 0, WithMaxItems[string, interface{}](1000))
+	keys := make([]string, 2000)
+	for i := range keys {
+		keys[i] = "foo" + strconv.Itoa(i)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tc.Set(keys[i%len(keys)], "bar", DefaultExpiration)
+	}
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := newLFUPolicy[string]()
+	if _, ok := p.victim(); ok {
+		t.Fatal("Empty policy returned a victim")
+	}
+	p.insert("a")
+	p.insert("b")
+	p.insert("c")
+	if k, _ := p.victim(); k != "a" {
+		t.Error("Victim is not a when all frequencies are equal:", k)
+	}
+	p.access("a")
+	p.access("a")
+	p.access("b")
+	if k, _ := p.victim(); k != "c" {
+		t.Error("Victim is not c, the least frequently used key:", k)
+	}
+	p.access("c")
+	if k, _ := p.victim(); k != "b" {
+		t.Error("Victim is not b, the least recently used of b and c:", k)
+	}
+	p.remove("b")
+	if k, _ := p.victim(); k != "c" {
+		t.Error("Victim is not c after removing b:", k)
+	}
+	p.remove("c")
+	p.remove("a")
+	if _, ok := p.victim(); ok {
+		t.Error("Policy returned a victim after removing every key")
+	}
+}
+
+func TestLFUPolicyAging(t *testing.T) {
+	p := newLFUPolicy[string]()
+	p.insert("a")
+	for i := 0; i < lfuMaxFrequency; i++ {
+		p.access("a")
+	}
+	if f := p.entries["a"].freq; f >= lfuMaxFrequency {
+		t.Errorf("Frequency of a was not halved: %d", f)
+	}
+}
+
+func TestEvictionPolicyLFU(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0,
+		WithMaxItems[string, int](2),
+		WithEvictionPolicy[string, int](PolicyLFU),
+	)
+	tc.Set("hot", 1, DefaultExpiration)
+	for i := 0; i < 10; i++ {
+		tc.Get("hot")
+	}
+	for i := 0; i < 10; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	if _, found := tc.Get("hot"); !found {
+		t.Error("hot was evicted by a burst of one-shot keys")
+	}
+	if _, found := tc.Get("9"); !found {
+		t.Error("9 was not found")
+	}
+}
+
+func TestEvictionPolicyLFUNewKey(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0,
+		WithMaxItems[string, int](3),
+		WithEvictionPolicy[string, int](PolicyLFU),
+	)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("b")
+	tc.Get("c")
+	tc.Set("d", 4, DefaultExpiration)
+	if _, found := tc.Get("d"); !found {
+		t.Error("d was evicted as soon as it was set")
+	}
+	if _, found := tc.Get("a"); found {
+		t.Error("a, the least recently used key, was not evicted")
+	}
+}
+
+func TestSampledLRUPolicy(t *testing.T) {
+	p := newSampledLRUPolicy[string](3)
+	if _, ok := p.victim(); ok {
+		t.Fatal("Empty policy returned a victim")
+	}
+	p.insert("a")
+	p.insert("b")
+	p.insert("c")
+	// With a sample as large as the policy, the victim is the exact LRU key.
+	if k, _ := p.victim(); k != "a" {
+		t.Error("Victim is not a:", k)
+	}
+	p.access("a")
+	if k, _ := p.victim(); k != "b" {
+		t.Error("Victim is not b after accessing a:", k)
+	}
+	p.remove("b")
+	if k, _ := p.victim(); k != "c" {
+		t.Error("Victim is not c after removing b:", k)
+	}
+	var order []string
+	p.each(func(k string) bool {
+		order = append(order, k)
+		return true
+	})
+	if !reflect.DeepEqual(order, []string{"c", "a"}) {
+		t.Error("Unexpected eviction order:", order)
+	}
+	p.reset()
+	if _, ok := p.victim(); ok {
+		t.Error("Reset policy returned a victim")
+	}
+}
+
+func TestEvictionPolicySampledLRU(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0,
+		WithMaxItems[string, int](10),
+		WithEvictionPolicy[string, int](PolicySampledLRU),
+		WithEvictSampleSize[string, int](20),
+	)
+	if !tc.sharedAccess {
+		t.Fatal("PolicySampledLRU doesn't let reads share the lock")
+	}
+	for i := 0; i < 10; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				tc.Get(strconv.Itoa(i))
+				tc.GetMany([]string{strconv.Itoa(i)})
+			}
+		}()
+	}
+	wg.Wait()
+	var evicted []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+	for i := 10; i < 15; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	// The sample is larger than the cache, so the unread items are evicted in
+	// the order they were stored in, as with PolicyLRU.
+	if !reflect.DeepEqual(evicted, []string{"5", "6", "7", "8", "9"}) {
+		t.Error("Unexpected evictions:", evicted)
+	}
+}
+
+func TestCost(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0,
+		WithCost[string, string](func(v string) int64 { return int64(len(v)) }, 10),
+	)
+	var evicted []string
+	tc.OnEvicted(func(k string, v string, reason EvictionReason) {
+		if reason == ReasonCapacity {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.Set("a", "aaaa", DefaultExpiration)
+	tc.Set("b", "bbbb", DefaultExpiration)
+	if n := tc.Cost(); n != 8 {
+		t.Errorf("Cost is not 8: %d", n)
+	}
+	tc.Replace("a", "a", DefaultExpiration)
+	if n := tc.Cost(); n != 5 {
+		t.Errorf("Cost is not 5 after replacing a: %d", n)
+	}
+	// a was touched by Replace, so b is the least recently used item.
+	tc.Set("c", "cccccc", DefaultExpiration)
+	if _, found := tc.Get("b"); found {
+		t.Error("b was found, but it should have been evicted to make room for c")
+	}
+	if n := tc.Cost(); n != 7 {
+		t.Errorf("Cost is not 7 after evicting b: %d", n)
+	}
+	tc.Delete("c")
+	if n := tc.Cost(); n != 1 {
+		t.Errorf("Cost is not 1 after deleting c: %d", n)
+	}
+	tc.Flush()
+	if n := tc.Cost(); n != 0 {
+		t.Errorf("Cost is not 0 after flushing: %d", n)
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Error("Unexpected evictions:", evicted)
+	}
+}
+
+func TestCostOversizedItem(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0,
+		WithCost[string, string](func(v string) int64 { return int64(len(v)) }, 10),
+	)
+	tc.Set("a", "aaaa", DefaultExpiration)
+	tc.Set("big", "this value is too large", DefaultExpiration)
+	if _, found := tc.Get("big"); found {
+		t.Error("big was found, but it exceeds the cache's maximum cost")
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("a was evicted to make room for an item that could never fit")
+	}
+	if n := tc.Cost(); n != 4 {
+		t.Errorf("Cost is not 4: %d", n)
+	}
+}
+
+func TestMaxValueCost(t *testing.T) {
+	var evicted []string
+	tc := New[string, string](DefaultExpiration, 0,
+		WithCost[string, string](func(v string) int64 { return int64(len(v)) }, 0),
+		WithMaxValueCost[string, string](10),
+	)
+	tc.OnEvicted(func(k string, v string, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+	tc.Set("a", "aaaa", DefaultExpiration)
+	tc.Set("z", "zzzz", DefaultExpiration)
+	tc.Set("z", "this value is too large", DefaultExpiration)
+	if x, found := tc.Get("z"); found {
+		t.Error("Set kept either value of z:", x)
+	}
+	if tc.SetIfPresent("a", "this value is too large", DefaultExpiration) {
+		t.Error("SetIfPresent stored a value that is too large")
+	}
+	if tc.SetIfAbsent("b", "this value is too large", DefaultExpiration) {
+		t.Error("SetIfAbsent stored a value that is too large")
+	}
+	if err := tc.Add("b", "this value is too large", DefaultExpiration); !errors.Is(err, ErrValueTooLarge) {
+		t.Error("Add didn't return ErrValueTooLarge:", err)
+	}
+	if err := tc.Replace("a", "this value is too large", DefaultExpiration); !errors.Is(err, ErrValueTooLarge) {
+		t.Error("Replace didn't return ErrValueTooLarge:", err)
+	}
+	if err := tc.Add("b", "bb", DefaultExpiration); err != nil {
+		t.Error("Couldn't add a small value:", err)
+	}
+	tc.SetMany(map[string]string{"c": "this value is too large"}, DefaultExpiration)
+	if _, found := tc.Get("c"); found {
+		t.Error("SetMany kept a value that is too large")
+	}
+	if x, _ := tc.Get("a"); x != "aaaa" {
+		t.Error("a was changed by a value that is too large:", x)
+	}
+	if len(evicted) != 2 || evicted[0] != "z" || evicted[1] != "c" {
+		t.Error("Unexpected evictions:", evicted)
+	}
+	if n := tc.Cost(); n != 6 {
+		t.Errorf("Cost is not 6: %d", n)
+	}
+}
+
+func TestOnBeforeEvict(t *testing.T) {
+	var evicted []string
+	tc := New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](2))
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+	vetoes := 0
+	tc.OnBeforeEvict(func(k string, v int) bool {
+		if v < 0 {
+			vetoes++
+			return false
+		}
+		return true
+	})
+	tc.Set("keep", -1, DefaultExpiration)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	if len(evicted) != 1 || evicted[0] != "a" || vetoes != 1 {
+		t.Fatalf("The vetoed item wasn't skipped: %v evicted after %d vetoes", evicted, vetoes)
+	}
+	// keep was treated as just stored, so b is now the first candidate.
+	tc.Set("c", 3, DefaultExpiration)
+	if len(evicted) != 2 || evicted[1] != "b" {
+		t.Fatal("Unexpected evictions:", evicted)
+	}
+
+	// If every candidate is vetoed, they are evicted anyway.
+	tc.Set("c", -3, DefaultExpiration)
+	tc.Set("d", -4, DefaultExpiration)
+	if len(evicted) != 3 || tc.ItemCount() != 2 {
+		t.Errorf("The limit wasn't enforced when every candidate was vetoed: %v evicted, %d left", evicted, tc.ItemCount())
+	}
+
+	tc.OnBeforeEvict(nil)
+	tc.Set("e", 5, DefaultExpiration)
+	if len(evicted) != 4 {
+		t.Error("An item was vetoed after the veto function was unset:", evicted)
+	}
+}
+
+func TestLRUKeys(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0,
+		WithMaxItems[string, int](10),
+		WithClock[string, int](clock))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	tc.Set("c", 3, NoExpiration)
+	tc.Set("d", 4, NoExpiration)
+	tc.Get("a")
+	tc.Get("b")
+	if got := strings.Join(tc.LRUKeys(10), ","); got != "c,d,a,b" {
+		t.Error("LRUKeys is not c,d,a,b:", got)
+	}
+	if got := strings.Join(tc.LRUKeys(2), ","); got != "c,d" {
+		t.Error("LRUKeys(2) is not c,d:", got)
+	}
+	if got := strings.Join(tc.LRUKeys(10), ","); got != "c,d,a,b" {
+		t.Error("LRUKeys changed the order:", got)
+	}
+	clock.Advance(2 * time.Minute)
+	if got := strings.Join(tc.LRUKeys(10), ","); got != "c,d,b" {
+		t.Error("LRUKeys returned an expired key:", got)
+	}
+
+	lfu := New[string, int](DefaultExpiration, 0,
+		WithMaxItems[string, int](10),
+		WithEvictionPolicy[string, int](PolicyLFU))
+	lfu.Set("a", 1, DefaultExpiration)
+	lfu.Set("b", 2, DefaultExpiration)
+	lfu.Set("c", 3, DefaultExpiration)
+	lfu.Get("a")
+	lfu.Get("a")
+	lfu.Get("c")
+	if got := strings.Join(lfu.LRUKeys(10), ","); got != "b,c,a" {
+		t.Error("LFU LRUKeys is not b,c,a:", got)
+	}
+
+	unbounded := New[string, int](DefaultExpiration, 0)
+	unbounded.Set("a", 1, DefaultExpiration)
+	unbounded.Set("b", 2, DefaultExpiration)
+	if got := len(unbounded.LRUKeys(10)); got != 2 {
+		t.Error("LRUKeys of an unbounded cache returned", got, "keys instead of 2")
+	}
+}
+
+func TestShardedLRUKeys(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 2,
+		WithMaxItems[int, int](10),
+		WithHashFunc[int, int](func(k int) uint32 { return uint32(k) }))
+	for i := 0; i < 6; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	got := tc.LRUKeys(4)
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LRUKeys(4) is %v, want %v", got, want)
+	}
+	if got := tc.LRUKeys(10); len(got) != 6 {
+		t.Error("LRUKeys(10) didn't return all 6 keys:", got)
+	}
+}
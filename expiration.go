@@ -0,0 +1,302 @@
+package ttlcache
+
+import (
+	insecurerand "math/rand"
+	"reflect"
+	"time"
+)
+
+// expiration returns the Item.Expiration for an item stored now with the
+// duration d, resolving DefaultExpiration and NoExpiration.
+func (c *cache[K, V]) expiration(d time.Duration) int64 {
+	if d == DefaultExpiration {
+		d = c.defaultExpiration
+	}
+	if d > 0 {
+		e := c.now() + int64(d)
+		if c.jitter > 0 {
+			e += c.jitterOffset()
+		}
+		return e
+	}
+	return 0
+}
+
+// Expirer is implemented by values that know when they expire. A value
+// implementing it that is stored with DefaultExpiration, by Set or any of the
+// other methods that take a duration, expires at the time returned by
+// ExpireAt instead of after the cache's default expiration time. Any other
+// duration, including NoExpiration, takes precedence over ExpireAt, as does
+// an explicit deadline, e.g. given to SetWithDeadline; and a zero time means
+// the value has no expiration of its own, so the default applies. The
+// expiration time is read once, when the value is stored.
+type Expirer interface {
+	ExpireAt() time.Time
+}
+
+// expireAt returns the Item.Expiration of x if it implements Expirer, and 0
+// otherwise.
+func expireAt[V any](x V) int64 {
+	if xe, ok := any(x).(Expirer); ok {
+		if t := xe.ExpireAt(); !t.IsZero() {
+			return t.UnixNano()
+		}
+	}
+	return 0
+}
+
+// mayImplement reports whether values of type V may implement the interface
+// I: either V implements it, or V is an interface type, whose dynamic values
+// may. It lets the cache skip the type assertion for other types.
+func mayImplement[V, I any]() bool {
+	t := reflect.TypeFor[V]()
+	return t.Kind() == reflect.Interface || t.Implements(reflect.TypeFor[I]())
+}
+
+// valueExpiration is like expiration, but for storing x: for
+// DefaultExpiration, it returns the expiration of x if x implements Expirer.
+func (c *cache[K, V]) valueExpiration(x V, d time.Duration) int64 {
+	if d == DefaultExpiration && c.expirer {
+		if e := expireAt(x); e != 0 {
+			return e
+		}
+	}
+	return c.expiration(d)
+}
+
+// jitterOffset returns a random offset between 0 and c.jitter, in nanoseconds.
+func (c *cache[K, V]) jitterOffset() int64 {
+	return insecurerand.Int63n(int64(c.jitter) + 1)
+}
+
+// SetWithDeadline sets an item to the cache, replacing any existing item, so
+// that it expires at the given deadline rather than after a duration. A zero
+// deadline means the item never expires. A deadline that has already passed
+// stores an item that is expired right away.
+func (c *cache[K, V]) SetWithDeadline(k K, x V, deadline time.Time) {
+	var e int64
+	if !deadline.IsZero() {
+		e = deadline.UnixNano()
+	}
+	c.mu.Lock()
+	c.setItem(k, x, e)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+}
+
+// GetAndRefresh gets an item from the cache like Get, and on a hit resets its
+// expiration time to d from now, implementing sliding expiration. If d is 0
+// (DefaultExpiration), the cache's default expiration time is used; if it is
+// negative (e.g. NoExpiration), the item never expires. Like Get it counts as
+// a hit or a miss in Stats, but unlike Get it always takes the write lock,
+// since it modifies the item.
+func (c *cache[K, V]) GetAndRefresh(k K, d time.Duration) (V, bool) {
+	c.mu.Lock()
+	v, found := c.get(k)
+	if !found {
+		c.mu.Unlock()
+		c.stats.misses.Add(1)
+		var zero V
+		return zero, false
+	}
+	item := c.items[k]
+	item.Expiration = c.expiration(d)
+	c.items[k] = item
+	if c.policy != nil {
+		c.policy.access(k)
+	}
+	exhausted := c.use(k)
+	f := c.onEvicted
+	c.mu.Unlock()
+	c.stats.hits.Add(1)
+	if exhausted {
+		c.notifyExhausted(f, k, v)
+	}
+	return c.decoded(v), true
+}
+
+// GetWithRefreshThreshold gets an item from the cache like Get, and if less
+// than threshold is left until it expires, resets its expiration time to d
+// from now, with d interpreted as in GetAndRefresh. This implements sliding
+// expiration without a write on every read: the lookup only takes the read
+// lock (unless the cache has an eviction policy), and the write lock is taken
+// only to refresh the item. Since the item may have been replaced, refreshed
+// or deleted in between, it is looked up again under the write lock, and only
+// refreshed if it still exists and is still below the threshold. Items that
+// never expire are never refreshed.
+func (c *cache[K, V]) GetWithRefreshThreshold(k K, d, threshold time.Duration) (V, bool) {
+	v, ttl, found := c.GetWithTTL(k)
+	if !found || ttl == NoExpiration || ttl >= threshold {
+		return v, found
+	}
+	c.mu.Lock()
+	item, found := c.items[k]
+	now := c.now()
+	if found && item.Expiration > 0 && now <= item.Expiration && c.remaining(item.Expiration, now) < threshold {
+		item.Expiration = c.expiration(d)
+		c.items[k] = item
+	}
+	c.mu.Unlock()
+	return v, true
+}
+
+// SetWithDeadline sets an item to the cache that expires at the given
+// deadline. See Cache.SetWithDeadline.
+func (sc *shardedCache[K, V]) SetWithDeadline(k K, x V, deadline time.Time) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetWithDeadline(k, x, deadline)
+}
+
+// GetAndRefresh gets an item and resets its expiration time. See
+// Cache.GetAndRefresh. Only the write lock of the shard holding k is taken, so
+// refreshing items in other shards isn't blocked.
+func (sc *shardedCache[K, V]) GetAndRefresh(k K, d time.Duration) (V, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetAndRefresh(k, d)
+}
+
+// Touch resets the expiration time of an existing, unexpired item to d from
+// now without reading it, and reports whether the item was found. The
+// duration is interpreted as in GetAndRefresh. Touching an item doesn't count
+// as a use for the eviction policy.
+func (c *cache[K, V]) Touch(k K, d time.Duration) bool {
+	c.mu.Lock()
+	_, found := c.get(k)
+	if found {
+		item := c.items[k]
+		item.Expiration = c.expiration(d)
+		c.items[k] = item
+	}
+	c.mu.Unlock()
+	return found
+}
+
+// ExpireAt sets the expiration time of an existing, unexpired item to t,
+// without changing its value, and reports whether the item was found. A zero t
+// makes the item never expire, and a t that has already passed expires it
+// right away. It is the absolute-time counterpart of Touch and, like it,
+// doesn't count as a use of the item.
+func (c *cache[K, V]) ExpireAt(k K, t time.Time) bool {
+	var e int64
+	if !t.IsZero() {
+		e = t.UnixNano()
+	}
+	c.mu.Lock()
+	_, found := c.get(k)
+	if found {
+		item := c.items[k]
+		item.Expiration = e
+		c.items[k] = item
+	}
+	c.mu.Unlock()
+	return found
+}
+
+// ExpireAt sets the expiration time of an existing item to t. See
+// Cache.ExpireAt.
+func (sc *shardedCache[K, V]) ExpireAt(k K, t time.Time) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).ExpireAt(k, t)
+}
+
+// GetWithRefreshThreshold gets an item and resets its expiration time if it
+// is close to expiring. See Cache.GetWithRefreshThreshold.
+func (sc *shardedCache[K, V]) GetWithRefreshThreshold(k K, d, threshold time.Duration) (V, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetWithRefreshThreshold(k, d, threshold)
+}
+
+// Touch resets the expiration time of an existing item. See Cache.Touch.
+func (sc *shardedCache[K, V]) Touch(k K, d time.Duration) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).Touch(k, d)
+}
+
+// TTL returns the time remaining until the item k expires, and whether it was
+// found (and hasn't expired). For an item that never expires it returns
+// NoExpiration and true. TTL only takes the read lock.
+func (c *cache[K, V]) TTL(k K) (time.Duration, bool) {
+	c.mu.RLock()
+	item, found := c.items[k]
+	if !found {
+		c.mu.RUnlock()
+		return 0, false
+	}
+	if item.Expiration <= 0 {
+		c.mu.RUnlock()
+		return NoExpiration, true
+	}
+	remaining := item.Expiration - c.now()
+	c.mu.RUnlock()
+	if remaining < 0 {
+		return 0, false
+	}
+	return time.Duration(remaining), true
+}
+
+// GetWithTTL gets an item from the cache like Get, together with the time
+// remaining until it expires, from a single lookup, so that the two can't be
+// torn apart by the item expiring or being replaced in between. For an item
+// that never expires the duration is NoExpiration. If k is not found, or has
+// expired, it returns the zero value of V, 0 and false.
+func (c *cache[K, V]) GetWithTTL(k K) (V, time.Duration, bool) {
+	if c.lockedReads() {
+		item, found := c.getAndTrack(k)
+		if !found {
+			return item.Object, 0, false
+		}
+		return c.decoded(item.Object), c.remaining(item.Expiration, c.now()), true
+	}
+	c.mu.RLock()
+	item, found := c.items[k]
+	now := c.now()
+	if !found || (item.Expiration > 0 && now > item.Expiration) {
+		c.mu.RUnlock()
+		c.stats.misses.Add(1)
+		if found && c.deleteOnGet {
+			c.deleteIfExpired(k)
+		}
+		var zero V
+		return zero, 0, false
+	}
+	if c.policy != nil {
+		c.policy.access(k)
+	}
+	c.mu.RUnlock()
+	c.stats.hits.Add(1)
+	return c.decoded(item.Object), c.remaining(item.Expiration, now), true
+}
+
+// remaining returns the time left at now until the expiration time e, or
+// NoExpiration if e is not set.
+func (c *cache[K, V]) remaining(e, now int64) time.Duration {
+	if e <= 0 {
+		return NoExpiration
+	}
+	if now > e {
+		return 0
+	}
+	return time.Duration(e - now)
+}
+
+// GetWithTTL gets an item and the time remaining until it expires. See
+// Cache.GetWithTTL.
+func (sc *shardedCache[K, V]) GetWithTTL(k K) (V, time.Duration, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetWithTTL(k)
+}
+
+// TTL returns the time remaining until an item expires. See Cache.TTL.
+func (sc *shardedCache[K, V]) TTL(k K) (time.Duration, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).TTL(k)
+}
@@ -0,0 +1,325 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	if _, found := tc.GetAndRefresh("a", DefaultExpiration); found {
+		t.Error("Refreshed a even though it doesn't exist")
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+
+	clock.Advance(50 * time.Second)
+	if x, found := tc.GetAndRefresh("a", DefaultExpiration); !found || x != 1 {
+		t.Error("Couldn't refresh a:", x)
+	}
+	if _, found := tc.GetAndRefresh("b", 2*time.Minute); !found {
+		t.Error("Couldn't refresh b")
+	}
+	if _, found := tc.GetAndRefresh("c", NoExpiration); !found {
+		t.Error("Couldn't refresh c")
+	}
+
+	clock.Advance(50 * time.Second)
+	if _, found := tc.Get("a"); !found {
+		t.Error("a expired even though it was refreshed")
+	}
+	clock.Advance(20 * time.Second)
+	if _, found := tc.Get("a"); found {
+		t.Error("a was found after its refreshed expiration time")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b expired before its refreshed expiration time")
+	}
+	clock.Advance(time.Hour)
+	if _, found := tc.GetAndRefresh("b", DefaultExpiration); found {
+		t.Error("Refreshed b even though it has expired")
+	}
+	if _, expiration, found := tc.GetWithExpiration("c"); !found || !expiration.IsZero() {
+		t.Error("c was not made non-expiring")
+	}
+}
+
+func TestGetAndRefreshStats(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.GetAndRefresh("a", DefaultExpiration)
+	tc.GetAndRefresh("a", DefaultExpiration)
+	tc.GetAndRefresh("b", DefaultExpiration)
+	if s := tc.Stats(); s.Hits != 2 || s.Misses != 1 {
+		t.Errorf("Stats are %d hits and %d misses, not 2 and 1", s.Hits, s.Misses)
+	}
+}
+
+func TestShardedGetAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock))
+	tc.Set("a", 1, DefaultExpiration)
+	clock.Advance(50 * time.Second)
+	tc.GetAndRefresh("a", DefaultExpiration)
+	clock.Advance(50 * time.Second)
+	if _, found := tc.Get("a"); !found {
+		t.Error("a expired even though it was refreshed")
+	}
+}
+
+func TestGetWithRefreshThreshold(t *testing.T) {
+	clock := newFakeClock()
+	for _, tc := range []interface {
+		Set(string, int, time.Duration)
+		TTL(string) (time.Duration, bool)
+		GetWithRefreshThreshold(string, time.Duration, time.Duration) (int, bool)
+	}{
+		New[string, int](time.Minute, 0, WithClock[string, int](clock)),
+		NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock)),
+	} {
+		if _, found := tc.GetWithRefreshThreshold("a", DefaultExpiration, 10*time.Second); found {
+			t.Error("Found a even though it doesn't exist")
+		}
+		tc.Set("a", 1, DefaultExpiration)
+		tc.Set("b", 2, NoExpiration)
+		clock.Advance(30 * time.Second)
+		if x, found := tc.GetWithRefreshThreshold("a", DefaultExpiration, 10*time.Second); !found || x != 1 {
+			t.Error("a wasn't found:", x, found)
+		}
+		if ttl, _ := tc.TTL("a"); ttl != 30*time.Second {
+			t.Error("a was refreshed above the threshold; its TTL is", ttl)
+		}
+		clock.Advance(25 * time.Second)
+		tc.GetWithRefreshThreshold("a", DefaultExpiration, 10*time.Second)
+		if ttl, _ := tc.TTL("a"); ttl != time.Minute {
+			t.Error("a wasn't refreshed below the threshold; its TTL is", ttl)
+		}
+		tc.GetWithRefreshThreshold("b", time.Second, 10*time.Second)
+		if ttl, _ := tc.TTL("b"); ttl != NoExpiration {
+			t.Error("The item that never expires was given a TTL of", ttl)
+		}
+		clock.Advance(2 * time.Minute)
+		if _, found := tc.GetWithRefreshThreshold("a", DefaultExpiration, 10*time.Second); found {
+			t.Error("An expired item was found and refreshed")
+		}
+	}
+}
+
+func TestTouch(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock))
+	if tc.Touch("a", DefaultExpiration) {
+		t.Error("Touched a even though it doesn't exist")
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	clock.Advance(50 * time.Second)
+	if !tc.Touch("a", DefaultExpiration) {
+		t.Error("Couldn't touch a")
+	}
+	if !tc.Touch("b", NoExpiration) {
+		t.Error("Couldn't touch b")
+	}
+	clock.Advance(50 * time.Second)
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Error("a expired even though it was touched")
+	}
+	clock.Advance(time.Hour)
+	if tc.Touch("a", DefaultExpiration) {
+		t.Error("Touched a even though it has expired")
+	}
+	if _, expiration, found := tc.GetWithExpiration("b"); !found || !expiration.IsZero() {
+		t.Error("b was not made non-expiring")
+	}
+}
+
+func TestExpireAt(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	if tc.ExpireAt("a", clock.Now().Add(time.Hour)) {
+		t.Error("Set the expiration of a even though it doesn't exist")
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, NoExpiration)
+	deadline := clock.Now().Add(time.Hour)
+	if !tc.ExpireAt("a", deadline) || !tc.ExpireAt("b", time.Time{}) {
+		t.Fatal("Couldn't set the expiration of a and b")
+	}
+	if !tc.ExpireAt("c", clock.Now().Add(-time.Second)) {
+		t.Fatal("Couldn't set the expiration of c")
+	}
+	clock.Advance(2 * time.Minute)
+	if v, e, found := tc.GetWithExpiration("a"); !found || v != 1 || !e.Equal(deadline) {
+		t.Error("a doesn't expire at the new deadline:", v, e, found)
+	}
+	if _, e, found := tc.GetWithExpiration("b"); !found || !e.IsZero() {
+		t.Error("b was not made non-expiring:", e, found)
+	}
+	if tc.Has("c") {
+		t.Error("c didn't expire at a deadline in the past")
+	}
+	if tc.ExpireAt("c", time.Time{}) {
+		t.Error("Set the expiration of c even though it has expired")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	if _, found := tc.TTL("a"); found {
+		t.Error("Found a TTL for a even though it doesn't exist")
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	clock.Advance(20 * time.Second)
+	if ttl, found := tc.TTL("a"); !found || ttl != 40*time.Second {
+		t.Error("TTL of a is not 40s:", ttl)
+	}
+	if ttl, found := tc.TTL("b"); !found || ttl != NoExpiration {
+		t.Error("TTL of b is not NoExpiration:", ttl)
+	}
+	clock.Advance(time.Minute)
+	if ttl, found := tc.TTL("a"); found {
+		t.Error("Found a TTL for a even though it has expired:", ttl)
+	}
+}
+
+func TestGetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	for name, opts := range map[string][]Option[string, int]{
+		"default": {WithClock[string, int](clock)},
+		"bounded": {WithClock[string, int](clock), WithMaxItems[string, int](10)},
+	} {
+		tc := New[string, int](time.Minute, 0, opts...)
+		if _, _, found := tc.GetWithTTL("a"); found {
+			t.Errorf("%s: found a even though it doesn't exist", name)
+		}
+		tc.Set("a", 1, DefaultExpiration)
+		tc.Set("b", 2, NoExpiration)
+		clock.Advance(20 * time.Second)
+		if v, ttl, found := tc.GetWithTTL("a"); !found || v != 1 || ttl != 40*time.Second {
+			t.Errorf("%s: a is %d with a TTL of %v, want 1 and 40s", name, v, ttl)
+		}
+		if v, ttl, found := tc.GetWithTTL("b"); !found || v != 2 || ttl != NoExpiration {
+			t.Errorf("%s: b is %d with a TTL of %v, want 2 and NoExpiration", name, v, ttl)
+		}
+		clock.Advance(time.Minute)
+		if _, _, found := tc.GetWithTTL("a"); found {
+			t.Errorf("%s: found a even though it has expired", name)
+		}
+		if s := tc.Stats(); s.Hits != 2 || s.Misses != 2 {
+			t.Errorf("%s: got %d hits and %d misses, want 2 and 2", name, s.Hits, s.Misses)
+		}
+	}
+}
+
+func TestSetWithDeadline(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, 0, 2, WithClock[string, int](clock))
+	deadline := clock.Now().Add(90 * time.Second)
+	tc.SetWithDeadline("a", 1, deadline)
+	tc.SetWithDeadline("b", 2, time.Time{})
+	tc.SetWithDeadline("c", 3, clock.Now().Add(-time.Second))
+
+	if _, expiration, found := tc.GetWithExpiration("a"); !found || !expiration.Equal(deadline) {
+		t.Errorf("Expiration of a is %v, not %v", expiration, deadline)
+	}
+	if _, expiration, found := tc.GetWithExpiration("b"); !found || !expiration.IsZero() {
+		t.Error("b was given an expiration time:", expiration)
+	}
+	if _, found := tc.Get("c"); found {
+		t.Error("c was found even though its deadline has passed")
+	}
+	clock.Advance(91 * time.Second)
+	if _, found := tc.Get("a"); found {
+		t.Error("a was found after its deadline")
+	}
+}
+
+func TestExpirationJitter(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[int, int](time.Minute, 0,
+		WithClock[int, int](clock),
+		WithExpirationJitter[int, int](10*time.Second))
+	base := clock.Now().Add(time.Minute)
+	distinct := map[time.Time]bool{}
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			tc.Set(i, i, DefaultExpiration)
+		} else {
+			tc.SetMany(map[int]int{i: i}, DefaultExpiration)
+		}
+		_, expiration, _ := tc.GetWithExpiration(i)
+		if expiration.Before(base) || expiration.After(base.Add(10*time.Second)) {
+			t.Errorf("Expiration of %d is %v, outside of the jitter bounds", i, expiration)
+		}
+		distinct[expiration] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("All expiration times are the same despite the jitter")
+	}
+
+	tc.Set(-1, -1, NoExpiration)
+	if _, expiration, _ := tc.GetWithExpiration(-1); !expiration.IsZero() {
+		t.Error("A non-expiring item was given an expiration time:", expiration)
+	}
+	deadline := clock.Now().Add(time.Hour)
+	tc.SetWithDeadline(-2, -2, deadline)
+	if _, expiration, _ := tc.GetWithExpiration(-2); !expiration.Equal(deadline) {
+		t.Error("A deadline was jittered:", expiration)
+	}
+}
+
+type lease struct {
+	id       int
+	deadline time.Time
+}
+
+func (l lease) ExpireAt() time.Time {
+	return l.deadline
+}
+
+func TestExpirer(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, lease](time.Minute, 0, WithClock[string, lease](clock))
+	deadline := clock.Now().Add(time.Hour)
+	tc.Set("own", lease{1, deadline}, DefaultExpiration)
+	tc.Set("explicit", lease{2, deadline}, time.Second)
+	tc.Set("permanent", lease{3, deadline}, NoExpiration)
+	tc.Set("default", lease{4, time.Time{}}, DefaultExpiration)
+	if err := tc.Add("added", lease{5, deadline}, DefaultExpiration); err != nil {
+		t.Fatal("Couldn't add added:", err)
+	}
+
+	for _, k := range []string{"own", "added"} {
+		if _, e, _ := tc.GetWithExpiration(k); !e.Equal(deadline) {
+			t.Errorf("%s doesn't expire at its own deadline: %v", k, e)
+		}
+	}
+	if _, e, _ := tc.GetWithExpiration("explicit"); !e.Equal(clock.Now().Add(time.Second)) {
+		t.Error("The explicit duration of explicit didn't take precedence:", e)
+	}
+	if _, e, found := tc.GetWithExpiration("permanent"); !found || !e.IsZero() {
+		t.Error("NoExpiration didn't take precedence for permanent:", e)
+	}
+	if _, e, _ := tc.GetWithExpiration("default"); !e.Equal(clock.Now().Add(time.Minute)) {
+		t.Error("default didn't get the default expiration:", e)
+	}
+
+	// Dynamic values of an interface type are consulted as well.
+	sc := NewSharded[string, any](time.Minute, 0, 2, WithClock[string, any](clock))
+	sc.Set("lease", lease{6, deadline}, DefaultExpiration)
+	sc.Set("int", 7, DefaultExpiration)
+	if _, e, _ := sc.GetWithExpiration("lease"); !e.Equal(deadline) {
+		t.Error("The lease in the sharded cache doesn't expire at its deadline:", e)
+	}
+	if _, e, _ := sc.GetWithExpiration("int"); !e.Equal(clock.Now().Add(time.Minute)) {
+		t.Error("int didn't get the default expiration:", e)
+	}
+	if New[string, int](0, 0).expirer {
+		t.Error("A cache of ints looks for Expirer values")
+	}
+}
@@ -0,0 +1,204 @@
+package ttlcache
+
+import (
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// IntegerHash is a hash function for integer keys, suitable for use with
+// WithHashFunc. It uses Fibonacci (multiplicative) hashing, which spreads
+// sequential keys evenly over the shards. Integer keys are hashed the same
+// way by default, but mixed with the cache's seed; IntegerHash places every
+// key in the same shard whatever the seed.
+func IntegerHash[K Integer](k K) uint32 {
+	return fibonacciHash(uint64(k))
+}
+
+// Hashable is implemented by key types that hash themselves. A sharded cache
+// whose key type implements it assigns keys to shards with HashCode, rather
+// than hashing them with reflection. Keys that are equal must have the same
+// HashCode. HashCombine and StringHash help build one from a key's fields:
+//
+//	func (k tenantUser) HashCode() uint32 {
+//		return ttlcache.HashCombine(ttlcache.StringHash(k.Tenant), ttlcache.StringHash(k.User))
+//	}
+type Hashable interface {
+	HashCode() uint32
+}
+
+// StringHash is a hash function for strings (FNV-1a), for use in HashCode
+// methods and with WithHashFunc.
+func StringHash(s string) uint32 {
+	return hashString(fnvOffset32, s)
+}
+
+// HashCombine combines the hashes of a key's fields into a single hash. The
+// result depends on the order of the hashes, so that e.g. the keys {"a", "b"}
+// and {"b", "a"} are unlikely to collide.
+func HashCombine(hashes ...uint32) uint32 {
+	h := uint32(fnvOffset32)
+	for _, x := range hashes {
+		h = mix32(h ^ x)
+	}
+	return h
+}
+
+// newHasher returns the function used by a sharded cache to assign keys to
+// shards: f if one was given, HashCode for keys that implement Hashable, djb33
+// for string keys, integerHasher for keys of integer types, and reflectHash for
+// keys of any other type.
+func newHasher[K comparable](seed uint32, f func(K) uint32) func(K) uint32 {
+	if f != nil {
+		return f
+	}
+	var zero K
+	if _, ok := any(zero).(Hashable); ok {
+		return func(k K) uint32 {
+			return mix32(any(k).(Hashable).HashCode() ^ seed)
+		}
+	}
+	if _, ok := any(zero).(string); ok {
+		return func(k K) uint32 {
+			return djb33(seed, any(k).(string))
+		}
+	}
+	if h := integerHasher[K](seed); h != nil {
+		return h
+	}
+	return func(k K) uint32 {
+		return reflectHash(seed, k)
+	}
+}
+
+// integerHasher returns a seeded Fibonacci hash, like IntegerHash, for keys
+// whose underlying type is an integer type, or nil if K's isn't. The kind and
+// size of K are looked up once, so that hashing a key is only a load and some
+// arithmetic: unlike a type switch on the key, it neither boxes the key nor
+// needs a case per named integer type.
+func integerHasher[K comparable](seed uint32) func(K) uint32 {
+	t := reflect.TypeFor[K]()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		return nil
+	}
+	s := uint64(seed)
+	switch t.Size() {
+	case 1:
+		return func(k K) uint32 {
+			return fibonacciHash(uint64(*(*uint8)(unsafe.Pointer(&k))) ^ s)
+		}
+	case 2:
+		return func(k K) uint32 {
+			return fibonacciHash(uint64(*(*uint16)(unsafe.Pointer(&k))) ^ s)
+		}
+	case 4:
+		return func(k K) uint32 {
+			return fibonacciHash(uint64(*(*uint32)(unsafe.Pointer(&k))) ^ s)
+		}
+	default:
+		return func(k K) uint32 {
+			return fibonacciHash(*(*uint64)(unsafe.Pointer(&k)) ^ s)
+		}
+	}
+}
+
+// fibonacciHash multiplies x by 2^64 divided by the golden ratio and keeps
+// the top half of the product, whose bits depend on all the bits of x.
+func fibonacciHash(x uint64) uint32 {
+	return uint32((x * 0x9e3779b97f4a7c15) >> 32)
+}
+
+// mix32 is the MurmurHash3 finalizer. It spreads the bits of a hash over the
+// whole word, so that a weak HashCode, e.g. one that returns a small integer,
+// still uses all the shards.
+func mix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// reflectHash hashes any comparable value by walking it with reflection and
+// feeding its contents to FNV-1a. Pointers, channels and the like are hashed by
+// address, matching their equality semantics. It is much slower than djb33 or
+// integerHasher, but guarantees that keys of any type are spread over the shards.
+func reflectHash[K comparable](seed uint32, k K) uint32 {
+	h := uint32(fnvOffset32) ^ seed
+	h = hashValue(h, reflect.ValueOf(&k).Elem())
+	return h ^ (h >> 16)
+}
+
+func hashUint64(h uint32, x uint64) uint32 {
+	for i := 0; i < 8; i++ {
+		h ^= uint32(byte(x))
+		h *= fnvPrime32
+		x >>= 8
+	}
+	return h
+}
+
+func hashString(h uint32, s string) uint32 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= fnvPrime32
+	}
+	return h
+}
+
+func hashValue(h uint32, v reflect.Value) uint32 {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return hashUint64(h, 1)
+		}
+		return hashUint64(h, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			f = 0 // -0 and +0 are equal, so they must hash the same
+		}
+		return hashUint64(h, math.Float64bits(f))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		h = hashValue(h, reflect.ValueOf(real(c)))
+		return hashValue(h, reflect.ValueOf(imag(c)))
+	case reflect.String:
+		return hashString(h, v.String())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = hashValue(h, v.Index(i))
+		}
+		return h
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h = hashValue(h, v.Field(i))
+		}
+		return h
+	case reflect.Interface:
+		if v.IsNil() {
+			return hashUint64(h, 0)
+		}
+		e := v.Elem()
+		h = hashString(h, e.Type().String())
+		return hashValue(h, e)
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return hashUint64(h, uint64(v.Pointer()))
+	}
+	// Other kinds (funcs, maps and slices) aren't comparable, so they can't
+	// be part of a key.
+	return h
+}
@@ -0,0 +1,155 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+)
+
+type compositeKey struct {
+	Tenant string
+	User   int
+}
+
+func TestReflectHash(t *testing.T) {
+	a := reflectHash[compositeKey](1, compositeKey{"foo", 1})
+	b := reflectHash[compositeKey](1, compositeKey{"foo", 1})
+	if a != b {
+		t.Error("Equal keys have different hashes")
+	}
+	if c := reflectHash[compositeKey](1, compositeKey{"foo", 2}); a == c {
+		t.Error("Different keys have the same hash")
+	}
+	if c := reflectHash[compositeKey](2, compositeKey{"foo", 1}); a == c {
+		t.Error("The hash doesn't depend on the seed")
+	}
+	if reflectHash[float64](1, 0) != reflectHash[float64](1, -1*0.0) {
+		t.Error("0 and -0 have different hashes")
+	}
+	var x, y any = 1, "1"
+	if reflectHash[any](1, x) == reflectHash[any](1, y) {
+		t.Error("Interface keys with different dynamic types have the same hash")
+	}
+}
+
+func TestShardedCacheSpreadsKeys(t *testing.T) {
+	tcs := map[string]*ShardedCache[compositeKey, int]{
+		"reflect": NewSharded[compositeKey, int](DefaultExpiration, 0, 8),
+		"custom": NewSharded[compositeKey, int](DefaultExpiration, 0, 8,
+			WithHashFunc[compositeKey, int](func(k compositeKey) uint32 {
+				return IntegerHash(k.User)
+			}),
+		),
+	}
+	for name, tc := range tcs {
+		for i := 0; i < 1000; i++ {
+			tc.Set(compositeKey{"foo", i}, i, DefaultExpiration)
+		}
+		for i, v := range tc.cs {
+			if n := v.ItemCount(); n == 0 || n == 1000 {
+				t.Errorf("%s: shard %d holds %d of 1000 items", name, i, n)
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			if x, found := tc.Get(compositeKey{"foo", i}); !found || x != i {
+				t.Errorf("%s: key %d was not found", name, i)
+			}
+		}
+	}
+}
+
+func TestIntegerHash(t *testing.T) {
+	counts := make([]int, 16)
+	for i := 0; i < 1600; i++ {
+		counts[IntegerHash(i)%16]++
+	}
+	for i, n := range counts {
+		if n < 50 || n > 150 {
+			t.Errorf("Bucket %d holds %d of 1600 sequential keys", i, n)
+		}
+	}
+}
+
+type userID uint16
+
+func TestIntegerHasher(t *testing.T) {
+	if integerHasher[string](1) != nil || integerHasher[float64](1) != nil {
+		t.Error("An integer hasher was returned for a non-integer key type")
+	}
+	h64, h8, hu := integerHasher[int64](1), integerHasher[int8](1), integerHasher[userID](1)
+	if h64 == nil || h8 == nil || hu == nil {
+		t.Fatal("No integer hasher was returned for an integer key type")
+	}
+	if h64(-1) == h64(1) || h8(-1) == h8(1) || hu(1) == hu(2) {
+		t.Error("Different keys have the same hash")
+	}
+	if integerHasher[int64](2)(5) == h64(5) {
+		t.Error("The hash doesn't depend on the seed")
+	}
+	if n := testing.AllocsPerRun(100, func() { h64(12345) }); n != 0 {
+		t.Error("Hashing an integer key allocates:", n)
+	}
+
+	counts := make([]int, 16)
+	for i := 0; i < 1600; i++ {
+		counts[hu(userID(i))%16]++
+	}
+	for i, n := range counts {
+		if n < 50 || n > 150 {
+			t.Errorf("Bucket %d holds %d of 1600 sequential keys", i, n)
+		}
+	}
+}
+
+func BenchmarkIntegerKeyHash(b *testing.B) {
+	for name, h := range map[string]func(int) uint32{
+		"integer": integerHasher[int](1),
+		"reflect": func(k int) uint32 { return reflectHash(1, k) },
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h(i)
+			}
+		})
+	}
+}
+
+type hashableKey struct {
+	Tenant, User string
+}
+
+var hashCodeCalls int
+
+func (k hashableKey) HashCode() uint32 {
+	hashCodeCalls++
+	return HashCombine(StringHash(k.Tenant), StringHash(k.User))
+}
+
+func TestHashable(t *testing.T) {
+	tc := NewSharded[hashableKey, int](DefaultExpiration, 0, 8)
+	hashCodeCalls = 0
+	for i := 0; i < 1000; i++ {
+		tc.Set(hashableKey{"foo", strconv.Itoa(i)}, i, DefaultExpiration)
+	}
+	if hashCodeCalls != 1000 {
+		t.Errorf("HashCode was called %d times instead of 1000", hashCodeCalls)
+	}
+	for i, v := range tc.cs {
+		if n := v.ItemCount(); n == 0 || n == 1000 {
+			t.Errorf("Shard %d holds %d of 1000 items", i, n)
+		}
+	}
+	if v, found := tc.Get(hashableKey{"foo", "42"}); !found || v != 42 {
+		t.Error("Couldn't get a Hashable key:", v, found)
+	}
+}
+
+func TestHashCombine(t *testing.T) {
+	a, b := StringHash("a"), StringHash("b")
+	if HashCombine(a, b) == HashCombine(b, a) {
+		t.Error("HashCombine doesn't depend on the order of the hashes")
+	}
+	if HashCombine(a, b) != HashCombine(a, b) {
+		t.Error("HashCombine isn't deterministic")
+	}
+}
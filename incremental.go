@@ -0,0 +1,117 @@
+package ttlcache
+
+import "time"
+
+// tick runs one cleanup for the janitor: a sweep of the whole cache, or of the
+// next batch of items if the cache was created with WithCleanupBatchSize.
+func (c *cache[K, V]) tick() {
+	if c.cfg.cleanupBatch <= 0 {
+		c.sweep()
+		return
+	}
+	start := time.Now()
+	n, _, _ := c.sweepBatch(c.cfg.cleanupBatch)
+	if f := c.onCleanup.Load(); f != nil {
+		(*f)(n, time.Since(start))
+	}
+}
+
+// sweepBatch visits up to limit items of the janitor's current cycle through the
+// cache, starting a new cycle if the last one is finished, and deletes the
+// expired ones. It returns the number of items deleted and visited, and
+// whether the cycle is finished. It is only called by the janitor, which is
+// the only user of c.sweepKeys.
+func (c *cache[K, V]) sweepBatch(limit int) (removed, visited int, done bool) {
+	if len(c.sweepKeys) == 0 {
+		c.mu.RLock()
+		keys := make([]K, 0, len(c.items))
+		for k := range c.items {
+			keys = append(keys, k)
+		}
+		c.mu.RUnlock()
+		c.sweepKeys = keys
+	}
+	batch := c.sweepKeys
+	if len(batch) > limit {
+		batch = batch[:limit]
+	}
+	var evictedItems []keyAndValue[K, V]
+	now := c.now()
+	c.mu.Lock()
+	for _, k := range batch {
+		// The item may have been replaced or deleted since the cycle
+		// started.
+		if v, found := c.items[k]; found && v.Expiration > 0 && now > v.Expiration {
+			c.delete(k)
+			removed++
+			if c.onEvicted != nil || c.onEvictedBatch != nil || c.onExpired != nil {
+				evictedItems = append(evictedItems, keyAndValue[K, V]{k, v.Object})
+			}
+		}
+	}
+	f, bf, xf := c.onEvicted, c.onEvictedBatch, c.onExpired
+	c.mu.Unlock()
+	c.sweepKeys = c.sweepKeys[len(batch):]
+	if len(c.sweepKeys) == 0 {
+		// Let the keys of the finished cycle be collected.
+		c.sweepKeys = nil
+		done = true
+	}
+	c.stats.evicted(ReasonExpired, uint64(removed))
+	notifyExpired(f, bf, xf, evictedItems)
+	return removed, len(batch), done
+}
+
+// tick runs one cleanup for the janitor. With WithCleanupBatchSize, it sweeps
+// the shards one after the other, visiting up to the batch size of items in
+// all, and moves on to the next shard once one is done with its cycle. With
+// WithStaggeredCleanup, it sweeps the next shard only.
+func (sc *shardedCache[K, V]) tick() {
+	sc.mu.RLock()
+	defer sc.unlock()
+	budget := sc.cs[0].cfg.cleanupBatch
+	if budget <= 0 {
+		if sc.cs[0].cfg.staggered {
+			sc.sweepNextShard()
+			return
+		}
+		sc.sweepLocked()
+		return
+	}
+	start := time.Now()
+	n := 0
+	for i := 0; i < len(sc.cs) && budget > 0; i++ {
+		removed, visited, done := sc.cs[sc.sweepShard%len(sc.cs)].sweepBatch(budget)
+		n += removed
+		budget -= visited
+		if !done {
+			break
+		}
+		sc.sweepShard = (sc.sweepShard + 1) % len(sc.cs)
+	}
+	sc.cleanedUp(n, start)
+}
+
+// sweepNextShard deletes the expired items of the shard after the one swept by
+// the previous call, and calls the OnCleanup hook, if set. It is only called
+// by the janitor, with sc.mu held.
+func (sc *shardedCache[K, V]) sweepNextShard() {
+	i := sc.sweepShard % len(sc.cs)
+	sc.sweepShard = (i + 1) % len(sc.cs)
+	start := time.Now()
+	_, n := sc.cs[i].deleteExpired(false)
+	sc.cleanedUp(n, start)
+}
+
+// janitorTick returns how often the janitor ticks for the cleanup interval
+// ci: ci itself, or with WithStaggeredCleanup, ci divided by the number of
+// shards, since each tick sweeps a single shard.
+func (sc *shardedCache[K, V]) janitorTick(ci time.Duration) time.Duration {
+	sc.mu.RLock()
+	defer sc.unlock()
+	cfg := &sc.cs[0].cfg
+	if !cfg.staggered || cfg.cleanupBatch > 0 {
+		return ci
+	}
+	return max(ci/time.Duration(len(sc.cs)), 1)
+}
@@ -0,0 +1,86 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIncrementalSweep(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0,
+		WithClock[string, int](clock),
+		WithCleanupBatchSize[string, int](4))
+	var runs, removed int
+	tc.OnCleanup(func(n int, d time.Duration) {
+		runs++
+		removed += n
+	})
+	for i := 0; i < 10; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	tc.Set("forever", -1, NoExpiration)
+	clock.Advance(2 * time.Minute)
+
+	// 11 keys in batches of 4 take three runs.
+	for i := 1; i <= 3; i++ {
+		tc.tick()
+		if left := tc.ItemCountIncludingExpired(); i < 3 && left == 1 {
+			t.Fatalf("The whole cache was swept in %d runs", i)
+		}
+	}
+	if left := tc.ItemCountIncludingExpired(); left != 1 {
+		t.Errorf("%d items left after a full cycle, want 1", left)
+	}
+	if runs != 3 || removed != 10 {
+		t.Errorf("OnCleanup was called %d times with %d items in all", runs, removed)
+	}
+
+	// Items stored during a cycle are visited by the next one.
+	for i := 0; i < 6; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	tc.tick()
+	tc.Set("late", 0, time.Second)
+	clock.Advance(2 * time.Minute)
+	// One run finishes the current cycle, and two more visit the 5 items
+	// left, including late.
+	for i := 0; i < 3; i++ {
+		tc.tick()
+	}
+	if left := tc.ItemCountIncludingExpired(); left != 1 {
+		t.Errorf("%d items left after two cycles, want 1", left)
+	}
+}
+
+func TestShardedIncrementalSweep(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[int, int](time.Minute, 0, 4,
+		WithClock[int, int](clock),
+		WithCleanupBatchSize[int, int](5),
+		WithHashFunc[int, int](func(k int) uint32 { return uint32(k) }))
+	for i := 0; i < 12; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	clock.Advance(2 * time.Minute)
+	want := []int{7, 2, 0}
+	for _, w := range want {
+		tc.tick()
+		if left := tc.ItemCountIncludingExpired(); left != w {
+			t.Fatalf("%d items left, want %d", left, w)
+		}
+	}
+}
+
+func TestFullSweepWithoutBatchSize(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](time.Minute, 0, WithClock[string, int](clock))
+	for i := 0; i < 10; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	clock.Advance(2 * time.Minute)
+	tc.tick()
+	if left := tc.ItemCountIncludingExpired(); left != 0 {
+		t.Error("The janitor didn't sweep the whole cache:", left)
+	}
+}
@@ -0,0 +1,25 @@
+package ttlcache
+
+import "time"
+
+// Interface is the set of operations shared by Cache and ShardedCache, for
+// code that works with either, e.g. choosing between them based on
+// configuration. The constructors return the concrete types, which have many
+// more methods; it is named Interface since Cache is already taken by the
+// standard cache type.
+type Interface[K comparable, V any] interface {
+	Set(k K, x V, d time.Duration)
+	Add(k K, x V, d time.Duration) error
+	Replace(k K, x V, d time.Duration) error
+	Get(k K) (V, bool)
+	Delete(k K)
+	DeleteExpired()
+	Flush()
+	ItemCount() int
+	Close()
+}
+
+var (
+	_ Interface[string, any] = (*Cache[string, any])(nil)
+	_ Interface[string, any] = (*ShardedCache[string, any])(nil)
+)
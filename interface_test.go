@@ -0,0 +1,37 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterface(t *testing.T) {
+	for _, sharded := range []bool{false, true} {
+		var c Interface[string, int]
+		if sharded {
+			c = NewSharded[string, int](DefaultExpiration, 0, 4)
+		} else {
+			c = New[string, int](DefaultExpiration, 0)
+		}
+		c.Set("a", 1, DefaultExpiration)
+		if err := c.Add("a", 2, DefaultExpiration); err == nil {
+			t.Error("Add replaced an existing item, sharded:", sharded)
+		}
+		if err := c.Replace("a", 3, DefaultExpiration); err != nil {
+			t.Error("Couldn't replace a:", err)
+		}
+		c.Set("b", 2, time.Millisecond)
+		<-time.After(5 * time.Millisecond)
+		c.DeleteExpired()
+		if x, found := c.Get("a"); !found || x != 3 || c.ItemCount() != 1 {
+			t.Errorf("Unexpected contents, sharded: %v: a is %v, %v, count %d", sharded, x, found, c.ItemCount())
+		}
+		c.Delete("a")
+		c.Set("c", 3, DefaultExpiration)
+		c.Flush()
+		if n := c.ItemCount(); n != 0 {
+			t.Error("Items are left after Flush:", n)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package ttlcache
+
+// Iterator iterates over the items of a cache without holding its lock in
+// between steps, so that writers aren't blocked however long the iteration
+// takes. It is created by Iterator, and used like this:
+//
+//	it := c.Iterator()
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+//
+// The keys are captured when the Iterator is created, but each value is only
+// looked up as Next reaches it, so the iteration doesn't reflect a single
+// point in time: a value may be newer than the snapshot of the keys, items
+// deleted or expired in the meantime are skipped, and items stored after the
+// Iterator was created are not visited. Looking up the values doesn't count
+// as a hit or miss in Stats, nor as a use of the items by the eviction policy.
+// An Iterator must not be used by several goroutines at once.
+type Iterator[K comparable, V any] struct {
+	keys   []K
+	lookup func(K) (V, bool)
+	key    K
+	value  V
+}
+
+// Next advances the iterator to the next item that is still in the cache, and
+// reports whether there was one.
+func (it *Iterator[K, V]) Next() bool {
+	for len(it.keys) > 0 {
+		k := it.keys[0]
+		it.keys = it.keys[1:]
+		if v, found := it.lookup(k); found {
+			it.key, it.value = k, v
+			return true
+		}
+	}
+	var zeroK K
+	var zeroV V
+	it.key, it.value = zeroK, zeroV
+	return false
+}
+
+// Key returns the key of the current item.
+func (it *Iterator[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current item, as it was when Next reached
+// it.
+func (it *Iterator[K, V]) Value() V {
+	return it.value
+}
+
+// Iterator returns an Iterator over the unexpired items of the cache, which
+// takes the cache's read lock once to capture the keys, and then once per
+// item.
+func (c *cache[K, V]) Iterator() *Iterator[K, V] {
+	return &Iterator[K, V]{keys: c.Keys(), lookup: c.peek}
+}
+
+// peek looks up k like Get, but isn't counted in Stats nor as a use of the
+// item.
+func (c *cache[K, V]) peek(k K) (V, bool) {
+	c.mu.RLock()
+	v, found := c.get(k)
+	c.mu.RUnlock()
+	if found {
+		v = c.decoded(v)
+	}
+	return v, found
+}
+
+// Iterator returns an Iterator over the unexpired items of all shards. The
+// keys are captured one shard at a time, and each item is looked up under the
+// lock of its own shard. See Cache.Iterator.
+func (sc *shardedCache[K, V]) Iterator() *Iterator[K, V] {
+	return &Iterator[K, V]{keys: sc.Keys(), lookup: sc.peek}
+}
+
+// peek looks up k like Get, but isn't counted in Stats nor as a use of the
+// item.
+func (sc *shardedCache[K, V]) peek(k K) (V, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).peek(k)
+}
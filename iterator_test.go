@@ -0,0 +1,43 @@
+package ttlcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIterator(t *testing.T) {
+	for name, tc := range map[string]interface {
+		Set(string, int, time.Duration)
+		Delete(string)
+		Iterator() *Iterator[string, int]
+		Stats() Stats
+	}{
+		"standard": New[string, int](DefaultExpiration, 0),
+		"sharded":  NewSharded[string, int](DefaultExpiration, 0, 4),
+	} {
+		tc.Set("a", 1, DefaultExpiration)
+		tc.Set("b", 2, DefaultExpiration)
+		tc.Set("c", 3, DefaultExpiration)
+		it := tc.Iterator()
+		// Changes made while iterating are seen by the iterator, apart
+		// from new keys.
+		tc.Delete("b")
+		tc.Set("c", 30, DefaultExpiration)
+		tc.Set("d", 4, DefaultExpiration)
+		got := map[string]int{}
+		for it.Next() {
+			got[it.Key()] = it.Value()
+			tc.Set(it.Key(), 0, DefaultExpiration) // doesn't block
+		}
+		if want := map[string]int{"a": 1, "c": 30}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: iterated over %v, want %v", name, got, want)
+		}
+		if it.Next() {
+			t.Errorf("%s: Next returned true after the end", name)
+		}
+		if s := tc.Stats(); s.Hits != 0 || s.Misses != 0 {
+			t.Errorf("%s: iterating counted %d hits and %d misses", name, s.Hits, s.Misses)
+		}
+	}
+}
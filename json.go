@@ -0,0 +1,66 @@
+package ttlcache
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonItem is the JSON representation of an Item.
+type jsonItem[V any] struct {
+	Value      V          `json:"value"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// SnapshotJSON writes the cache's unexpired items to w as a JSON object that
+// maps each key to an object holding its value and, for items that expire,
+// its expiration time as an RFC 3339 timestamp:
+//
+//	{"foo": {"value": "bar", "expiration": "2024-01-02T15:04:05.999999999Z"}, "baz": {"value": "qux"}}
+//
+// K must be usable as a JSON object key, i.e. be a string or integer type or
+// implement encoding.TextMarshaler, and V must be encodable by encoding/json.
+// The snapshot is taken under the cache's read lock, but written after it is
+// released.
+func (c *cache[K, V]) SnapshotJSON(w io.Writer) error {
+	items := c.Items()
+	m := make(map[K]jsonItem[V], len(items))
+	for k, v := range items {
+		ji := jsonItem[V]{Value: v.Object}
+		if v.Expiration > 0 {
+			t := time.Unix(0, v.Expiration).UTC()
+			ji.Expiration = &t
+		}
+		m[k] = ji
+	}
+	return json.NewEncoder(w).Encode(m)
+}
+
+// RestoreJSON reads a JSON object in the format written by SnapshotJSON from r
+// and stores its items in the cache, replacing any existing items with the
+// same keys, as Set does: their read limits and tags are lifted. Items that
+// have expired since the snapshot was taken are skipped.
+// Nothing is stored if r doesn't hold a valid snapshot.
+func (c *cache[K, V]) RestoreJSON(r io.Reader) error {
+	m := map[K]jsonItem[V]{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return err
+	}
+	now := c.now()
+	c.mu.Lock()
+	for k, ji := range m {
+		var e int64
+		if ji.Expiration != nil {
+			e = ji.Expiration.UnixNano()
+			if now > e {
+				continue
+			}
+		}
+		c.setItem(k, ji.Value, e)
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return nil
+}
@@ -0,0 +1,85 @@
+package ttlcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotJSON(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("forever", 1, NoExpiration)
+	tc.Set("later", 2, time.Hour)
+	tc.Set("expired", 3, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	if err := tc.SnapshotJSON(buf); err != nil {
+		t.Fatal("Couldn't write snapshot:", err)
+	}
+	var m map[string]map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal("Snapshot is not valid JSON:", err)
+	}
+	if _, found := m["expired"]; found {
+		t.Error("expired was included in the snapshot")
+	}
+	if _, found := m["forever"]["expiration"]; found {
+		t.Error("forever has an expiration in the snapshot")
+	}
+	exp, ok := m["later"]["expiration"].(string)
+	if !ok {
+		t.Fatal("later has no expiration in the snapshot")
+	}
+	if _, err := time.Parse(time.RFC3339, exp); err != nil {
+		t.Error("Expiration of later is not an RFC 3339 timestamp:", exp)
+	}
+
+	oc := New[string, int](DefaultExpiration, 0)
+	oc.Set("forever", 10, DefaultExpiration)
+	if err := oc.RestoreJSON(buf); err != nil {
+		t.Fatal("Couldn't restore snapshot:", err)
+	}
+	if x, found := oc.Get("forever"); !found || x != 1 {
+		t.Error("forever was not restored:", x)
+	}
+	_, want, _ := tc.GetWithExpiration("later")
+	x, got, found := oc.GetWithExpiration("later")
+	if !found || x != 2 {
+		t.Error("later was not restored:", x)
+	}
+	if !got.Equal(want) {
+		t.Error("Expiration of later was not restored:", got)
+	}
+	if _, found := oc.Get("expired"); found {
+		t.Error("expired was restored")
+	}
+}
+
+func TestRestoreJSONInvalid(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if err := tc.RestoreJSON(strings.NewReader(`{"foo": {"value": "bar"}}`)); err == nil {
+		t.Error("Restored a snapshot with values of the wrong type")
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count is not 0 after a failed restore: %d", n)
+	}
+}
+
+func TestRestoreJSONReplacesTags(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.SetWithTags("a", 1, DefaultExpiration, "stale")
+	tc.SetWithUses("b", 2, 1)
+	if err := tc.RestoreJSON(strings.NewReader(`{"a": {"value": 10}, "b": {"value": 20}}`)); err != nil {
+		t.Fatal("Couldn't restore snapshot:", err)
+	}
+	if n := tc.InvalidateTag("stale"); n != 0 {
+		t.Error("The restored a was invalidated by the tag of the old one")
+	}
+	tc.Get("b")
+	if x, found := tc.Get("b"); !found || x != 20 {
+		t.Error("The restored b was removed by the read limit of the old one:", x, found)
+	}
+}
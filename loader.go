@@ -0,0 +1,281 @@
+package ttlcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// call is an in-flight or completed load for a single key.
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	waiters int                // callers waiting on done, guarded by loadGroup.mu
+	cancel  context.CancelFunc // cancels the load; nil if it can't be cancelled
+}
+
+// loadGroup deduplicates concurrent loads of the same key so that only one
+// loader invocation runs per key at a time. The zero value is ready to use.
+type loadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// do runs fn for k unless a load for k is already in flight, in which case it
+// waits for that load and returns its result instead. If fn panics, the panic
+// carries on in the caller that ran it, and the callers waiting on it get an
+// error wrapping ErrLoaderPanicked.
+func (g *loadGroup[K, V]) do(k K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if cl, ok := g.calls[k]; ok {
+		cl.waiters++
+		g.mu.Unlock()
+		<-cl.done
+		return cl.val, cl.err
+	}
+	cl := &call[V]{done: make(chan struct{}), waiters: 1}
+	g.calls[k] = cl
+	g.mu.Unlock()
+
+	defer g.finish(k, cl)
+	defer func() {
+		if r := recover(); r != nil {
+			cl.err = fmt.Errorf("%w: %v", ErrLoaderPanicked, r)
+			panic(r)
+		}
+	}()
+	cl.val, cl.err = fn()
+	return cl.val, cl.err
+}
+
+// doContext is like do, but runs fn in its own goroutine so that each caller
+// can stop waiting when its ctx is done, without affecting the other callers
+// waiting on the same load. The context passed to fn carries the values of
+// the ctx that started the load and is cancelled only once every caller has
+// stopped waiting; a cancelled load is removed from the group right away, so
+// later callers start a fresh one rather than joining it. Since no caller runs
+// fn itself, a panic in fn is recovered, and every caller gets an error
+// wrapping ErrLoaderPanicked instead.
+func (g *loadGroup[K, V]) doContext(ctx context.Context, k K, fn func(context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	cl, ok := g.calls[k]
+	if !ok {
+		lctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		cl = &call[V]{done: make(chan struct{}), cancel: cancel}
+		g.calls[k] = cl
+		go func() {
+			defer cancel()
+			defer g.finish(k, cl)
+			defer func() {
+				if r := recover(); r != nil {
+					var zero V
+					cl.val, cl.err = zero, fmt.Errorf("%w: %v", ErrLoaderPanicked, r)
+				}
+			}()
+			cl.val, cl.err = fn(lctx)
+		}()
+	}
+	cl.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.val, cl.err
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	cl.waiters--
+	if cl.waiters == 0 && cl.cancel != nil {
+		cl.cancel()
+		if g.calls[k] == cl {
+			delete(g.calls, k)
+		}
+	}
+	g.mu.Unlock()
+	var zero V
+	return zero, ctx.Err()
+}
+
+// finish removes cl from the group, unless it has been replaced already, and
+// wakes up its waiters.
+func (g *loadGroup[K, V]) finish(k K, cl *call[V]) {
+	g.mu.Lock()
+	if g.calls[k] == cl {
+		delete(g.calls, k)
+	}
+	g.mu.Unlock()
+	close(cl.done)
+}
+
+// ErrNotFound may be returned by a loader to report that no value exists for
+// a key. GetOrLoadWith can cache this result for a while; see
+// LoadConfig.NegativeTTL.
+var ErrNotFound = errors.New("ttlcache: not found")
+
+// ErrCachedNotFound is returned by GetOrLoadWith when an ErrNotFound result of
+// an earlier load is still cached, and the loader was not called. It wraps
+// ErrNotFound, so errors.Is(err, ErrNotFound) holds for both.
+var ErrCachedNotFound = fmt.Errorf("%w (cached)", ErrNotFound)
+
+// ErrLoaderPanicked is returned, wrapped together with the panic value, to the
+// callers waiting on a load whose loader panicked, and by GetOrLoadContext to
+// every caller of such a load.
+var ErrLoaderPanicked = errors.New("ttlcache: loader panicked")
+
+// LoadConfig configures the loads of GetOrLoadWith.
+type LoadConfig struct {
+	// NegativeTTL is how long an ErrNotFound returned by the loader is
+	// cached. Until it expires, GetOrLoadWith returns ErrCachedNotFound
+	// for the key without calling the loader, unless a value is set for the
+	// key in the meantime. If it is less than one, ErrNotFound is not
+	// cached, like any other error.
+	NegativeTTL time.Duration
+}
+
+// GetOrLoad returns the value for k if it is present and hasn't expired.
+// Otherwise it calls loader, stores the result with the expiration d and
+// returns it. Concurrent callers for the same key share a single loader
+// invocation. If loader returns an error nothing is cached, and the error is
+// returned to every caller waiting on that load. If it panics, nothing is
+// cached either: the panic propagates to the caller that invoked loader, and
+// the others get an error wrapping ErrLoaderPanicked.
+func (c *cache[K, V]) GetOrLoad(k K, d time.Duration, loader func(K) (V, error)) (V, error) {
+	return c.GetOrLoadWith(k, d, LoadConfig{}, loader)
+}
+
+// GetOrLoadWith is like GetOrLoad, with the behaviour of the load configured
+// by cfg.
+func (c *cache[K, V]) GetOrLoadWith(k K, d time.Duration, cfg LoadConfig, loader func(K) (V, error)) (V, error) {
+	return getOrLoadWith[K, V](c, &c.loads, k, d, cfg, loader)
+}
+
+// loadTarget is what GetOrLoad and Warm load values into: a cache, or a
+// sharded cache. Each of its methods takes the locks it needs by itself, so
+// that loaders run without any held.
+type loadTarget[K comparable, V any] interface {
+	Get(k K) (V, bool)
+	peek(k K) (V, bool)
+	Has(k K) bool
+	Set(k K, x V, d time.Duration)
+	negativeCached(k K) bool
+	setNegative(k K, d time.Duration)
+	clearNegative(k K)
+}
+
+// getOrLoadWith implements GetOrLoadWith for t, deduplicating loads with g.
+func getOrLoadWith[K comparable, V any](t loadTarget[K, V], g *loadGroup[K, V], k K, d time.Duration, cfg LoadConfig, loader func(K) (V, error)) (V, error) {
+	if v, found := t.Get(k); found {
+		return v, nil
+	}
+	if t.negativeCached(k) {
+		var zero V
+		return zero, ErrCachedNotFound
+	}
+	return g.do(k, func() (V, error) {
+		// Another load may have completed between the Get above and
+		// joining the group. The miss has been counted already, so the
+		// value is looked up again without counting it.
+		if v, found := t.peek(k); found {
+			return v, nil
+		}
+		if t.negativeCached(k) {
+			var zero V
+			return zero, ErrCachedNotFound
+		}
+		v, err := loader(k)
+		if err != nil {
+			if cfg.NegativeTTL > 0 && errors.Is(err, ErrNotFound) {
+				t.setNegative(k, cfg.NegativeTTL)
+			}
+			return v, err
+		}
+		t.Set(k, v, d)
+		t.clearNegative(k)
+		return v, nil
+	})
+}
+
+// negativeCached reports whether an unexpired ErrNotFound result is cached for
+// k.
+func (c *cache[K, V]) negativeCached(k K) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, found := c.negatives[k]
+	return found && c.now() <= e
+}
+
+func (c *cache[K, V]) setNegative(k K, d time.Duration) {
+	c.mu.Lock()
+	if c.negatives == nil {
+		c.negatives = make(map[K]int64)
+	}
+	c.negatives[k] = c.now() + int64(d)
+	c.mu.Unlock()
+}
+
+func (c *cache[K, V]) clearNegative(k K) {
+	c.mu.Lock()
+	delete(c.negatives, k)
+	c.mu.Unlock()
+}
+
+// GetOrLoadContext is like GetOrLoad, but the wait for the value is bounded by
+// ctx. If ctx is done before the value is available, GetOrLoadContext returns
+// ctx.Err(), while a load shared with other callers carries on for them. The
+// context passed to loader carries the values of ctx, and is cancelled once
+// every caller waiting on that load has given up; nothing is cached in that
+// case unless the loader succeeds regardless, and the next caller starts a new
+// load. The loader runs in a goroutine of its own, so if it panics the panic is
+// recovered, and every caller waiting on it gets an error wrapping
+// ErrLoaderPanicked.
+func (c *cache[K, V]) GetOrLoadContext(ctx context.Context, k K, d time.Duration, loader func(context.Context, K) (V, error)) (V, error) {
+	return getOrLoadContext[K, V](ctx, c, &c.loads, k, d, loader)
+}
+
+// getOrLoadContext implements GetOrLoadContext for t, deduplicating loads
+// with g.
+func getOrLoadContext[K comparable, V any](ctx context.Context, t loadTarget[K, V], g *loadGroup[K, V], k K, d time.Duration, loader func(context.Context, K) (V, error)) (V, error) {
+	if v, found := t.Get(k); found {
+		return v, nil
+	}
+	return g.doContext(ctx, k, func(ctx context.Context) (V, error) {
+		if v, found := t.peek(k); found {
+			return v, nil
+		}
+		v, err := loader(ctx, k)
+		if err != nil {
+			return v, err
+		}
+		t.Set(k, v, d)
+		return v, nil
+	})
+}
+
+// negativeCached reports whether an unexpired ErrNotFound result is cached for
+// k in its shard.
+func (sc *shardedCache[K, V]) negativeCached(k K) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).negativeCached(k)
+}
+
+func (sc *shardedCache[K, V]) setNegative(k K, d time.Duration) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	sc.bucket(k).setNegative(k, d)
+}
+
+func (sc *shardedCache[K, V]) clearNegative(k K) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	sc.bucket(k).clearNegative(k)
+}
@@ -0,0 +1,298 @@
+package ttlcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	var calls int32
+	loader := func(k string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return len(k), nil
+	}
+
+	v, err := tc.GetOrLoad("foo", DefaultExpiration, loader)
+	if err != nil {
+		t.Fatal("Couldn't load foo:", err)
+	}
+	if v != 3 {
+		t.Error("foo is not 3:", v)
+	}
+	v, err = tc.GetOrLoad("foo", DefaultExpiration, loader)
+	if err != nil || v != 3 {
+		t.Error("Couldn't get foo a second time:", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times instead of once", n)
+	}
+	if s := tc.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Stats are %d hits and %d misses, not 1 and 1", s.Hits, s.Misses)
+	}
+	if x, found := tc.Get("foo"); !found || x != 3 {
+		t.Error("foo was not cached by GetOrLoad")
+	}
+}
+
+func TestGetOrLoadSingleFlight(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	var calls int32
+	release := make(chan struct{})
+	loader := func(k string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	n := 50
+	wg := new(sync.WaitGroup)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			v, err := tc.GetOrLoad("foo", DefaultExpiration, loader)
+			if err != nil || v != 42 {
+				t.Error("Unexpected result from GetOrLoad:", v, err)
+			}
+		}()
+	}
+	<-time.After(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times instead of once", n)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(k string) (int, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}
+
+	leader := make(chan any)
+	go func() {
+		defer func() {
+			leader <- recover()
+		}()
+		tc.GetOrLoad("foo", DefaultExpiration, loader)
+	}()
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, err := tc.GetOrLoad("foo", DefaultExpiration, func(string) (int, error) {
+			return 0, errors.New("the waiter started a load of its own")
+		})
+		waiter <- err
+	}()
+	<-time.After(10 * time.Millisecond)
+	close(release)
+	if r := <-leader; r != "boom" {
+		t.Error("The panic didn't propagate to the caller of the loader:", r)
+	}
+	if err := <-waiter; !errors.Is(err, ErrLoaderPanicked) {
+		t.Error("The waiter didn't get ErrLoaderPanicked:", err)
+	}
+	if _, found := tc.Get("foo"); found {
+		t.Error("A value was cached by a loader that panicked")
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	errLoad := errors.New("load failed")
+	_, err := tc.GetOrLoad("foo", DefaultExpiration, func(string) (int, error) {
+		return 0, errLoad
+	})
+	if err != errLoad {
+		t.Error("GetOrLoad did not return the loader's error:", err)
+	}
+	if _, found := tc.Get("foo"); found {
+		t.Error("foo was cached even though the loader failed")
+	}
+	v, err := tc.GetOrLoad("foo", DefaultExpiration, func(string) (int, error) {
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Error("A failed load prevented a later one from succeeding:", v, err)
+	}
+}
+
+func TestGetOrLoadContext(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	v, err := tc.GetOrLoadContext(context.Background(), "foo", DefaultExpiration, func(ctx context.Context, k string) (int, error) {
+		return len(k), nil
+	})
+	if err != nil || v != 3 {
+		t.Error("Couldn't load foo:", v, err)
+	}
+	if s := tc.Stats(); s.Hits != 0 || s.Misses != 1 {
+		t.Errorf("Stats are %d hits and %d misses, not 0 and 1", s.Hits, s.Misses)
+	}
+	if x, found := tc.Get("foo"); !found || x != 3 {
+		t.Error("foo was not cached by GetOrLoadContext")
+	}
+}
+
+func TestGetOrLoadContextPanic(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	_, err := tc.GetOrLoadContext(context.Background(), "foo", DefaultExpiration, func(context.Context, string) (int, error) {
+		panic("boom")
+	})
+	if !errors.Is(err, ErrLoaderPanicked) {
+		t.Error("GetOrLoadContext didn't return ErrLoaderPanicked:", err)
+	}
+	if _, found := tc.Get("foo"); found {
+		t.Error("A value was cached by a loader that panicked")
+	}
+}
+
+func TestGetOrLoadContextWaiterCancelled(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	loader := func(ctx context.Context, k string) (int, error) {
+		close(started)
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		v, err := tc.GetOrLoadContext(context.Background(), "foo", DefaultExpiration, loader)
+		if err == nil && v != 42 {
+			err = errors.New("unexpected value")
+		}
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := tc.GetOrLoadContext(ctx, "foo", DefaultExpiration, loader)
+		waiter <- err
+	}()
+	cancel()
+	if err := <-waiter; err != context.Canceled {
+		t.Error("Cancelled waiter did not return context.Canceled:", err)
+	}
+
+	close(release)
+	if err := <-result; err != nil {
+		t.Error("Cancelling one waiter affected the shared load:", err)
+	}
+	if x, found := tc.Get("foo"); !found || x != 42 {
+		t.Error("foo was not cached by the shared load")
+	}
+}
+
+func TestGetOrLoadContextAllCancelled(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	loaderErr := make(chan error, 1)
+	go func() {
+		<-time.After(5 * time.Millisecond)
+		cancel()
+	}()
+	_, err := tc.GetOrLoadContext(ctx, "foo", DefaultExpiration, func(ctx context.Context, k string) (int, error) {
+		<-ctx.Done()
+		loaderErr <- ctx.Err()
+		return 0, ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Error("GetOrLoadContext did not return context.Canceled:", err)
+	}
+	if err := <-loaderErr; err == nil {
+		t.Error("The load was not cancelled after every caller gave up")
+	}
+
+	v, err := tc.GetOrLoadContext(context.Background(), "foo", DefaultExpiration, func(ctx context.Context, k string) (int, error) {
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Error("A cancelled load poisoned a later one:", v, err)
+	}
+}
+
+func TestGetOrLoadWithNegativeTTL(t *testing.T) {
+	clock := newFakeClock()
+	tc := New[string, int](DefaultExpiration, 0, WithClock[string, int](clock))
+	cfg := LoadConfig{NegativeTTL: time.Minute}
+	var calls int32
+	exists := false
+	loader := func(k string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		if !exists {
+			return 0, ErrNotFound
+		}
+		return len(k), nil
+	}
+
+	if _, err := tc.GetOrLoadWith("foo", DefaultExpiration, cfg, loader); err != ErrNotFound {
+		t.Error("The first load didn't return ErrNotFound:", err)
+	}
+	_, err := tc.GetOrLoadWith("foo", DefaultExpiration, cfg, loader)
+	if err != ErrCachedNotFound || !errors.Is(err, ErrNotFound) {
+		t.Error("The cached negative result wasn't returned:", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times instead of once", n)
+	}
+
+	exists = true
+	clock.Advance(2 * time.Minute)
+	v, err := tc.GetOrLoadWith("foo", DefaultExpiration, cfg, loader)
+	if err != nil || v != 3 {
+		t.Error("foo wasn't loaded after the negative result expired:", v, err)
+	}
+	if len(tc.negatives) != 0 {
+		t.Error("The negative result wasn't cleared by a successful load")
+	}
+}
+
+func TestGetOrLoadWithNegativeTTLSet(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	cfg := LoadConfig{NegativeTTL: time.Hour}
+	loader := func(k string) (int, error) {
+		return 0, ErrNotFound
+	}
+	tc.GetOrLoadWith("foo", DefaultExpiration, cfg, loader)
+	tc.Set("foo", 1, DefaultExpiration)
+	if v, err := tc.GetOrLoadWith("foo", DefaultExpiration, cfg, loader); err != nil || v != 1 {
+		t.Error("A value set after a negative result was shadowed by it:", v, err)
+	}
+
+	// Without a NegativeTTL, ErrNotFound isn't cached.
+	tc.GetOrLoad("bar", DefaultExpiration, loader)
+	if _, err := tc.GetOrLoad("bar", DefaultExpiration, loader); err != ErrNotFound {
+		t.Error("ErrNotFound was cached without a NegativeTTL:", err)
+	}
+}
+
+func TestNegativeResultsDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](DefaultExpiration, 0, 2, WithClock[string, int](clock))
+	loader := func(k string) (int, error) {
+		return 0, ErrNotFound
+	}
+	tc.GetOrLoadWith("foo", DefaultExpiration, LoadConfig{NegativeTTL: time.Minute}, loader)
+	clock.Advance(2 * time.Minute)
+	tc.DeleteExpired()
+	if len(tc.bucket("foo").negatives) != 0 {
+		t.Error("The expired negative result wasn't deleted by DeleteExpired")
+	}
+}
@@ -0,0 +1,83 @@
+package ttlcache
+
+import "time"
+
+// LoadingCache is a read-through cache: its Get loads missing and expired
+// values with the loader it was created with, instead of reporting a miss. All
+// the methods of Cache, such as Set and Delete, are available on it as well;
+// only Get is replaced. Concurrent loads of the same key share a single loader
+// invocation, as with GetOrLoad.
+type LoadingCache[K comparable, V any] struct {
+	*Cache[K, V]
+	loader func(K) (V, time.Duration, error)
+}
+
+// NewLoading returns a new LoadingCache which loads values with loader and
+// stores them with the default expiration time. The default expiration,
+// cleanup interval and opts are as for New.
+func NewLoading[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, loader func(K) (V, error), opts ...Option[K, V]) *LoadingCache[K, V] {
+	return NewLoadingWithTTL(defaultExpiration, cleanupInterval, func(k K) (V, time.Duration, error) {
+		v, err := loader(k)
+		return v, DefaultExpiration, err
+	}, opts...)
+}
+
+// NewLoadingWithTTL is like NewLoading, but loader also returns the duration
+// each value is stored for, which is interpreted as in Set. This suits values
+// which carry their own lifetime, such as tokens.
+func NewLoadingWithTTL[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, loader func(K) (V, time.Duration, error), opts ...Option[K, V]) *LoadingCache[K, V] {
+	return &LoadingCache[K, V]{
+		Cache:  New[K, V](defaultExpiration, cleanupInterval, opts...),
+		loader: loader,
+	}
+}
+
+// Get returns the value for k, calling the loader and storing its result if k
+// is not in the cache or has expired. If the loader returns an error nothing
+// is cached, and the error is returned to every caller waiting on that load.
+func (lc *LoadingCache[K, V]) Get(k K) (V, error) {
+	if v, found := lc.Cache.Get(k); found {
+		return v, nil
+	}
+	return lc.load(k, false)
+}
+
+// Invalidate removes k from the cache, so that the next Get loads it again.
+func (lc *LoadingCache[K, V]) Invalidate(k K) {
+	lc.Delete(k)
+}
+
+// Refresh loads k and stores the result, whether or not a value is cached for
+// it already. If the loader returns an error, the cached value, if any, is
+// left in place and the error is returned.
+func (lc *LoadingCache[K, V]) Refresh(k K) error {
+	_, err := lc.GetForceRefresh(k)
+	return err
+}
+
+// GetForceRefresh is like Refresh, but also returns the freshly loaded value:
+// it bypasses any value cached for k, calls the loader, stores the result for
+// later callers and returns it. Concurrent loads of k, forced or not, share a
+// single loader invocation. If the loader returns an error, the cached value,
+// if any, is left in place and the error is returned.
+func (lc *LoadingCache[K, V]) GetForceRefresh(k K) (V, error) {
+	return lc.load(k, true)
+}
+
+// load calls the loader for k within the cache's load group. Unless force is
+// set, a value stored while waiting to join the group is returned instead.
+func (lc *LoadingCache[K, V]) load(k K, force bool) (V, error) {
+	return lc.loads.do(k, func() (V, error) {
+		if !force {
+			if v, found := lc.Cache.Get(k); found {
+				return v, nil
+			}
+		}
+		v, d, err := lc.loader(k)
+		if err != nil {
+			return v, err
+		}
+		lc.Set(k, v, d)
+		return v, nil
+	})
+}
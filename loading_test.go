@@ -0,0 +1,157 @@
+package ttlcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCache(t *testing.T) {
+	var calls int32
+	lc := NewLoading[string, int](DefaultExpiration, 0, func(k string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		if k == "bad" {
+			return 0, errors.New("bad key")
+		}
+		return len(k), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if v, err := lc.Get("foo"); err != nil || v != 3 {
+			t.Fatal("Couldn't get foo:", v, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times instead of once", n)
+	}
+	if _, err := lc.Get("bad"); err == nil {
+		t.Error("Get of bad didn't return the loader's error")
+	}
+	if lc.Has("bad") {
+		t.Error("bad was cached after a failed load")
+	}
+
+	lc.Invalidate("foo")
+	if lc.Has("foo") {
+		t.Error("foo is still cached after Invalidate")
+	}
+	if v, err := lc.Get("foo"); err != nil || v != 3 {
+		t.Error("Couldn't get foo after Invalidate:", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("loader was called %d times instead of 3", n)
+	}
+}
+
+func TestLoadingCacheRefresh(t *testing.T) {
+	var version int32
+	fail := false
+	lc := NewLoading[string, int32](DefaultExpiration, 0, func(string) (int32, error) {
+		if fail {
+			return 0, errors.New("unavailable")
+		}
+		return atomic.AddInt32(&version, 1), nil
+	})
+
+	if v, _ := lc.Get("foo"); v != 1 {
+		t.Fatal("foo is not 1:", v)
+	}
+	if err := lc.Refresh("foo"); err != nil {
+		t.Fatal("Couldn't refresh foo:", err)
+	}
+	if v, _ := lc.Get("foo"); v != 2 {
+		t.Error("foo is not 2 after Refresh:", v)
+	}
+
+	fail = true
+	if err := lc.Refresh("foo"); err == nil {
+		t.Error("Refresh didn't return the loader's error")
+	}
+	if v, err := lc.Get("foo"); err != nil || v != 2 {
+		t.Error("A failed Refresh replaced foo:", v, err)
+	}
+}
+
+func TestLoadingCacheWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	lc := NewLoadingWithTTL[string, string](time.Hour, 0, func(k string) (string, time.Duration, error) {
+		if k == "short" {
+			return k, time.Minute, nil
+		}
+		return k, DefaultExpiration, nil
+	}, WithClock[string, string](clock))
+
+	lc.Get("short")
+	lc.Get("long")
+	clock.Advance(2 * time.Minute)
+	if lc.Has("short") {
+		t.Error("short didn't expire after the TTL returned by the loader")
+	}
+	if !lc.Has("long") {
+		t.Error("long expired before the default expiration time")
+	}
+}
+
+func TestLoadingCacheSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	lc := NewLoading[string, int](DefaultExpiration, 0, func(string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 1, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := lc.Get("foo"); err != nil || v != 1 {
+				t.Error("Couldn't get foo:", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times instead of once", n)
+	}
+}
+
+func TestLoadingCacheGetForceRefresh(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	lc := NewLoading[string, int32](DefaultExpiration, 0, func(string) (int32, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			<-release
+		}
+		return n, nil
+	})
+
+	if v, _ := lc.Get("foo"); v != 1 {
+		t.Fatal("foo is not 1:", v)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := lc.GetForceRefresh("foo"); err != nil || v != 2 {
+				t.Error("GetForceRefresh didn't return the reloaded value:", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("loader was called %d times instead of twice", n)
+	}
+	if v, _ := lc.Get("foo"); v != 2 {
+		t.Error("GetForceRefresh didn't store the reloaded value:", v)
+	}
+}
@@ -0,0 +1,134 @@
+package ttlcache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Float is a constraint that permits any floating-point type.
+type Float interface {
+	~float32 | ~float64
+}
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	Integer | Float
+}
+
+// modifier is implemented by both *Cache and *ShardedCache.
+type modifier[K comparable, V any] interface {
+	// modify replaces the value of item k with the result of f, all under
+	// the lock guarding k. f is called with the current value and whether
+	// k was found (and hasn't expired). An existing item keeps its
+	// expiration time, but the new value lifts its read limit and tags,
+	// as storing it by Set would; a new item gets the default expiration.
+	// If f returns an error the cache is left unchanged.
+	modify(k K, f func(V, bool) (V, error)) (V, error)
+}
+
+// Increment atomically adds n to the number stored at k and returns the new
+// value. c may be a *Cache or a *ShardedCache. The read-modify-write happens
+// under the lock guarding k, so concurrent increments are never lost, and the
+// item keeps its expiration time. It returns an error wrapping ErrKeyNotFound
+// if k is not in the cache or has expired. Integer values wrap around on
+// overflow.
+func Increment[K comparable, V Number](c modifier[K, V], k K, n V) (V, error) {
+	return c.modify(k, func(v V, found bool) (V, error) {
+		if !found {
+			return v, fmt.Errorf("%w: %v", ErrKeyNotFound, k)
+		}
+		return v + n, nil
+	})
+}
+
+// Decrement atomically subtracts n from the number stored at k and returns the
+// new value. It behaves like Increment otherwise; in particular, unsigned
+// values wrap around rather than going below zero.
+func Decrement[K comparable, V Number](c modifier[K, V], k K, n V) (V, error) {
+	return c.modify(k, func(v V, found bool) (V, error) {
+		if !found {
+			return v, fmt.Errorf("%w: %v", ErrKeyNotFound, k)
+		}
+		return v - n, nil
+	})
+}
+
+// IncrementFloat atomically adds n to the floating-point number stored at k,
+// and returns the new value. Unlike Increment, it accepts caches of any value
+// type, and checks at run time that the value stored at k is a float32 or a
+// float64, or of a type defined with one of them as its underlying type (for
+// a float32, n is converted to float32 first). The new value has the same
+// type as the old one. As with Increment, the item keeps its expiration time.
+//
+// It returns an error, and leaves the cache unchanged, if k is not in the cache
+// or has expired, if its value is not a floating-point number, or if the result
+// is not a finite number (NaN, or an infinity from adding an infinity or from
+// overflow), so that a NaN or infinity is never stored by accident.
+func IncrementFloat[K comparable, V any](c modifier[K, V], k K, n float64) (float64, error) {
+	var result float64
+	_, err := c.modify(k, func(v V, found bool) (V, error) {
+		if !found {
+			return v, fmt.Errorf("%w: %v", ErrKeyNotFound, k)
+		}
+		x := reflect.ValueOf(any(v))
+		switch x.Kind() {
+		case reflect.Float32:
+			result = float64(float32(x.Float()) + float32(n))
+		case reflect.Float64:
+			result = x.Float() + n
+		default:
+			return v, fmt.Errorf("the value for %v is a %T, not a float32 or float64", k, v)
+		}
+		if math.IsNaN(result) || math.IsInf(result, 0) {
+			return v, fmt.Errorf("incrementing %v by %v gives %v", k, n, result)
+		}
+		nv := reflect.New(x.Type()).Elem()
+		nv.SetFloat(result)
+		return nv.Interface().(V), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (c *cache[K, V]) modify(k K, f func(V, bool) (V, error)) (V, error) {
+	c.mu.Lock()
+	v, found := c.get(k)
+	if found {
+		v = c.decoded(v)
+	} else {
+		var zero V
+		v = zero
+	}
+	nv, err := f(v, found)
+	if err != nil {
+		c.mu.Unlock()
+		return v, err
+	}
+	if found {
+		// The new value is stored like any other, lifting a read limit
+		// and tags, but keeps the expiration time of the old one.
+		c.storeItem(k, c.encoded(nv), c.items[k].Expiration)
+	} else {
+		c.set(k, nv, DefaultExpiration)
+	}
+	evicted := c.evictOverflow()
+	ef := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(ef, evicted, ReasonCapacity)
+	return nv, nil
+}
+
+func (sc *shardedCache[K, V]) modify(k K, f func(V, bool) (V, error)) (V, error) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).modify(k, f)
+}
@@ -0,0 +1,153 @@
+package ttlcache
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if _, err := Increment(tc, "foo", 1); err == nil {
+		t.Error("Incremented foo even though it doesn't exist")
+	}
+	tc.Set("foo", 1, 50*time.Millisecond)
+	_, before, _ := tc.GetWithExpiration("foo")
+	n, err := Increment(tc, "foo", 2)
+	if err != nil {
+		t.Fatal("Couldn't increment foo:", err)
+	}
+	if n != 3 {
+		t.Error("foo is not 3:", n)
+	}
+	x, after, _ := tc.GetWithExpiration("foo")
+	if x != 3 {
+		t.Error("Incremented value was not stored:", x)
+	}
+	if !before.Equal(after) {
+		t.Error("Increment changed the expiration time of foo")
+	}
+	n, err = Decrement(tc, "foo", 5)
+	if err != nil || n != -2 {
+		t.Error("Couldn't decrement foo to -2:", n, err)
+	}
+}
+
+func TestIncrementLiftsUsesAndTags(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.SetWithUses("uses", 1, 1)
+	tc.SetWithTags("tags", 1, DefaultExpiration, "t")
+	before := tc.Stats().Insertions
+	if _, err := Increment(tc, "uses", 1); err != nil {
+		t.Fatal("Couldn't increment uses:", err)
+	}
+	if _, err := Increment(tc, "tags", 1); err != nil {
+		t.Fatal("Couldn't increment tags:", err)
+	}
+	if n := tc.Stats().Insertions - before; n != 2 {
+		t.Error("Increments counted", n, "insertions instead of 2")
+	}
+	tc.Get("uses")
+	if x, found := tc.Get("uses"); !found || x != 2 {
+		t.Error("The read limit of uses wasn't lifted by Increment:", x, found)
+	}
+	if n := tc.InvalidateTag("t"); n != 0 {
+		t.Error("The tags of tags weren't lifted by Increment")
+	}
+}
+
+func TestIncrementFloat64(t *testing.T) {
+	tc := NewSharded[string, float64](DefaultExpiration, 0, 4)
+	tc.Set("foo", 1.5, DefaultExpiration)
+	n, err := Increment(tc, "foo", 1.25)
+	if err != nil || n != 2.75 {
+		t.Error("Couldn't increment foo to 2.75:", n, err)
+	}
+	n, err = Decrement(tc, "foo", 0.75)
+	if err != nil || n != 2 {
+		t.Error("Couldn't decrement foo to 2:", n, err)
+	}
+}
+
+func TestIncrementExpired(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("foo", 1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if _, err := Increment(tc, "foo", 1); err == nil {
+		t.Error("Incremented foo even though it has expired")
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	tc := New[string, uint64](DefaultExpiration, 0)
+	tc.Set("foo", 0, DefaultExpiration)
+	n := 100
+	wg := new(sync.WaitGroup)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			Increment(tc, "foo", 1)
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("foo"); x != uint64(n) {
+		t.Errorf("foo is not %d after %d concurrent increments: %d", n, n, x)
+	}
+}
+
+func TestIncrementFloat(t *testing.T) {
+	tc := New[string, any](DefaultExpiration, 0)
+	tc.Set("f64", 1.5, DefaultExpiration)
+	tc.Set("f32", float32(1.5), DefaultExpiration)
+	tc.Set("int", 1, DefaultExpiration)
+
+	if x, err := IncrementFloat(tc, "f64", 2); err != nil || x != 3.5 {
+		t.Error("Couldn't increment f64:", x, err)
+	}
+	if v, _ := tc.Get("f64"); v != 3.5 {
+		t.Error("f64 is not 3.5:", v)
+	}
+	if x, err := IncrementFloat(tc, "f32", -0.5); err != nil || x != 1 {
+		t.Error("Couldn't increment f32:", x, err)
+	}
+	if v, _ := tc.Get("f32"); v != float32(1) {
+		t.Errorf("f32 is not float32(1): %#v", v)
+	}
+	if _, err := IncrementFloat(tc, "int", 1); err == nil {
+		t.Error("Incremented an int with IncrementFloat")
+	}
+
+	type celsius float64
+	tc.Set("named", celsius(20), DefaultExpiration)
+	if x, err := IncrementFloat(tc, "named", 1.5); err != nil || x != 21.5 {
+		t.Error("Couldn't increment a named float type:", x, err)
+	}
+	if v, _ := tc.Get("named"); v != celsius(21.5) {
+		t.Errorf("named is not celsius(21.5): %#v", v)
+	}
+	if _, err := IncrementFloat(tc, "missing", 1); err == nil {
+		t.Error("Incremented a missing key")
+	}
+}
+
+func TestIncrementFloatNonFinite(t *testing.T) {
+	tc := NewSharded[string, float64](DefaultExpiration, 0, 2)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("max", math.MaxFloat64, DefaultExpiration)
+	for k, n := range map[string]float64{
+		"a":   math.NaN(),
+		"max": math.MaxFloat64,
+	} {
+		if _, err := IncrementFloat(tc, k, n); err == nil {
+			t.Errorf("Incrementing %s by %v didn't return an error", k, n)
+		}
+	}
+	if v, _ := tc.Get("a"); v != 1 {
+		t.Error("a was changed by a failed increment:", v)
+	}
+	if v, _ := tc.Get("max"); v != math.MaxFloat64 {
+		t.Error("max was changed by a failed increment:", v)
+	}
+}
@@ -0,0 +1,302 @@
+package ttlcache
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option configures optional behaviour of a cache at construction time.
+type Option[K comparable, V any] func(*config[K, V])
+
+type config[K comparable, V any] struct {
+	maxItems   int
+	policy     EvictionPolicy
+	sampleSize int
+	costFunc   func(V) int64
+	maxCost    int64
+	hashFunc   func(K) uint32
+	clock      Clock
+	jitter     time.Duration
+
+	noFinalizer      bool
+	keepExpiredOnGet bool
+	drain            func(K, V)
+	cleanupBatch     int
+	seed             *uint32
+	logger           *slog.Logger
+	maxTotalCost     int64
+	maxValueCost     int64
+	cleanupOnStart   bool
+	staggered        bool
+	contention       bool
+	encode           func(V) V
+	decode           func(V) V
+}
+
+func newConfig[K comparable, V any](opts []Option[K, V]) config[K, V] {
+	var cfg config[K, V]
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// log returns the logger set with WithLogger, or the default one.
+func (cfg *config[K, V]) log() *slog.Logger {
+	if cfg.logger != nil {
+		return cfg.logger
+	}
+	return slog.Default()
+}
+
+// WithMaxItems caps the number of items the cache holds. When storing an item
+// would exceed the cap, an item is evicted first (the least-recently-used one
+// unless WithEvictionPolicy says otherwise) and OnEvicted is called with
+// ReasonCapacity. A cap less than one means the cache is unbounded (the
+// default).
+//
+// Enabling a cap makes Get take the cache's write lock, since every hit
+// updates the eviction policy's bookkeeping, unless the policy is
+// PolicySampledLRU. For the sharded cache the cap applies to each
+// shard separately rather than to the cache as a whole.
+func WithMaxItems[K comparable, V any](n int) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.maxItems = n
+	}
+}
+
+// WithEvictionPolicy selects the policy used to pick which item to evict once
+// the cap set by WithMaxItems is reached. It has no effect on an unbounded
+// cache. The default is PolicyLRU.
+func WithEvictionPolicy[K comparable, V any](p EvictionPolicy) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.policy = p
+	}
+}
+
+// WithEvictSampleSize sets how many items PolicySampledLRU samples on each
+// eviction, the least recently used of which is evicted. Larger samples evict
+// closer to the exact LRU order, but make evictions slower. It has no effect
+// with the other policies. A size less than one means the default of 5.
+func WithEvictSampleSize[K comparable, V any](n int) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.sampleSize = n
+	}
+}
+
+// WithCost bounds the cache by the summed cost of its items rather than (or
+// in addition to) their number. costFunc is called with each value as it is
+// stored, and items are evicted in the order of the eviction policy until the
+// total cost is at most maxCost, calling OnEvicted with ReasonCapacity. An item
+// whose own cost exceeds maxCost is evicted as soon as it is stored, without
+// evicting anything else. If maxCost is less than one, costs are tracked (see
+// Cost) but not enforced.
+//
+// For the sharded cache the limit applies to each shard separately.
+func WithCost[K comparable, V any](costFunc func(V) int64, maxCost int64) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.costFunc = costFunc
+		cfg.maxCost = maxCost
+	}
+}
+
+// WithMaxTotalCost bounds a sharded cache by the summed cost of the items of
+// all its shards, as computed by the cost function set with WithCost, rather
+// than bounding each shard separately. It has no effect without WithCost (whose
+// per-shard maxCost may be 0, so that only the total is enforced), nor on the
+// standard cache.
+//
+// The total is kept in a counter shared by the shards. When a write takes it
+// over max, items are evicted, with ReasonCapacity, from the shard holding the
+// highest cost, and then the next highest, until the total is at most max;
+// only one shard is locked at a time, after the write's own shard has been
+// released, so that writes to different shards can't deadlock. Writes that
+// race with each other may each evict, so slightly more than needed can be
+// evicted. As with WithMaxItems, bounding the cache makes Get take the write
+// lock of the shard.
+func WithMaxTotalCost[K comparable, V any](max int64) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.maxTotalCost = max
+	}
+}
+
+// WithMaxValueCost rejects values whose cost, as computed by the cost
+// function set with WithCost, exceeds max, so that a single pathological value
+// is never kept. The methods that report whether they stored a value leave
+// the cache unchanged for such a value: Add and Replace return an error
+// wrapping ErrValueTooLarge, and SetIfAbsent and SetIfPresent return false.
+// Every other method that stores values, such as Set, SetMany or Update,
+// stores it in place of any existing item and then evicts it right away with
+// ReasonCapacity, as WithCost does with a value that exceeds maxCost, so that
+// the old value is never left behind. It has no effect without WithCost, or
+// if max is less than one (the default).
+func WithMaxValueCost[K comparable, V any](max int64) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.maxValueCost = max
+	}
+}
+
+// WithHashFunc sets the function a sharded cache uses to assign keys to shards.
+// By default string keys are hashed with djb33, integer keys with a
+// multiplicative hash and keys of other types with a slower reflection-based
+// hash. It has no effect on the standard cache.
+func WithHashFunc[K comparable, V any](f func(K) uint32) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.hashFunc = f
+	}
+}
+
+// WithSeed sets the seed of the hash a sharded cache uses to assign keys to
+// shards, so that every key is placed in the same shard from one run to the
+// next, e.g. for tests of the distribution of keys. By default the seed is
+// read from the system's cryptographically secure random number generator, so
+// that keys can't be chosen to all fall into the same shard; only pin it
+// where that doesn't matter. It has no effect on the standard cache, nor on a
+// hash function set with WithHashFunc.
+func WithSeed[K comparable, V any](seed uint32) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.seed = &seed
+	}
+}
+
+// WithLogger sets the logger the cache reports unexpected conditions to, such
+// as a sharded cache failing to read a random seed. The default is
+// slog.Default().
+func WithLogger[K comparable, V any](l *slog.Logger) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.logger = l
+	}
+}
+
+// WithClock sets the clock used for expiration times and by the janitor. The
+// default is RealClock.
+func WithClock[K comparable, V any](c Clock) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.clock = c
+	}
+}
+
+// WithExpirationJitter adds a random offset between 0 and max to the expiration
+// time of every item stored with a positive duration (including the default
+// expiration), so that items stored together with the same duration don't all
+// expire at once. It uses math/rand, not a cryptographically secure source.
+// Absolute deadlines, as given to SetWithDeadline, are not jittered.
+func WithExpirationJitter[K comparable, V any](max time.Duration) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.jitter = max
+	}
+}
+
+// WithoutFinalizer stops the cache from setting a finalizer that closes it once
+// it becomes unreachable. By default the finalizer stops the janitor goroutine
+// of a cache that was never closed, which ties the janitor's lifetime to the
+// garbage collector; with this option, the janitor runs until Close is
+// called, and a cache with a cleanup interval that is never closed leaks its
+// janitor. It suits caches that live as long as the process, or whose
+// lifecycle is managed explicitly.
+func WithoutFinalizer[K comparable, V any]() Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.noFinalizer = true
+	}
+}
+
+// WithDeleteOnGet sets whether a lookup (Get, GetWithExpiration or
+// GetWithVersion) that finds an expired item deletes it right away, calling
+// the eviction callback with ReasonExpired, rather than leaving it for the
+// janitor or DeleteExpired. This reclaims the memory of expired items sooner
+// when sweeps are infrequent. The write lock is only taken when an expired
+// item is found. It is enabled by default.
+func WithDeleteOnGet[K comparable, V any](enabled bool) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.keepExpiredOnGet = !enabled
+	}
+}
+
+// WithDrainOnClose sets a function that Close calls with each unexpired item
+// left in the cache, e.g. to release resources such as open files held by the
+// values. It is called once the cache has been emptied, before the janitor is
+// stopped, and is distinct from the eviction callback, which isn't called for
+// the drained items. However many times, and from however many goroutines,
+// Close is called, the cache is only drained once; items stored after that are
+// not drained. It also runs if the finalizer closes the cache.
+func WithDrainOnClose[K comparable, V any](f func(k K, v V)) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.drain = f
+	}
+}
+
+// WithCleanupBatchSize makes the janitor sweep the cache incrementally: rather
+// than scanning every item at each cleanup interval, each of its runs visits
+// at most n items, under the write lock, carrying on from where the previous
+// run stopped. This spreads out the work of cleaning up a very large cache,
+// and bounds how long each run blocks other operations, at the price of
+// expired items lingering for longer.
+//
+// Each cycle through the cache starts with a copy of its keys, taken under the
+// read lock, and visits them in batches, so every item is visited within two
+// cycles of being stored, i.e. within 2*ceil(items/n) cleanup intervals. For
+// the sharded cache, n is the number of items visited per run across all the
+// shards, which are swept one after the other. OnCleanup is called after each
+// run. DeleteExpired and CleanupNow still sweep the whole cache at once. If n
+// is less than one, the janitor sweeps the whole cache at every interval (the
+// default).
+func WithCleanupBatchSize[K comparable, V any](n int) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.cleanupBatch = n
+	}
+}
+
+// WithStaggeredCleanup sets whether the janitor of a sharded cache spreads
+// its sweeps over the cleanup interval, rather than sweeping every shard at
+// each interval, so that large caches don't see a spike of work all at once.
+// When enabled, the janitor ticks n times per interval, n being the number of
+// shards, and sweeps the next shard at each tick, so that every shard is still
+// swept once per interval; OnCleanup is called after each of these sweeps. It
+// has no effect on the standard cache, nor with WithCleanupBatchSize, whose
+// incremental sweeps are spread out already. It is disabled by default.
+func WithStaggeredCleanup[K comparable, V any](enabled bool) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.staggered = enabled
+	}
+}
+
+// WithCleanupOnStart sets whether the janitor deletes expired items as soon as
+// it starts, rather than only once the first cleanup interval has passed. This
+// suits caches restored with NewFrom, whose items may have expired while the
+// process was down. The initial sweep runs in the janitor's goroutine, covers
+// the whole cache even with WithCleanupBatchSize, and is reported to
+// OnCleanup; it happens whenever a janitor is started, including by
+// SetCleanupInterval. It is disabled by default.
+func WithCleanupOnStart[K comparable, V any](enabled bool) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.cleanupOnStart = enabled
+	}
+}
+
+// WithContentionTracking sets whether the cache counts how often, and for how
+// long, taking its write lock has to wait for another goroutine. For the
+// sharded cache the counts are kept per shard and returned by ShardContention.
+// Tracking costs a few atomic operations per write, and a clock read for each
+// contended one, so it is disabled by default, in which case it costs nothing.
+func WithContentionTracking[K comparable, V any](enabled bool) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.contention = enabled
+	}
+}
+
+// WithCodec sets functions that transform values as they enter and leave the
+// cache, e.g. to store []byte values gzip-compressed and decompress them
+// transparently. encode is applied to every value stored in the cache, and
+// decode to every value the cache hands back, whether returned by a getter or
+// passed to a callback, so that values are only ever seen encoded by the cost
+// function of WithCost and by ItemsRef. Set, Add, Replace, SetIfAbsent and
+// SetIfPresent encode outside the cache's lock; other methods may call encode
+// or decode with the lock held, so neither function may call back into the
+// cache. Either function may be nil, in which case values are stored or
+// returned as they are, at no cost.
+func WithCodec[K comparable, V any](encode, decode func(V) V) Option[K, V] {
+	return func(cfg *config[K, V]) {
+		cfg.encode = encode
+		cfg.decode = decode
+	}
+}
@@ -0,0 +1,52 @@
+package ttlcache
+
+import (
+	"cmp"
+	"time"
+)
+
+// conditionalSetter is implemented by both *Cache and *ShardedCache.
+type conditionalSetter[K comparable, V any] interface {
+	// setIf stores v at k with the duration d if k is missing or has
+	// expired, or if replace reports true for its current value, all under
+	// the lock guarding k. It returns the value stored at k afterwards.
+	setIf(k K, v V, d time.Duration, replace func(old V) bool) V
+}
+
+// SetMax atomically stores v at k, with the duration d interpreted as in Set,
+// if k is missing or has expired or if v is greater than its current value,
+// and returns the value stored at k afterwards. c may be a *Cache or a
+// *ShardedCache. If v is not greater, the item is left as it is, expiration
+// time included. For floating-point values a NaN is never greater than, nor
+// replaced by, anything.
+func SetMax[K comparable, V cmp.Ordered](c conditionalSetter[K, V], k K, v V, d time.Duration) V {
+	return c.setIf(k, v, d, func(old V) bool { return v > old })
+}
+
+// SetMin is like SetMax, but stores v only if it is less than the current
+// value of k.
+func SetMin[K comparable, V cmp.Ordered](c conditionalSetter[K, V], k K, v V, d time.Duration) V {
+	return c.setIf(k, v, d, func(old V) bool { return v < old })
+}
+
+func (c *cache[K, V]) setIf(k K, v V, d time.Duration, replace func(old V) bool) V {
+	c.mu.Lock()
+	if old, found := c.get(k); found {
+		if old = c.decoded(old); !replace(old) {
+			c.mu.Unlock()
+			return old
+		}
+	}
+	c.set(k, v, d)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return v
+}
+
+func (sc *shardedCache[K, V]) setIf(k K, v V, d time.Duration, replace func(old V) bool) V {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).setIf(k, v, d, replace)
+}
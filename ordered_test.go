@@ -0,0 +1,61 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetMaxMin(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if v := SetMax(tc, "max", 5, NoExpiration); v != 5 {
+		t.Error("SetMax didn't store a missing item:", v)
+	}
+	tc.Set("kept", 1, time.Hour)
+	_, before, _ := tc.GetWithExpiration("kept")
+	if v := SetMax(tc, "kept", 0, NoExpiration); v != 1 {
+		t.Error("SetMax returned", v, "instead of the current value 1")
+	}
+	if _, after, _ := tc.GetWithExpiration("kept"); !after.Equal(before) {
+		t.Error("SetMax changed the expiration of an item it didn't replace")
+	}
+	if v := SetMax(tc, "max", 7, NoExpiration); v != 7 {
+		t.Error("SetMax didn't store a greater value:", v)
+	}
+	if v := SetMin(tc, "max", 9, NoExpiration); v != 7 {
+		t.Error("SetMin stored a greater value:", v)
+	}
+	if v := SetMin(tc, "max", 2, NoExpiration); v != 2 {
+		t.Error("SetMin didn't store a lesser value:", v)
+	}
+	if x, _ := tc.Get("max"); x != 2 {
+		t.Error("max is not 2:", x)
+	}
+
+	ts := New[string, string](DefaultExpiration, 0)
+	ts.Set("s", "b", 10*time.Millisecond)
+	<-time.After(20 * time.Millisecond)
+	if v := SetMin(ts, "s", "c", NoExpiration); v != "c" {
+		t.Error("SetMin didn't replace an expired item:", v)
+	}
+}
+
+func TestShardedSetMaxConcurrent(t *testing.T) {
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			SetMax(sc, "max", i, NoExpiration)
+			SetMin(sc, "min", i, NoExpiration)
+		}(i)
+	}
+	wg.Wait()
+	if x, _ := sc.Get("max"); x != 100 {
+		t.Error("max is not 100:", x)
+	}
+	if x, _ := sc.Get("min"); x != 1 {
+		t.Error("min is not 1:", x)
+	}
+}
@@ -0,0 +1,81 @@
+package ttlcache
+
+// Pin exempts the item k from capacity-based eviction, i.e. by the limits of
+// WithMaxItems, WithCost and WithMaxTotalCost, and reports whether k was found
+// (and hasn't expired). A pinned item is still removed by Delete, Flush and
+// the like, though not by FlushExpirable, and once it expires. It stays
+// pinned for as long as it is stored, including when a new value is stored at
+// k, and is unpinned when it is removed. Since pinned items are never chosen
+// for eviction, a cache whose pinned items alone exceed its limits stays over
+// them. Pins are copied by Clone.
+func (c *cache[K, V]) Pin(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.get(k); !found {
+		return false
+	}
+	if c.pinned == nil {
+		c.pinned = make(map[K]struct{})
+	}
+	if _, pinned := c.pinned[k]; !pinned {
+		c.pinned[k] = struct{}{}
+		if c.policy != nil {
+			c.policy.remove(k)
+		}
+	}
+	return true
+}
+
+// Unpin makes the item k subject to capacity-based eviction again, as the
+// most recently used item, and reports whether it was pinned. Items may be
+// evicted right away if the cache is over its limits.
+func (c *cache[K, V]) Unpin(k K) bool {
+	c.mu.Lock()
+	if _, pinned := c.pinned[k]; !pinned {
+		c.mu.Unlock()
+		return false
+	}
+	delete(c.pinned, k)
+	if c.policy != nil {
+		c.policy.insert(k)
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return true
+}
+
+// IsPinned reports whether the item k is pinned and hasn't expired.
+func (c *cache[K, V]) IsPinned(k K) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, pinned := c.pinned[k]
+	if !pinned {
+		return false
+	}
+	_, found := c.get(k)
+	return found
+}
+
+// Pin exempts the item k from capacity-based eviction. See Cache.Pin.
+func (sc *shardedCache[K, V]) Pin(k K) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).Pin(k)
+}
+
+// Unpin makes the item k subject to capacity-based eviction again. See
+// Cache.Unpin.
+func (sc *shardedCache[K, V]) Unpin(k K) bool {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).Unpin(k)
+}
+
+// IsPinned reports whether the item k is pinned and hasn't expired.
+func (sc *shardedCache[K, V]) IsPinned(k K) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).IsPinned(k)
+}
@@ -0,0 +1,68 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	for _, p := range []EvictionPolicy{PolicyLRU, PolicyLFU} {
+		tc := New[string, int](DefaultExpiration, 0,
+			WithMaxItems[string, int](3), WithEvictionPolicy[string, int](p))
+		if tc.Pin("a") {
+			t.Error("Pinned a even though it doesn't exist")
+		}
+		tc.Set("a", 1, DefaultExpiration)
+		if !tc.Pin("a") || !tc.IsPinned("a") {
+			t.Fatal("Couldn't pin a")
+		}
+		for i := 0; i < 10; i++ {
+			tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+		}
+		tc.Set("a", 2, DefaultExpiration)
+		tc.Set("x", 3, DefaultExpiration)
+		if x, found := tc.Get("a"); !found || x != 2 || !tc.IsPinned("a") {
+			t.Errorf("The pinned item was evicted or unpinned with policy %d: %v, %v", p, x, found)
+		}
+		if n := tc.ItemCount(); n != 3 {
+			t.Error("The cache holds", n, "items instead of 3")
+		}
+
+		if !tc.Unpin("a") || tc.IsPinned("a") {
+			t.Fatal("Couldn't unpin a")
+		}
+		if tc.Unpin("a") {
+			t.Error("Unpinned a twice")
+		}
+		tc.Pin("x")
+		tc.Delete("x")
+		if tc.IsPinned("x") {
+			t.Error("x is still pinned after it was deleted")
+		}
+	}
+}
+
+func TestShardedPin(t *testing.T) {
+	var evicted []string
+	sc := NewSharded[string, int](DefaultExpiration, 0, 1, WithMaxItems[string, int](2))
+	sc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+	sc.Set("a", 1, DefaultExpiration)
+	sc.Set("b", 2, DefaultExpiration)
+	sc.Pin("a")
+	sc.Pin("b")
+	sc.Set("c", 3, DefaultExpiration)
+	if len(evicted) != 1 || evicted[0] != "c" {
+		t.Fatal("The only unpinned item wasn't the one evicted:", evicted)
+	}
+	sc.Unpin("a")
+	sc.Set("d", 4, DefaultExpiration)
+	if len(evicted) != 2 || evicted[1] != "a" {
+		t.Error("The unpinned item wasn't evicted:", evicted)
+	}
+	sc.Reshard(4)
+	if !sc.IsPinned("b") {
+		t.Error("b was unpinned by Reshard")
+	}
+}
@@ -0,0 +1,35 @@
+package ttlcache
+
+import (
+	"strings"
+)
+
+// prefixCache is implemented by both *Cache and *ShardedCache.
+type prefixCache[K comparable, V any] interface {
+	Range(f func(k K, v V) bool)
+	DeleteFunc(pred func(k K, v V) bool) int
+}
+
+// ScanPrefix returns the unexpired items of c whose keys start with prefix. c
+// may be a *Cache or a *ShardedCache with string keys. There is no index of
+// the keys, so every item in the cache is looked at: ScanPrefix takes time
+// proportional to the size of the cache, not to the number of matches.
+func ScanPrefix[K ~string, V any](c prefixCache[K, V], prefix string) map[K]V {
+	m := map[K]V{}
+	c.Range(func(k K, v V) bool {
+		if strings.HasPrefix(string(k), prefix) {
+			m[k] = v
+		}
+		return true
+	})
+	return m
+}
+
+// DeletePrefix deletes the unexpired items of c whose keys start with prefix,
+// calling the eviction callback with ReasonDeleted, and returns the number of
+// items deleted. Like ScanPrefix, it looks at every item in the cache.
+func DeletePrefix[K ~string, V any](c prefixCache[K, V], prefix string) int {
+	return c.DeleteFunc(func(k K, v V) bool {
+		return strings.HasPrefix(string(k), prefix)
+	})
+}
@@ -0,0 +1,52 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+type tenantKey string
+
+func TestScanPrefix(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("tenant:1:a", 1, DefaultExpiration)
+	tc.Set("tenant:1:b", 2, DefaultExpiration)
+	tc.Set("tenant:2:a", 3, DefaultExpiration)
+	tc.Set("tenant:1:expired", 4, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	m := ScanPrefix(tc, "tenant:1:")
+	if len(m) != 2 || m["tenant:1:a"] != 1 || m["tenant:1:b"] != 2 {
+		t.Error("Unexpected items for tenant:1:", m)
+	}
+	if m := ScanPrefix(tc, "nope"); len(m) != 0 {
+		t.Error("Unexpected items for nope:", m)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	tc := NewSharded[tenantKey, int](DefaultExpiration, 0, 4)
+	for i, k := range shardedKeys {
+		tc.Set(tenantKey("a:"+k), i, DefaultExpiration)
+		tc.Set(tenantKey("b:"+k), i, DefaultExpiration)
+	}
+	var evicted int
+	tc.OnEvicted(func(k tenantKey, v int, reason EvictionReason) {
+		if reason != ReasonDeleted {
+			t.Errorf("%s was evicted with reason %v", k, reason)
+		}
+		evicted++
+	})
+	if n := DeletePrefix(tc, "a:"); n != len(shardedKeys) {
+		t.Errorf("%d items were deleted instead of %d", n, len(shardedKeys))
+	}
+	if evicted != len(shardedKeys) {
+		t.Errorf("The eviction callback was called %d times instead of %d", evicted, len(shardedKeys))
+	}
+	if m := ScanPrefix(tc, "a:"); len(m) != 0 {
+		t.Error("Items are left under a:", m)
+	}
+	if m := ScanPrefix(tc, "b:"); len(m) != len(shardedKeys) {
+		t.Errorf("%d items are left under b: instead of %d", len(m), len(shardedKeys))
+	}
+}
@@ -0,0 +1,89 @@
+package ttlcache
+
+// RenameKey moves the item stored at oldK, with its value and expiration time
+// intact, to newK, overwriting any item already stored there. It reports
+// whether oldK was found (and hadn't expired); if not, nothing is changed.
+func (c *cache[K, V]) RenameKey(oldK, newK K) bool {
+	c.mu.Lock()
+	moved := moveItem(c, c, oldK, newK)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return moved
+}
+
+// moveItem moves the item stored at oldK in src to newK in dst, and reports
+// whether it was found. It must be called with the locks of both src and dst
+// held.
+func moveItem[K comparable, V any](src, dst *cache[K, V], oldK, newK K) bool {
+	item, found := src.items[oldK]
+	if !found || (item.Expiration > 0 && src.now() > item.Expiration) {
+		return false
+	}
+	if src == dst && oldK == newK {
+		return true
+	}
+	transferItem(src, dst, oldK, newK, item)
+	return true
+}
+
+// transferItem moves item, stored at oldK in src, to newK in dst, whether or
+// not it has expired. It must be called with the locks of both src and dst
+// held.
+func transferItem[K comparable, V any](src, dst *cache[K, V], oldK, newK K, item Item[V]) {
+	n, limited := src.uses[oldK]
+	_, pinned := src.pinned[oldK]
+	tags := src.keyTags[oldK]
+	src.delete(oldK)
+	// The item keeps its version, so dst has to skip past it to keep its
+	// versions increasing.
+	dst.version = max(dst.version, item.Version)
+	// The item stored at newK, if any, is overwritten along with its read
+	// limit, pin and tags.
+	dst.delete(newK)
+	dst.items[newK] = item
+	if limited {
+		if dst.uses == nil {
+			dst.uses = make(map[K]int)
+			dst.limitedUses.Store(true)
+		}
+		dst.uses[newK] = n
+	}
+	if pinned {
+		if dst.pinned == nil {
+			dst.pinned = make(map[K]struct{})
+		}
+		dst.pinned[newK] = struct{}{}
+	}
+	dst.tag(newK, tags)
+	if dst.tracking {
+		dst.track(newK, item.Object)
+	}
+}
+
+// RenameKey moves the item stored at oldK to newK. See Cache.RenameKey. If the
+// keys belong to different shards, both shards are locked, always in the same
+// order, so that concurrent renames can't deadlock.
+func (sc *shardedCache[K, V]) RenameKey(oldK, newK K) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	i, j := sc.index(oldK), sc.index(newK)
+	if i == j {
+		return sc.cs[i].RenameKey(oldK, newK)
+	}
+	src, dst := sc.cs[i], sc.cs[j]
+	first, second := src, dst
+	if j < i {
+		first, second = dst, src
+	}
+	first.mu.Lock()
+	second.mu.Lock()
+	moved := moveItem(src, dst, oldK, newK)
+	evicted := dst.evictOverflow()
+	f := dst.onEvicted
+	second.mu.Unlock()
+	first.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	return moved
+}
@@ -0,0 +1,108 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRenameKey(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if tc.RenameKey("foo", "bar") {
+		t.Error("Renamed foo when it doesn't exist")
+	}
+	tc.Set("foo", 1, time.Hour)
+	_, expiration, _ := tc.GetWithExpiration("foo")
+	tc.Set("bar", 2, DefaultExpiration)
+	if !tc.RenameKey("foo", "bar") {
+		t.Error("Couldn't rename foo")
+	}
+	if _, found := tc.Get("foo"); found {
+		t.Error("foo was found after renaming it")
+	}
+	x, e, found := tc.GetWithExpiration("bar")
+	if !found || x != 1 || !e.Equal(expiration) {
+		t.Errorf("bar is %d, expiring at %v, not 1 expiring at %v", x, e, expiration)
+	}
+	if !tc.RenameKey("bar", "bar") {
+		t.Error("Couldn't rename bar to itself")
+	}
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is not 1: %d", n)
+	}
+
+	tc.Set("expired", 1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if tc.RenameKey("expired", "baz") {
+		t.Error("Renamed expired after it expired")
+	}
+}
+
+func TestRenameKeyTracking(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0, WithCost[string, string](func(v string) int64 {
+		return int64(len(v))
+	}, 0))
+	tc.Set("foo", "abc", DefaultExpiration)
+	tc.RenameKey("foo", "bar")
+	if n := tc.Cost(); n != 3 {
+		t.Errorf("Cost is not 3 after renaming: %d", n)
+	}
+}
+
+func TestRenameKeyOverwrites(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	tc.Set("foo", "abc", DefaultExpiration)
+	tc.SetWithTags("bar", "def", DefaultExpiration, "old")
+	tc.Pin("bar")
+	tc.RenameKey("foo", "bar")
+	if tc.IsPinned("bar") {
+		t.Error("bar kept the pin of the item it overwrote")
+	}
+	if n := tc.InvalidateTag("old"); n != 0 {
+		t.Error("bar kept the tags of the item it overwrote:", n)
+	}
+	if x, found := tc.Get("bar"); !found || x != "abc" {
+		t.Error("bar is not abc:", x)
+	}
+}
+
+func TestShardedRenameKey(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, k := range shardedKeys {
+		tc.Set(k, i, DefaultExpiration)
+	}
+	for i, k := range shardedKeys {
+		if !tc.RenameKey(k, k+"!") {
+			t.Errorf("Couldn't rename %s", k)
+		}
+		if x, found := tc.Get(k + "!"); !found || x != i {
+			t.Errorf("%s! is not %d: %d", k, i, x)
+		}
+	}
+	if n := tc.ItemCount(); n != len(shardedKeys) {
+		t.Errorf("Item count is not %d: %d", len(shardedKeys), n)
+	}
+}
+
+func TestShardedRenameKeyConcurrent(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	a, b := shardedKeys[0], shardedKeys[len(shardedKeys)-1]
+	tc.Set(a, 1, DefaultExpiration)
+	tc.Set(b, 2, DefaultExpiration)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tc.RenameKey(a, b)
+		}()
+		go func() {
+			defer wg.Done()
+			tc.RenameKey(b, a)
+		}()
+	}
+	wg.Wait()
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is not 1: %d", n)
+	}
+}
@@ -0,0 +1,106 @@
+package ttlcache
+
+// Reshard changes the number of shards of the cache to n (at least one), and
+// redistributes the items over the new shards with the cache's hash and seed.
+// It does nothing if the cache already has n shards.
+//
+// Reshard is expensive: it stalls every other operation on the cache until it
+// is done, and takes time proportional to the number of items, so it is meant
+// to be called rarely, e.g. when the expected load changes. It waits for
+// in-flight operations to finish first. Loaders, and the callbacks of a
+// sharded cache (the eviction and expiration callbacks, the OnCleanup hook,
+// the function passed to FlushWith and the WithDrainOnClose function), are
+// run outside of the operations Reshard waits for, so they may call back into
+// the cache, or call Reshard themselves, without deadlocking.
+//
+// Every item is moved, with its expiration time and version, including
+// expired items the janitor hasn't deleted yet. Items are moved in eviction
+// order, so with PolicyLRU each new shard keeps the recency of its items, but
+// PolicyLFU access counts start over. The eviction callbacks, subscribers and
+// stats carry over. If WithMaxItems or WithCost caps the shards, fewer shards
+// hold fewer items in all, and the excess is evicted with ReasonCapacity.
+func (sc *shardedCache[K, V]) Reshard(n int) {
+	if n < 1 {
+		n = 1
+	}
+	sc.mu.Lock()
+	if n == len(sc.cs) {
+		sc.mu.Unlock()
+		return
+	}
+	old := sc.cs
+	for _, c := range old {
+		c.mu.Lock()
+	}
+	first := old[0]
+	// Versions stay increasing for every key, wherever it moves to, if all
+	// new shards start past the versions handed out by any of the old ones.
+	var version uint64
+	for _, c := range old {
+		version = max(version, c.version)
+	}
+	sc.cs = make([]*cache[K, V], n)
+	for i := range sc.cs {
+		c := sc.newShard(first.defaultExpiration, map[K]Item[V]{}, first.cfg)
+		c.evictedFunc = first.evictedFunc
+		c.subs = first.subs
+		c.onEvictedBatch = first.onEvictedBatch
+		c.beforeEvict = first.beforeEvict
+		c.onExpired = first.onExpired
+		c.updateOnEvicted()
+		c.version = version
+		sc.cs[i] = c
+	}
+	sc.m = uint32(n)
+	sc.mask = uint32(n) - 1
+	sc.pow2 = n&(n-1) == 0
+	for _, c := range old {
+		sc.reshardFrom(c)
+		sc.cs[0].stats.merge(&c.stats)
+	}
+	for _, c := range old {
+		c.mu.Unlock()
+	}
+	type overflow struct {
+		f       func(K, V, EvictionReason)
+		evicted []keyAndValue[K, V]
+	}
+	var overflows []overflow
+	for _, c := range sc.cs {
+		if evicted := c.evictOverflow(); len(evicted) > 0 {
+			overflows = append(overflows, overflow{c.onEvicted, evicted})
+		}
+	}
+	sc.mu.Unlock()
+	for _, o := range overflows {
+		notifyEvicted(o.f, o.evicted, ReasonCapacity)
+	}
+	sc.outbox.deliver()
+	if first.cfg.staggered {
+		// Spread the sweeps over the new number of shards.
+		if ci := sc.cleanupInterval(); ci > 0 {
+			sc.SetCleanupInterval(ci)
+		}
+	}
+}
+
+// reshardFrom moves all items of src, a shard of the old layout, into the
+// shards of the new one. It must be called with sc.mu and src.mu held; the new
+// shards can't be reached by anything else yet, so they needn't be locked.
+func (sc *shardedCache[K, V]) reshardFrom(src *cache[K, V]) {
+	if src.policy != nil {
+		for k, ok := src.policy.victim(); ok; k, ok = src.policy.victim() {
+			transferItem(src, sc.bucket(k), k, k, src.items[k])
+		}
+	}
+	for k, item := range src.items {
+		transferItem(src, sc.bucket(k), k, k, item)
+	}
+	for k, e := range src.negatives {
+		dst := sc.bucket(k)
+		if dst.negatives == nil {
+			dst.negatives = make(map[K]int64)
+		}
+		dst.negatives[k] = e
+	}
+}
@@ -0,0 +1,192 @@
+package ttlcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReshard(t *testing.T) {
+	clock := newFakeClock()
+	tc := NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock))
+	for i := 0; i < 100; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	tc.Set("forever", -1, NoExpiration)
+	_, version, _ := tc.GetWithVersion("0")
+	var evicted []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+
+	for _, n := range []int{7, 16, 1} {
+		tc.Reshard(n)
+		if got := tc.ShardCount(); got != n {
+			t.Fatalf("ShardCount is %d after Reshard(%d)", got, n)
+		}
+		if got := tc.ItemCount(); got != 101 {
+			t.Fatalf("%d items after Reshard(%d), want 101", got, n)
+		}
+		for i := 0; i < 100; i++ {
+			if v, found := tc.Get(strconv.Itoa(i)); !found || v != i {
+				t.Fatalf("Item %d is %d, %v after Reshard(%d)", i, v, found, n)
+			}
+		}
+		if v, ttl, found := tc.GetWithTTL("forever"); !found || v != -1 || ttl != NoExpiration {
+			t.Error("forever lost its value or expiration:", v, ttl, found)
+		}
+		if _, v, _ := tc.GetWithVersion("0"); v != version {
+			t.Errorf("The version of 0 changed from %d to %d", version, v)
+		}
+	}
+	if s := tc.Stats(); s.Hits != 1+3*102 {
+		t.Error("The stats weren't carried over, hits:", s.Hits)
+	}
+
+	tc.Set("0", 0, DefaultExpiration)
+	if _, v, _ := tc.GetWithVersion("0"); v <= version {
+		t.Error("Setting an item after Reshard reused a version:", v)
+	}
+	if tc.CompareVersionAndSwap("0", version, 1, DefaultExpiration) {
+		t.Error("A stale version matched after Reshard")
+	}
+
+	clock.Advance(2 * time.Minute)
+	tc.Reshard(3)
+	if got := tc.ItemCountIncludingExpired(); got != 101 {
+		t.Error("Expired items weren't moved by Reshard:", got)
+	}
+	tc.DeleteExpired()
+	if len(evicted) != 100 {
+		t.Errorf("The eviction callback was called %d times instead of 100", len(evicted))
+	}
+}
+
+func TestReshardSameCount(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	tc.Set("a", 1, DefaultExpiration)
+	shards := tc.cs
+	tc.Reshard(4)
+	if tc.cs[0] != shards[0] {
+		t.Error("Reshard to the same count rebuilt the shards")
+	}
+	tc.Reshard(0)
+	if tc.ShardCount() != 1 {
+		t.Error("Reshard(0) didn't give a single shard:", tc.ShardCount())
+	}
+}
+
+func TestReshardEvictsOverflow(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 4,
+		WithMaxItems[int, int](10),
+		WithHashFunc[int, int](IntegerHash[int]))
+	for i := 0; i < 40; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	n := tc.ItemCount()
+	var evicted int
+	tc.OnEvicted(func(k, v int, reason EvictionReason) {
+		if reason != ReasonCapacity {
+			t.Error("Evicted with reason", reason)
+		}
+		evicted++
+	})
+	tc.Reshard(1)
+	if got := tc.ItemCount(); got != 10 {
+		t.Errorf("%d items left in a single shard capped at 10", got)
+	}
+	if evicted != n-10 {
+		t.Errorf("%d items were evicted, want %d", evicted, n-10)
+	}
+}
+
+func TestReshardConcurrent(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 2)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				k := strconv.Itoa(i*1000 + j%1000)
+				tc.Set(k, j, DefaultExpiration)
+				tc.Get(k)
+			}
+		}(i)
+	}
+	for _, n := range []int{3, 8, 1, 5} {
+		tc.Reshard(n)
+	}
+	close(stop)
+	wg.Wait()
+	if got, want := tc.ItemCount(), len(tc.AllItems()); got != want {
+		t.Errorf("ItemCount is %d but there are %d items", got, want)
+	}
+}
+
+func TestReshardWithReentrantCallbacks(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	// reshardDuring calls Reshard as soon as start is closed, and returns
+	// once f has returned after Reshard had time to wait for the layout
+	// lock, failing if that deadlocks.
+	reshardDuring := func(f func(start, resume chan struct{})) {
+		t.Helper()
+		start, resume := make(chan struct{}), make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f(start, resume)
+		}()
+		<-start
+		resharded := make(chan struct{})
+		go func() {
+			defer close(resharded)
+			tc.Reshard(tc.ShardCount() * 2)
+		}()
+		<-time.After(10 * time.Millisecond)
+		close(resume)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Calling back into the cache deadlocked with Reshard")
+		}
+		<-resharded
+	}
+
+	tc.Set("a", 1, DefaultExpiration)
+	reshardDuring(func(start, resume chan struct{}) {
+		tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+			close(start)
+			<-resume
+			tc.Set("b", v, DefaultExpiration)
+		})
+		tc.Delete("a")
+	})
+	tc.OnEvicted(nil)
+	reshardDuring(func(start, resume chan struct{}) {
+		v, err := tc.GetOrLoad("c", DefaultExpiration, func(string) (int, error) {
+			close(start)
+			<-resume
+			tc.Set("d", 4, DefaultExpiration)
+			return 3, nil
+		})
+		if err != nil || v != 3 {
+			t.Error("Unexpected result from GetOrLoad:", v, err)
+		}
+	})
+	for k, want := range map[string]int{"b": 1, "c": 3, "d": 4} {
+		if v, found := tc.Get(k); !found || v != want {
+			t.Errorf("%s is %d, %v, want %d", k, v, found, want)
+		}
+	}
+	if n := tc.ShardCount(); n != 16 {
+		t.Error("Reshard didn't complete:", n)
+	}
+}
@@ -0,0 +1,67 @@
+package ttlcache
+
+import (
+	"math/rand"
+)
+
+// SampleKeys returns up to n pseudo-randomly chosen keys of unexpired items in
+// the cache. It takes time proportional to n rather than to the size of the
+// cache (plus any expired items it skips), since it relies on the randomized
+// starting point of Go's map iteration. That randomization is neither
+// cryptographically secure nor uniform: keys that are close to each other in
+// the map are likely to be sampled together, which is good enough for
+// approximate eviction and for observability, but not for anything that needs
+// a fair sample.
+func (c *cache[K, V]) SampleKeys(n int) []K {
+	if n <= 0 {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sampleKeys(make([]K, 0, min(n, len(c.items))), n)
+}
+
+// sampleKeys appends up to n keys of unexpired items to keys. It must be
+// called with c.mu held.
+func (c *cache[K, V]) sampleKeys(keys []K, n int) []K {
+	now := c.now()
+	for k, v := range c.items {
+		if n == 0 {
+			break
+		}
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		keys = append(keys, k)
+		n--
+	}
+	return keys
+}
+
+// SampleKeys returns up to n pseudo-randomly chosen keys of unexpired items.
+// The shards are visited in turn, starting at a random one, and each is asked
+// for an even share of the keys still missing from the sample, so a shard with
+// too few items has its share made up by the shards after it. See
+// Cache.SampleKeys for the caveats of the sampling.
+func (sc *shardedCache[K, V]) SampleKeys(n int) []K {
+	sc.mu.RLock()
+	defer sc.unlock()
+	if n <= 0 {
+		return nil
+	}
+	keys := make([]K, 0, n)
+	start := rand.Intn(len(sc.cs))
+	for i := range sc.cs {
+		left := len(sc.cs) - i
+		want := (n - len(keys) + left - 1) / left
+		if want == 0 {
+			break
+		}
+		c := sc.cs[(start+i)%len(sc.cs)]
+		c.mu.RLock()
+		keys = c.sampleKeys(keys, want)
+		c.mu.RUnlock()
+	}
+	return keys
+}
@@ -0,0 +1,60 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampleKeys(t *testing.T) {
+	tc := New[int, int](DefaultExpiration, 0)
+	if keys := tc.SampleKeys(5); len(keys) != 0 {
+		t.Error("Sampled keys from an empty cache:", keys)
+	}
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.Set(-1, -1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	keys := tc.SampleKeys(10)
+	if len(keys) != 10 {
+		t.Errorf("Sampled %d keys instead of 10", len(keys))
+	}
+	seen := map[int]bool{}
+	for _, k := range keys {
+		if k < 0 {
+			t.Error("Sampled an expired key")
+		}
+		if seen[k] {
+			t.Error("Sampled a key twice:", k)
+		}
+		seen[k] = true
+	}
+	if keys := tc.SampleKeys(1000); len(keys) != 100 {
+		t.Errorf("Sampled %d keys instead of all 100", len(keys))
+	}
+	if keys := tc.SampleKeys(0); keys != nil {
+		t.Error("Sampled keys for n = 0:", keys)
+	}
+}
+
+func TestShardedSampleKeys(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 8, WithHashFunc[int, int](IntegerHash[int]))
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	keys := tc.SampleKeys(20)
+	if len(keys) != 20 {
+		t.Errorf("Sampled %d keys instead of 20", len(keys))
+	}
+	seen := map[int]bool{}
+	for _, k := range keys {
+		if seen[k] {
+			t.Error("Sampled a key twice:", k)
+		}
+		seen[k] = true
+	}
+	if keys := tc.SampleKeys(1000); len(keys) != 100 {
+		t.Errorf("Sampled %d keys instead of all 100", len(keys))
+	}
+}
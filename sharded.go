@@ -1,45 +1,73 @@
 package ttlcache
 
 import (
+	"context"
 	"crypto/rand"
 	"math"
 	"math/big"
 	insecurerand "math/rand"
-	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// This is an experimental and unexported (for now) attempt at making a cache
-// with better algorithmic complexity than the standard one, namely by
-// preventing write locks of the entire cache when an item is added. As of the
-// time of writing, the overhead of selecting buckets results in cache
-// operations being about twice as slow as for the standard cache with small
-// total cache sizes, and faster for larger ones.
+// ShardedCache is a cache with better algorithmic complexity than the standard
+// one, namely by preventing write locks of the entire cache when an item is
+// added. Items are spread over a fixed number of shards (buckets), each of
+// which is a standard cache with its own lock. As of the time of writing, the
+// overhead of selecting buckets results in cache operations being about twice
+// as slow as for the standard cache with small total cache sizes, and faster
+// for larger ones.
 //
-// See cache_test.go for a few benchmarks.
-
-type unexportedShardedCache[K comparable, V any] struct {
+// Keys are assigned to shards with a seeded djb33 hash for string keys, and a
+// seeded multiplicative hash for keys of integer types. Keys of any other type
+// are hashed with reflection, which works for every comparable type but is
+// considerably slower; a faster hash function can be supplied with
+// WithHashFunc, or by implementing Hashable.
+//
+// See sharded_test.go for a few benchmarks.
+type ShardedCache[K comparable, V any] struct {
 	*shardedCache[K, V]
 }
 
 type shardedCache[K comparable, V any] struct {
-	seed    uint32
-	m       uint32
-	cs      []*cache[K, V]
-	janitor *shardedJanitor[K, V]
+	// mu guards the shard layout: cs, m, mask and pow2. Every operation
+	// holds it for reading, and Reshard for writing.
+	mu    sync.RWMutex
+	seed  uint32
+	hash  func(K) uint32
+	m     uint32
+	mask  uint32 // m-1 if m is a power of two, used instead of % m
+	pow2  bool
+	cs    []*cache[K, V]
+	clock Clock
+	subs  *subscribers[K, V] // shared by the shards once Subscribe is called
+
+	totalCost    atomic.Int64 // the summed cost of all shards; see WithMaxTotalCost
+	maxTotalCost int64
+
+	// outbox holds the callbacks of the shards until sc.mu is released;
+	// see unlock.
+	outbox outbox
+	// loads deduplicates the loads of GetOrLoad and Warm, which run
+	// without sc.mu held.
+	loads loadGroup[K, V]
+	// tags is the tag index of every shard; see InvalidateTag.
+	tags tagIndex[K]
+
+	onCleanup atomic.Pointer[func(int, time.Duration)]
+	// janitorMu guards janitor.
+	janitorMu sync.Mutex
+	janitor   *shardedJanitor[K, V]
+	// sweepShard is the index of the shard the janitor's incremental sweep
+	// is in; see tick.
+	sweepShard int
+	closeOnce  sync.Once // drains the shards on the first Close
 }
 
 // djb2 with better shuffling. 5x faster than FNV with the hash.Hash overhead.
-func djb33[K comparable, V any](seed uint32, k K) uint32 {
-	var kRaw []byte
-	switch key := any(k).(type) {
-	case string:
-		kRaw = []byte(key)
-	case []byte:
-		kRaw = key
-	}
-
+func djb33(seed uint32, kRaw string) uint32 {
 	var (
 		l = uint32(len(kRaw))
 		d = 5381 + seed + l
@@ -70,42 +98,291 @@ func djb33[K comparable, V any](seed uint32, k K) uint32 {
 	return d ^ (d >> 16)
 }
 
+// unlock releases the read lock on sc.mu taken by an operation, and then runs
+// the callbacks of the shards that the operation, or any other, queued in the
+// meantime; see outbox.
+func (sc *shardedCache[K, V]) unlock() {
+	sc.mu.RUnlock()
+	sc.outbox.deliver()
+}
+
 func (sc *shardedCache[K, V]) bucket(k K) *cache[K, V] {
-	return sc.cs[djb33[K, V](sc.seed, k)%sc.m]
+	return sc.cs[sc.index(k)]
+}
+
+// index returns the index of the shard holding k.
+func (sc *shardedCache[K, V]) index(k K) uint32 {
+	if sc.pow2 {
+		return sc.hash(k) & sc.mask
+	}
+	return sc.hash(k) % sc.m
 }
 
+// Set an item to the cache, replacing any existing item. See Cache.Set.
 func (sc *shardedCache[K, V]) Set(k K, x V, d time.Duration) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
 	sc.bucket(k).Set(k, x, d)
 }
 
+// SetDefault sets an item to the cache, replacing any existing item, using the
+// cache's default expiration. See Cache.SetDefault.
+func (sc *shardedCache[K, V]) SetDefault(k K, x V) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetDefault(k, x)
+}
+
+// SetNoExpire sets an item to the cache, replacing any existing item, so that
+// it never expires. See Cache.SetNoExpire.
+func (sc *shardedCache[K, V]) SetNoExpire(k K, x V) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetNoExpire(k, x)
+}
+
+// SetTTL sets an item to the cache, replacing any existing item, so that it
+// expires after ttl, which must be positive. See Cache.SetTTL.
+func (sc *shardedCache[K, V]) SetTTL(k K, x V, ttl time.Duration) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetTTL(k, x, ttl)
+}
+
+// Add an item to the cache only if an item doesn't already exist for the given
+// key, or if the existing item has expired. Returns an error wrapping
+// ErrKeyExists otherwise.
 func (sc *shardedCache[K, V]) Add(k K, x V, d time.Duration) error {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
 	return sc.bucket(k).Add(k, x, d)
 }
 
+// SetIfAbsent sets an item to the cache only if an item doesn't already exist
+// for the given key, or if the existing item has expired. It reports whether
+// the item was set.
+func (sc *shardedCache[K, V]) SetIfAbsent(k K, x V, d time.Duration) bool {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).SetIfAbsent(k, x, d)
+}
+
+// SetIfPresent sets a new value for an item only if it already exists for the
+// given key and hasn't expired. See Cache.SetIfPresent.
+func (sc *shardedCache[K, V]) SetIfPresent(k K, x V, d time.Duration) bool {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).SetIfPresent(k, x, d)
+}
+
+// GetOrSet returns the existing value for k if it is present, or sets and
+// returns x otherwise. See Cache.GetOrSet.
+func (sc *shardedCache[K, V]) GetOrSet(k K, x V, d time.Duration) (V, bool) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).GetOrSet(k, x, d)
+}
+
+// Replace sets a new value for the cache key only if it already exists, and the
+// existing item hasn't expired. Returns an error wrapping ErrKeyNotFound
+// otherwise.
 func (sc *shardedCache[K, V]) Replace(k K, x V, d time.Duration) error {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
 	return sc.bucket(k).Replace(k, x, d)
 }
 
-func (sc *shardedCache[K, V]) Get(k K) (interface{}, bool) {
+// Get an item from the cache. Returns the item or the zero value of V, and a
+// bool indicating whether the key was found.
+func (sc *shardedCache[K, V]) Get(k K) (V, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
 	return sc.bucket(k).Get(k)
 }
 
+// SetWithUses sets an item to the cache that can only be read maxUses times.
+// See Cache.SetWithUses.
+func (sc *shardedCache[K, V]) SetWithUses(k K, x V, maxUses int) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetWithUses(k, x, maxUses)
+}
+
+// MustGet returns the value of k, and panics if it is not found. See
+// Cache.MustGet.
+func (sc *shardedCache[K, V]) MustGet(k K) V {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).MustGet(k)
+}
+
+// GetOr returns the value of k, or fallback if it is not found. See
+// Cache.GetOr.
+func (sc *shardedCache[K, V]) GetOr(k K, fallback V) V {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetOr(k, fallback)
+}
+
+// Has reports whether k is in the cache and hasn't expired, without counting
+// it as a use. See Cache.Has.
+func (sc *shardedCache[K, V]) Has(k K) bool {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).Has(k)
+}
+
+// GetWithExpiration returns an item and its expiration time from the cache.
+// See Cache.GetWithExpiration.
+func (sc *shardedCache[K, V]) GetWithExpiration(k K) (V, time.Time, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetWithExpiration(k)
+}
+
+// GetOrLoad returns the value for k, loading and storing it with loader if it
+// is missing or has expired. See Cache.GetOrLoad.
+func (sc *shardedCache[K, V]) GetOrLoad(k K, d time.Duration, loader func(K) (V, error)) (V, error) {
+	return sc.GetOrLoadWith(k, d, LoadConfig{}, loader)
+}
+
+// GetOrLoadWith is like GetOrLoad, with the behaviour of the load configured
+// by cfg. See Cache.GetOrLoadWith.
+func (sc *shardedCache[K, V]) GetOrLoadWith(k K, d time.Duration, cfg LoadConfig, loader func(K) (V, error)) (V, error) {
+	return getOrLoadWith[K, V](sc, &sc.loads, k, d, cfg, loader)
+}
+
+// GetOrLoadContext is like GetOrLoad, but the wait for the value is bounded by
+// ctx. See Cache.GetOrLoadContext.
+func (sc *shardedCache[K, V]) GetOrLoadContext(ctx context.Context, k K, d time.Duration, loader func(context.Context, K) (V, error)) (V, error) {
+	return getOrLoadContext[K, V](ctx, sc, &sc.loads, k, d, loader)
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
 func (sc *shardedCache[K, V]) Delete(k K) {
+	sc.mu.RLock()
+	defer sc.unlock()
 	sc.bucket(k).Delete(k)
 }
 
+// DeleteExpired deletes all expired items from the cache, one shard at a time.
+// It can be called directly for manual cleanup; see Cache.DeleteExpired.
 func (sc *shardedCache[K, V]) DeleteExpired() {
+	sc.mu.RLock()
+	defer sc.unlock()
 	for _, v := range sc.cs {
 		v.DeleteExpired()
 	}
 }
 
-// Returns the items in the cache. This may include items that have expired,
-// but have not yet been cleaned up. If this is significant, the Expiration
-// fields of the items should be checked. Note that explicit synchronization
-// is needed to use a cache and its corresponding Items() return values at
-// the same time, as the maps are shared.
+// DeleteExpiredKeys deletes all expired items from the cache, one shard at a
+// time, and returns the keys of the deleted items.
+func (sc *shardedCache[K, V]) DeleteExpiredKeys() []K {
+	sc.mu.RLock()
+	defer sc.unlock()
+	var keys []K
+	for _, v := range sc.cs {
+		keys = append(keys, v.DeleteExpiredKeys()...)
+	}
+	return keys
+}
+
+// sweep deletes all expired items from the cache, one shard at a time, and
+// calls the OnCleanup hook, if set. It is run by CleanupNow, and by the
+// janitor unless the cache was created with WithCleanupBatchSize.
+func (sc *shardedCache[K, V]) sweep() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.sweepLocked()
+}
+
+// sweepLocked is sweep for callers that hold sc.mu already.
+func (sc *shardedCache[K, V]) sweepLocked() int {
+	start := time.Now()
+	n := 0
+	for _, v := range sc.cs {
+		_, m := v.deleteExpired(false)
+		n += m
+	}
+	sc.cleanedUp(n, start)
+	return n
+}
+
+// cleanedUp queues a call to the OnCleanup hook, if set, for a sweep that
+// started at start and deleted n items. It must be called with sc.mu held.
+func (sc *shardedCache[K, V]) cleanedUp(n int, start time.Time) {
+	if f := sc.onCleanup.Load(); f != nil {
+		d := time.Since(start)
+		sc.outbox.push(func() { (*f)(n, d) })
+	}
+}
+
+// CleanupNow runs a sweep of all shards right away, and returns the number of
+// items deleted. See Cache.CleanupNow.
+func (sc *shardedCache[K, V]) CleanupNow() int {
+	return sc.sweep()
+}
+
+// OnCleanup sets an (optional) function that is called by the janitor (or
+// CleanupNow) after each sweep of all shards. See Cache.OnCleanup.
+func (sc *shardedCache[K, V]) OnCleanup(f func(removed int, duration time.Duration)) {
+	if f == nil {
+		sc.onCleanup.Store(nil)
+		return
+	}
+	sc.onCleanup.Store(&f)
+}
+
+// OnEvicted sets the eviction callback on every shard. See Cache.OnEvicted.
+// The callbacks of all shards, including those set by OnExpired and
+// OnEvictedBatch, run one at a time in the order they were triggered, once
+// the operation that triggered them has released the shard layout; a callback
+// triggered by another goroutine's operation may therefore run in the
+// goroutine of the next operation to finish.
+func (sc *shardedCache[K, V]) OnEvicted(f func(K, V, EvictionReason)) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.OnEvicted(f)
+	}
+}
+
+// OnExpired sets the expiration callback on every shard. See
+// Cache.OnExpired.
+func (sc *shardedCache[K, V]) OnExpired(f func(k K, v V)) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.OnExpired(f)
+	}
+}
+
+// OnBeforeEvict sets the eviction veto function on every shard. See
+// Cache.OnBeforeEvict.
+func (sc *shardedCache[K, V]) OnBeforeEvict(f func(k K, v V) bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.OnBeforeEvict(f)
+	}
+}
+
+// OnEvictedBatch sets the batch expiration callback on every shard. It is
+// called once for each shard that a sweep removes items from. See
+// Cache.OnEvictedBatch.
+func (sc *shardedCache[K, V]) OnEvictedBatch(f func([]Event[K, V])) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.OnEvictedBatch(f)
+	}
+}
+
+// Items returns a copy of the unexpired items of each shard, one map per
+// shard. See AllItems for a single merged map.
 func (sc *shardedCache[K, V]) Items() []map[K]Item[V] {
+	sc.mu.RLock()
+	defer sc.unlock()
 	res := make([]map[K]Item[V], len(sc.cs))
 	for i, v := range sc.cs {
 		res[i] = v.Items()
@@ -113,76 +390,364 @@ func (sc *shardedCache[K, V]) Items() []map[K]Item[V] {
 	return res
 }
 
+// Keys returns the keys of all unexpired items in the cache, shard by shard.
+// Each shard is read under its own read lock, so the result is a
+// point-in-time snapshot of each shard rather than of the cache as a whole.
+// See Cache.Keys.
+func (sc *shardedCache[K, V]) Keys() []K {
+	sc.mu.RLock()
+	defer sc.unlock()
+	var keys []K
+	for _, c := range sc.cs {
+		c.mu.RLock()
+		keys = c.appendKeys(keys)
+		c.mu.RUnlock()
+	}
+	return keys
+}
+
+// ItemsRef returns the underlying items map of each shard, one map per shard,
+// without copying them. The same warning as for Cache.ItemsRef applies: the
+// maps are shared with the cache and must not be accessed while it is in use.
+func (sc *shardedCache[K, V]) ItemsRef() []map[K]Item[V] {
+	sc.mu.RLock()
+	defer sc.unlock()
+	res := make([]map[K]Item[V], len(sc.cs))
+	for i, v := range sc.cs {
+		res[i] = v.ItemsRef()
+	}
+	return res
+}
+
+// AllItems copies the unexpired items of all shards into a single new map and
+// returns it. Shards are copied one at a time, so the result is a
+// point-in-time copy of each shard rather than of the cache as a whole: items
+// set to or deleted from a shard that has already been copied are not
+// reflected.
+func (sc *shardedCache[K, V]) AllItems() map[K]Item[V] {
+	sc.mu.RLock()
+	defer sc.unlock()
+	m := map[K]Item[V]{}
+	for _, v := range sc.cs {
+		v.mu.RLock()
+		now := v.now()
+		for k, item := range v.items {
+			if item.Expiration > 0 && now > item.Expiration {
+				continue
+			}
+			item.Object = v.decoded(item.Object)
+			m[k] = item
+		}
+		v.mu.RUnlock()
+	}
+	return m
+}
+
+// Range calls f for each unexpired item in the cache until f returns false.
+// Shards are visited one at a time, and only the shard being visited is
+// locked, so f sees no consistent snapshot of the whole cache. As with
+// Cache.Range, f must not call back into the cache.
+func (sc *shardedCache[K, V]) Range(f func(k K, v V) bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	stopped := false
+	for _, v := range sc.cs {
+		v.Range(func(k K, x V) bool {
+			stopped = !f(k, x)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// Cost returns the summed cost of the items in all shards. See Cache.Cost.
+func (sc *shardedCache[K, V]) Cost() int64 {
+	sc.mu.RLock()
+	defer sc.unlock()
+	var n int64
+	for _, v := range sc.cs {
+		n += v.Cost()
+	}
+	return n
+}
+
+// ItemCount returns the number of unexpired items in all shards. See
+// Cache.ItemCount.
+func (sc *shardedCache[K, V]) ItemCount() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	n := 0
+	for _, v := range sc.cs {
+		n += v.ItemCount()
+	}
+	return n
+}
+
+// ItemCountIncludingExpired returns the number of items in all shards. This may
+// include items that have expired, but have not yet been cleaned up.
+func (sc *shardedCache[K, V]) ItemCountIncludingExpired() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	n := 0
+	for _, v := range sc.cs {
+		n += v.ItemCountIncludingExpired()
+	}
+	return n
+}
+
+// ShardCount returns the number of shards of the cache.
+func (sc *shardedCache[K, V]) ShardCount() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return len(sc.cs)
+}
+
+// Seed returns the seed of the hash the cache assigns keys to shards with,
+// whether it was set with WithSeed or chosen at random. Sharded caches created
+// WithSeed(sc.Seed()), with the same number of shards, key type and hash
+// function, place every key in the shard of the same index.
+func (sc *shardedCache[K, V]) Seed() uint32 {
+	return sc.seed
+}
+
+// BucketIndex returns the index of the shard k is assigned to, in the range
+// [0, ShardCount()), whether or not k is in the cache, e.g. to check that
+// related keys are co-located. The index changes if the cache is resharded.
+func (sc *shardedCache[K, V]) BucketIndex(k K) int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return int(sc.index(k))
+}
+
+// ShardSizes returns the number of unexpired items in each shard, indexed like
+// the shards, e.g. to check how evenly the keys are spread over them. Shards
+// are counted one at a time.
+func (sc *shardedCache[K, V]) ShardSizes() []int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	sizes := make([]int, len(sc.cs))
+	for i, v := range sc.cs {
+		sizes[i] = v.ItemCount()
+	}
+	return sizes
+}
+
+// Flush deletes all items from the cache, one shard at a time. See
+// Cache.Flush.
 func (sc *shardedCache[K, V]) Flush() {
+	sc.mu.RLock()
+	defer sc.unlock()
 	for _, v := range sc.cs {
 		v.Flush()
 	}
 }
 
+// FlushWith deletes all items from the cache, one shard at a time, and calls f
+// with each of them. See Cache.FlushWith.
+func (sc *shardedCache[K, V]) FlushWith(f func(K, V)) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.FlushWith(f)
+	}
+}
+
+// FlushExpirable deletes the items of the cache that are subject to
+// expiration or eviction, one shard at a time, and returns how many it
+// deleted. See Cache.FlushExpirable.
+func (sc *shardedCache[K, V]) FlushExpirable() int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	n := 0
+	for _, v := range sc.cs {
+		n += v.FlushExpirable()
+	}
+	return n
+}
+
 type shardedJanitor[K comparable, V any] struct {
 	Interval time.Duration
+	ticker   Ticker
 	stop     chan bool
+	done     chan struct{} // closed when Run returns
+	once     sync.Once
 }
 
 func (j *shardedJanitor[K, V]) Run(sc *shardedCache[K, V]) {
-	j.stop = make(chan bool)
-	tick := time.Tick(j.Interval)
+	defer close(j.done)
+	defer j.ticker.Stop()
+	sc.mu.RLock()
+	onStart := sc.cs[0].cfg.cleanupOnStart
+	sc.mu.RUnlock()
+	if onStart {
+		sc.sweep()
+	}
 	for {
 		select {
-		case <-tick:
-			sc.DeleteExpired()
+		case <-j.ticker.C():
+			sc.tick()
 		case <-j.stop:
 			return
 		}
 	}
 }
 
-func stopShardedJanitor[K comparable, V any](sc *unexportedShardedCache[K, V]) {
-	sc.janitor.stop <- true
+// close signals the janitor to stop, and waits until it has. It may be called
+// more than once.
+func (j *shardedJanitor[K, V]) close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
 }
 
-func runShardedJanitor[K comparable, V any](sc *shardedCache[K, V], ci time.Duration) {
+func stopShardedJanitor[K comparable, V any](sc *ShardedCache[K, V]) {
+	sc.Close()
+}
+
+// runShardedJanitor starts a janitor with the cleanup interval ci, ticking
+// every tick; see janitorTick.
+func runShardedJanitor[K comparable, V any](sc *shardedCache[K, V], ci, tick time.Duration) {
 	j := &shardedJanitor[K, V]{
 		Interval: ci,
+		ticker:   clockOrReal(sc.clock).NewTicker(tick),
+		stop:     make(chan bool),
+		done:     make(chan struct{}),
 	}
 	sc.janitor = j
 	go j.Run(sc)
 }
 
-func newShardedCache[K comparable, V any](n int, de time.Duration) *shardedCache[K, V] {
+// Close stops the janitor goroutine, if the cache has one, and waits for it to
+// exit, draining the shards first if the cache was created with
+// WithDrainOnClose. See Cache.Close.
+func (sc *shardedCache[K, V]) Close() {
+	sc.closeOnce.Do(func() {
+		sc.mu.RLock()
+		if sc.cs[0].cfg.drain == nil {
+			sc.unlock()
+			return
+		}
+		// The drain function is called once the layout lock is
+		// released, as the callbacks are; see outbox.
+		cs := sc.cs
+		taken := make([]map[K]Item[V], len(cs))
+		for i, c := range cs {
+			taken[i] = c.takeItems()
+		}
+		sc.unlock()
+		for i, c := range cs {
+			c.drainItems(taken[i])
+		}
+	})
+	sc.SetCleanupInterval(0)
+}
+
+// SetCleanupInterval changes the interval at which the janitor deletes expired
+// items from all shards, or stops it if d is less than one. See
+// Cache.SetCleanupInterval.
+func (sc *shardedCache[K, V]) SetCleanupInterval(d time.Duration) {
+	// The layout lock is taken before janitorMu elsewhere, e.g. by Clone,
+	// so it mustn't be taken while holding janitorMu.
+	tick := sc.janitorTick(d)
+	sc.janitorMu.Lock()
+	defer sc.janitorMu.Unlock()
+	if sc.janitor != nil {
+		sc.janitor.close()
+		sc.janitor = nil
+	}
+	if d > 0 {
+		runShardedJanitor(sc, d, tick)
+	}
+}
+
+func newShardedCache[K comparable, V any](n int, de time.Duration, cfg config[K, V]) *shardedCache[K, V] {
+	if cfg.seed != nil {
+		return newShardedCacheWithSeed(n, de, cfg, *cfg.seed)
+	}
 	max := big.NewInt(0).SetUint64(uint64(math.MaxUint32))
 	rnd, err := rand.Int(rand.Reader, max)
 	var seed uint32
 	if err != nil {
-		os.Stderr.Write([]byte("WARNING: go-cache's newShardedCache failed to read from the system CSPRNG (/dev/urandom or equivalent.) Your system's security may be compromised. Continuing with an insecure seed.\n"))
+		cfg.log().Warn("ttlcache: failed to read from the system CSPRNG (/dev/urandom or equivalent); your system's security may be compromised. Continuing with an insecure shard seed.", "error", err)
 		seed = insecurerand.Uint32()
 	} else {
 		seed = uint32(rnd.Uint64())
 	}
+	return newShardedCacheWithSeed(n, de, cfg, seed)
+}
+
+func newShardedCacheWithSeed[K comparable, V any](n int, de time.Duration, cfg config[K, V], seed uint32) *shardedCache[K, V] {
 	sc := &shardedCache[K, V]{
-		seed: seed,
-		m:    uint32(n),
-		cs:   make([]*cache[K, V], n),
+		seed:  seed,
+		hash:  newHasher(seed, cfg.hashFunc),
+		m:     uint32(n),
+		mask:  uint32(n) - 1,
+		pow2:  n&(n-1) == 0,
+		cs:    make([]*cache[K, V], n),
+		clock: cfg.clock,
+		subs:  newSubscribers[K, V](),
+	}
+	if cfg.costFunc != nil {
+		sc.maxTotalCost = cfg.maxTotalCost
 	}
 	for i := 0; i < n; i++ {
-		c := &cache[K, V]{
-			defaultExpiration: de,
-			items:             map[K]Item[V]{},
-		}
-		sc.cs[i] = c
+		sc.cs[i] = sc.newShard(de, map[K]Item[V]{}, cfg)
 	}
 	return sc
 }
 
-func unexportedNewSharded[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, shards int) *unexportedShardedCache[K, V] {
-	if defaultExpiration == 0 {
-		defaultExpiration = -1
+// newShard returns a new cache to be used as a shard of sc, holding the items
+// of m.
+func (sc *shardedCache[K, V]) newShard(de time.Duration, m map[K]Item[V], cfg config[K, V]) *cache[K, V] {
+	c := newCache[K, V](de, m, cfg, &sc.totalCost)
+	c.outbox = &sc.outbox
+	c.tags = &sc.tags
+	return c
+}
+
+// NewSharded returns a new sharded cache with the given number of shards, a
+// default expiration duration and cleanup interval. The expiration and cleanup
+// semantics are the same as for New, and opts are applied to every shard. If
+// shards is less than one, the cache has a single shard; see
+// NewShardedChecked for a constructor that reports this as an error instead.
+func NewSharded[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, shards int, opts ...Option[K, V]) *ShardedCache[K, V] {
+	if shards < 1 {
+		shards = 1
 	}
-	sc := newShardedCache[K, V](shards, defaultExpiration)
-	SC := &unexportedShardedCache[K, V]{sc}
-	if cleanupInterval > 0 {
-		runShardedJanitor(sc, cleanupInterval)
+	sc := newShardedCache[K, V](shards, defaultExpiration, newConfig(opts))
+	return newShardedCacheWithJanitor(sc, cleanupInterval)
+}
+
+func newShardedCacheWithJanitor[K comparable, V any](sc *shardedCache[K, V], ci time.Duration) *ShardedCache[K, V] {
+	SC := &ShardedCache[K, V]{sc}
+	if ci > 0 {
+		runShardedJanitor(sc, ci, sc.janitorTick(ci))
+	}
+	if !sc.cs[0].cfg.noFinalizer {
 		runtime.SetFinalizer(SC, stopShardedJanitor[K, V])
 	}
 	return SC
 }
+
+// maxAutoShards bounds the number of shards chosen by NewShardedAuto.
+const maxAutoShards = 256
+
+// NewShardedAuto is like NewSharded, but chooses the number of shards itself:
+// the smallest power of two at or above runtime.GOMAXPROCS(0), up to a maximum
+// of 256 shards.
+func NewShardedAuto[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, opts ...Option[K, V]) *ShardedCache[K, V] {
+	return NewSharded[K, V](defaultExpiration, cleanupInterval, autoShardCount(runtime.GOMAXPROCS(0)), opts...)
+}
+
+// autoShardCount returns the smallest power of two at or above n, bounded to
+// maxAutoShards.
+func autoShardCount(n int) int {
+	shards := 1
+	for shards < n && shards < maxAutoShards {
+		shards <<= 1
+	}
+	return shards
+}
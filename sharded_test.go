@@ -1,7 +1,14 @@
 package ttlcache
 
 import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log/slog"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -27,12 +34,310 @@ var shardedKeys = []string{
 }
 
 func TestShardedCache(t *testing.T) {
-	tc := unexportedNewSharded[string, interface{}](DefaultExpiration, 0, 13)
+	tc := NewSharded[string, interface{}](DefaultExpiration, 0, 13)
 	for _, v := range shardedKeys {
 		tc.Set(v, "value", DefaultExpiration)
 	}
 }
 
+func TestShardedCacheGet(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	for i, v := range shardedKeys {
+		x, found := tc.Get(v)
+		if !found {
+			t.Errorf("%s was not found", v)
+		}
+		if x != i {
+			t.Errorf("%s is not %d: %d", v, i, x)
+		}
+	}
+	x, found := tc.Get("missing")
+	if found || x != 0 {
+		t.Error("Getting missing found value that shouldn't exist:", x)
+	}
+}
+
+func TestShardedCacheMethods(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	if err := tc.Add("foo", 1, DefaultExpiration); err != nil {
+		t.Error("Couldn't add foo even though it shouldn't exist")
+	}
+	if err := tc.Add("foo", 2, DefaultExpiration); !errors.Is(err, ErrKeyExists) {
+		t.Error("Adding another foo didn't return ErrKeyExists:", err)
+	}
+	if tc.SetIfAbsent("foo", 2, DefaultExpiration) {
+		t.Error("Set another foo when it already existed")
+	}
+	if !tc.SetIfAbsent("qux", 2, DefaultExpiration) {
+		t.Error("Couldn't set qux even though it shouldn't exist")
+	}
+	tc.Delete("qux")
+	if err := tc.Replace("bar", 1, DefaultExpiration); !errors.Is(err, ErrKeyNotFound) {
+		t.Error("Replacing bar when it shouldn't exist didn't return ErrKeyNotFound:", err)
+	}
+	if err := tc.Replace("foo", 3, DefaultExpiration); err != nil {
+		t.Error("Couldn't replace existing key foo")
+	}
+	tc.SetDefault("bar", 4)
+	tc.Set("baz", 5, DefaultExpiration)
+	if n := tc.ItemCount(); n != 3 {
+		t.Errorf("Item count is not 3: %d", n)
+	}
+	tc.Delete("foo")
+	if _, found := tc.Get("foo"); found {
+		t.Error("foo was found, but it should have been deleted")
+	}
+	tc.Flush()
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count is not 0 after flushing: %d", n)
+	}
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	n := 0
+	tc.FlushWith(func(k string, v int) {
+		n++
+	})
+	if n != len(shardedKeys) {
+		t.Errorf("FlushWith called f %d times instead of %d", n, len(shardedKeys))
+	}
+	for i, v := range shardedKeys {
+		tc.Set(v, i, time.Hour)
+	}
+	tc.Set("permanent", 0, NoExpiration)
+	if n := tc.FlushExpirable(); n != len(shardedKeys) {
+		t.Errorf("FlushExpirable deleted %d items instead of %d", n, len(shardedKeys))
+	}
+	if _, found := tc.Get("permanent"); !found || tc.ItemCount() != 1 {
+		t.Error("FlushExpirable didn't keep only the item without expiration")
+	}
+}
+
+func TestShardedCacheGetWithExpiration(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	tc.Set("a", 1, NoExpiration)
+	tc.Set("b", 2, 50*time.Millisecond)
+	tc.Set("c", 3, time.Nanosecond)
+
+	a, expiration, found := tc.GetWithExpiration("a")
+	if !found || a != 1 {
+		t.Error("a was not found or is not 1:", a)
+	}
+	if !expiration.IsZero() {
+		t.Error("expiration for a is not a zeroed time")
+	}
+
+	b, expiration, found := tc.GetWithExpiration("b")
+	if !found || b != 2 {
+		t.Error("b was not found or is not 2:", b)
+	}
+	if expiration.UnixNano() != tc.bucket("b").items["b"].Expiration {
+		t.Error("expiration for b is not the correct time")
+	}
+
+	<-time.After(time.Millisecond)
+	c, expiration, found := tc.GetWithExpiration("c")
+	if found || c != 0 || !expiration.IsZero() {
+		t.Error("Getting c found value that should have expired:", c)
+	}
+}
+
+func TestShardedCacheMaxItems(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4, WithMaxItems[string, int](2))
+	for i := 0; i < 100; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	for i, v := range tc.cs {
+		if n := v.ItemCount(); n > 2 {
+			t.Errorf("Shard %d holds %d items, more than its cap of 2", i, n)
+		}
+	}
+}
+
+func TestAutoShardCount(t *testing.T) {
+	for n, want := range map[int]int{
+		0:    1,
+		1:    1,
+		2:    2,
+		3:    4,
+		8:    8,
+		9:    16,
+		1000: maxAutoShards,
+	} {
+		if got := autoShardCount(n); got != want {
+			t.Errorf("autoShardCount(%d) is %d, not %d", n, got, want)
+		}
+	}
+}
+
+func TestNewShardedAuto(t *testing.T) {
+	tc := NewShardedAuto[string, int](DefaultExpiration, 0)
+	if n := len(tc.cs); n&(n-1) != 0 || n < runtime.GOMAXPROCS(0) && n < maxAutoShards {
+		t.Errorf("Unexpected shard count %d for GOMAXPROCS %d", n, runtime.GOMAXPROCS(0))
+	}
+	if !tc.pow2 {
+		t.Error("Shard count chosen by NewShardedAuto is not treated as a power of two")
+	}
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	for i, v := range shardedKeys {
+		if x, found := tc.Get(v); !found || x != i {
+			t.Errorf("%s was not found", v)
+		}
+	}
+}
+
+func TestShardedCacheRange(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	seen := map[string]int{}
+	tc.Range(func(k string, v int) bool {
+		seen[k] = v
+		return true
+	})
+	if len(seen) != len(shardedKeys) {
+		t.Errorf("Range visited %d items instead of %d", len(seen), len(shardedKeys))
+	}
+	for i, v := range shardedKeys {
+		if seen[v] != i {
+			t.Errorf("%s was visited with %d instead of %d", v, seen[v], i)
+		}
+	}
+
+	n := 0
+	tc.Range(func(k string, v int) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Error("Range did not stop when f returned false; calls:", n)
+	}
+}
+
+func TestShardedCacheAllItems(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	tc.Set("expired", -1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+
+	m := tc.AllItems()
+	if len(m) != len(shardedKeys) {
+		t.Errorf("AllItems returned %d items instead of %d", len(m), len(shardedKeys))
+	}
+	for i, v := range shardedKeys {
+		if item, found := m[v]; !found || item.Object != i {
+			t.Errorf("%s is missing or wrong in AllItems: %v", v, item)
+		}
+	}
+	if _, found := m["expired"]; found {
+		t.Error("AllItems returned an expired item")
+	}
+}
+
+func TestShardedCacheItemCount(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, v := range shardedKeys {
+		tc.Set(v, i, DefaultExpiration)
+	}
+	tc.Set("expired", -1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if n := tc.ItemCount(); n != len(shardedKeys) {
+		t.Errorf("Item count is not %d: %d", len(shardedKeys), n)
+	}
+	if n := tc.ItemCountIncludingExpired(); n != len(shardedKeys)+1 {
+		t.Errorf("Item count including expired items is not %d: %d", len(shardedKeys)+1, n)
+	}
+}
+
+func TestShardedCacheDeleteExpiredKeys(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 13)
+	for i, v := range shardedKeys {
+		tc.Set(v, i, time.Nanosecond)
+	}
+	tc.Set("live", -1, DefaultExpiration)
+	<-time.After(time.Millisecond)
+	keys := tc.DeleteExpiredKeys()
+	if len(keys) != len(shardedKeys) {
+		t.Errorf("%d keys were deleted instead of %d", len(keys), len(shardedKeys))
+	}
+	for _, k := range keys {
+		if k == "live" {
+			t.Error("An unexpired key was deleted")
+		}
+	}
+}
+
+func TestShardSizes(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 8, WithHashFunc[int, int](IntegerHash[int]))
+	if n := tc.ShardCount(); n != 8 {
+		t.Errorf("Shard count is not 8: %d", n)
+	}
+	for i := 0; i < 1000; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.Set(-1, -1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	sizes := tc.ShardSizes()
+	if len(sizes) != 8 {
+		t.Fatalf("ShardSizes returned %d sizes instead of 8", len(sizes))
+	}
+	total := 0
+	for i, n := range sizes {
+		if n != tc.cs[i].ItemCount() {
+			t.Errorf("Size of shard %d is %d, not %d", i, n, tc.cs[i].ItemCount())
+		}
+		if n == 0 {
+			t.Errorf("Shard %d is empty", i)
+		}
+		total += n
+	}
+	if total != 1000 {
+		t.Errorf("The shard sizes add up to %d instead of 1000", total)
+	}
+}
+
+func TestSeedAndBucketIndex(t *testing.T) {
+	a := NewSharded[string, int](DefaultExpiration, 0, 8)
+	b := NewSharded[string, int](DefaultExpiration, 0, 8, WithSeed[string, int](a.Seed()))
+	if a.Seed() != b.Seed() {
+		t.Fatalf("The seed %d wasn't reused: %d", a.Seed(), b.Seed())
+	}
+	for i, k := range shardedKeys {
+		ia, ib := a.BucketIndex(k), b.BucketIndex(k)
+		if ia != ib {
+			t.Errorf("%s is in shard %d of one cache but %d of the other", k, ia, ib)
+		}
+		if ia < 0 || ia >= 8 {
+			t.Errorf("Shard index %d of %s is out of range", ia, k)
+		}
+		a.Set(k, i, DefaultExpiration)
+		if _, found := a.cs[ia].Get(k); !found {
+			t.Errorf("%s isn't stored in shard %d", k, ia)
+		}
+	}
+}
+
+func TestShardedCacheClose(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, time.Millisecond, 4)
+	j := tc.janitor
+	tc.Close()
+	tc.Close()
+	select {
+	case <-j.done:
+	default:
+		t.Error("The janitor is still running after Close")
+	}
+	NewSharded[string, int](DefaultExpiration, 0, 4).Close()
+}
+
 func BenchmarkShardedCacheGetExpiring(b *testing.B) {
 	benchmarkShardedCacheGet(b, 5*time.Minute)
 }
@@ -43,7 +348,7 @@ func BenchmarkShardedCacheGetNotExpiring(b *testing.B) {
 
 func benchmarkShardedCacheGet(b *testing.B, exp time.Duration) {
 	b.StopTimer()
-	tc := unexportedNewSharded[string, interface{}](exp, 0, 10)
+	tc := NewSharded[string, interface{}](exp, 0, 10)
 	tc.Set("foobarba", "zquux", DefaultExpiration)
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
@@ -62,7 +367,7 @@ func BenchmarkShardedCacheGetManyConcurrentNotExpiring(b *testing.B) {
 func benchmarkShardedCacheGetManyConcurrent(b *testing.B, exp time.Duration) {
 	b.StopTimer()
 	n := 10000
-	tsc := unexportedNewSharded[string, interface{}](exp, 0, 20)
+	tsc := NewSharded[string, interface{}](exp, 0, 20)
 	keys := make([]string, n)
 	for i := 0; i < n; i++ {
 		k := "foo" + strconv.Itoa(i)
@@ -83,3 +388,46 @@ func benchmarkShardedCacheGetManyConcurrent(b *testing.B, exp time.Duration) {
 	b.StartTimer()
 	wg.Wait()
 }
+
+func TestShardedItemsRef(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	refs := tc.ItemsRef()
+	tc.Set("a", 1, DefaultExpiration)
+	n := 0
+	for _, m := range refs {
+		n += len(m)
+	}
+	if n != 1 || len(refs) != 4 {
+		t.Errorf("ItemsRef returned %d maps with %d items in all", len(refs), n)
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	a := NewSharded[string, int](DefaultExpiration, 0, 16, WithSeed[string, int](42))
+	b := NewSharded[string, int](DefaultExpiration, 0, 16, WithSeed[string, int](42))
+	if a.seed != 42 {
+		t.Error("The seed is not 42:", a.seed)
+	}
+	for _, k := range shardedKeys {
+		if a.index(k) != b.index(k) {
+			t.Errorf("%s was placed in shards %d and %d with the same seed", k, a.index(k), b.index(k))
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("no entropy")
+}
+
+func TestSeedFailureLogged(t *testing.T) {
+	defer func(r io.Reader) { rand.Reader = r }(rand.Reader)
+	rand.Reader = failingReader{}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	NewSharded[string, int](DefaultExpiration, 0, 4, WithLogger[string, int](logger))
+	if !strings.Contains(buf.String(), "no entropy") {
+		t.Errorf("The seed failure wasn't logged: %q", buf.String())
+	}
+}
@@ -0,0 +1,41 @@
+package ttlcache
+
+import "unsafe"
+
+// ApproxSize returns a rough estimate, in bytes, of the memory used by the
+// cache's map of items, e.g. for a cheap alert on the cache growing too big
+// when a cost function (see WithCost) would be overkill. It is computed from
+// the fixed sizes of K and Item[V], as given by unsafe.Sizeof, and an
+// allowance for the map's own overhead, so it ignores everything the keys and
+// values point to, such as the bytes of strings and the elements of slices, as
+// well as the bookkeeping of eviction policies and cost functions. Since Go
+// maps never shrink, the estimate is based on the most items the cache has
+// held since its map was last made, expired ones included; see Compact.
+func (c *cache[K, V]) ApproxSize() int64 {
+	c.mu.RLock()
+	n := max(c.peak, len(c.items))
+	c.mu.RUnlock()
+	return approxMapSize[K, Item[V]](n)
+}
+
+// ApproxSize returns a rough estimate, in bytes, of the memory used by the
+// maps of all the shards. See Cache.ApproxSize.
+func (sc *shardedCache[K, V]) ApproxSize() int64 {
+	sc.mu.RLock()
+	defer sc.unlock()
+	var size int64
+	for _, c := range sc.cs {
+		size += c.ApproxSize()
+	}
+	return size
+}
+
+// approxMapSize estimates the memory used by a map[K]T holding n entries:
+// each slot holds a key, a value and a control byte, and the map keeps about
+// one slot in eight free.
+func approxMapSize[K comparable, T any](n int) int64 {
+	var k K
+	var v T
+	slot := int64(unsafe.Sizeof(k)) + int64(unsafe.Sizeof(v)) + 1
+	return int64(n) * slot * 8 / 7
+}
@@ -0,0 +1,40 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"unsafe"
+)
+
+func TestApproxSize(t *testing.T) {
+	tc := New[int, int64](DefaultExpiration, 0)
+	if n := tc.ApproxSize(); n != 0 {
+		t.Error("An empty cache has a size of", n)
+	}
+	for i := 0; i < 700; i++ {
+		tc.Set(i, int64(i), DefaultExpiration)
+	}
+	per := int64(unsafe.Sizeof(0)) + int64(unsafe.Sizeof(Item[int64]{})) + 1
+	if n, want := tc.ApproxSize(), 700*per*8/7; n != want {
+		t.Errorf("ApproxSize is %d instead of %d", n, want)
+	}
+	tc.Delete(0)
+	if n, want := tc.ApproxSize(), 700*per*8/7; n != want {
+		t.Errorf("ApproxSize shrank to %d before Compact", n)
+	}
+	tc.Compact()
+	if n, want := tc.ApproxSize(), 699*per*8/7; n != want {
+		t.Errorf("ApproxSize is %d instead of %d after Compact", n, want)
+	}
+}
+
+func TestShardedApproxSize(t *testing.T) {
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	for i := 0; i < 100; i++ {
+		sc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	// Rounding in each shard can make the total slightly smaller.
+	if n, want := sc.ApproxSize(), approxMapSize[string, Item[int]](100); n > want || n < want-4 {
+		t.Errorf("ApproxSize is %d instead of about %d", n, want)
+	}
+}
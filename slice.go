@@ -0,0 +1,30 @@
+package ttlcache
+
+import (
+	"fmt"
+)
+
+// Append atomically appends items to the slice stored at k, creating the item
+// with the default expiration if it doesn't exist or has expired. c may be a
+// *Cache or a *ShardedCache whose values are []E, or an interface type holding
+// []E values. An existing item keeps its expiration time.
+//
+// Append returns an error, and leaves the cache unchanged, if the value stored
+// at k is not a []E, or if a []E can't be stored in the cache.
+func Append[K comparable, V any, E any](c modifier[K, V], k K, items ...E) error {
+	_, err := c.modify(k, func(v V, found bool) (V, error) {
+		var s []E
+		if found {
+			var ok bool
+			if s, ok = any(v).([]E); !ok {
+				return v, fmt.Errorf("item %v is a %T, not a %T", k, v, s)
+			}
+		}
+		nv, ok := any(append(s, items...)).(V)
+		if !ok {
+			return v, fmt.Errorf("%T can't be stored in a cache of %T", s, v)
+		}
+		return nv, nil
+	})
+	return err
+}
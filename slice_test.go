@@ -0,0 +1,68 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppend(t *testing.T) {
+	tc := New[string, []string](50*time.Millisecond, 0)
+	if err := Append(tc, "foo", "a"); err != nil {
+		t.Fatal("Couldn't append to missing foo:", err)
+	}
+	_, before, _ := tc.GetWithExpiration("foo")
+	if before.IsZero() {
+		t.Error("foo was not created with the default expiration")
+	}
+	if err := Append(tc, "foo", "b", "c"); err != nil {
+		t.Fatal("Couldn't append to foo:", err)
+	}
+	x, after, found := tc.GetWithExpiration("foo")
+	if !found {
+		t.Fatal("foo was not found")
+	}
+	if len(x) != 3 || x[0] != "a" || x[1] != "b" || x[2] != "c" {
+		t.Error("foo is not [a b c]:", x)
+	}
+	if !before.Equal(after) {
+		t.Error("Append changed the expiration time of foo")
+	}
+}
+
+func TestAppendWrongType(t *testing.T) {
+	tc := New[string, interface{}](DefaultExpiration, 0)
+	tc.Set("foo", 1, DefaultExpiration)
+	if err := Append(tc, "foo", "a"); err == nil {
+		t.Error("Appended to an int without an error")
+	}
+	if x, _ := tc.Get("foo"); x != 1 {
+		t.Error("A failed Append changed foo:", x)
+	}
+	if err := Append(tc, "bar", "a"); err != nil {
+		t.Error("Couldn't append to missing bar:", err)
+	}
+	if err := Append(tc, "bar", "b"); err != nil {
+		t.Error("Couldn't append to bar:", err)
+	}
+	if x, _ := tc.Get("bar"); len(x.([]string)) != 2 {
+		t.Error("bar is not [a b]:", x)
+	}
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	tc := NewSharded[string, []byte](DefaultExpiration, 0, 4)
+	n := 100
+	wg := new(sync.WaitGroup)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			Append(tc, "foo", byte('x'))
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("foo"); len(x) != n {
+		t.Errorf("foo holds %d bytes after %d concurrent appends", len(x), n)
+	}
+}
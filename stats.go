@@ -0,0 +1,120 @@
+package ttlcache
+
+import (
+	"sync/atomic"
+)
+
+// stats holds a cache's counters. They are updated atomically, outside of the
+// cache's lock where possible, so they add no lock contention.
+type stats struct {
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	insertions atomic.Uint64
+	evictions  [numEvictionReasons]atomic.Uint64
+}
+
+func (s *stats) evicted(reason EvictionReason, n uint64) {
+	if n > 0 {
+		s.evictions[reason].Add(n)
+	}
+}
+
+func (s *stats) snapshot() Stats {
+	st := Stats{
+		Hits:       s.hits.Load(),
+		Misses:     s.misses.Load(),
+		Insertions: s.insertions.Load(),
+		Evictions:  make(map[EvictionReason]uint64),
+	}
+	for r := range s.evictions {
+		if n := s.evictions[r].Load(); n > 0 {
+			st.Evictions[EvictionReason(r)] = n
+		}
+	}
+	return st
+}
+
+// merge adds the counters of o to s.
+func (s *stats) merge(o *stats) {
+	s.hits.Add(o.hits.Load())
+	s.misses.Add(o.misses.Load())
+	s.insertions.Add(o.insertions.Load())
+	for r := range o.evictions {
+		s.evictions[r].Add(o.evictions[r].Load())
+	}
+}
+
+func (s *stats) reset() {
+	s.hits.Store(0)
+	s.misses.Store(0)
+	s.insertions.Store(0)
+	for r := range s.evictions {
+		s.evictions[r].Store(0)
+	}
+}
+
+// Stats is a snapshot of a cache's counters.
+type Stats struct {
+	// Hits is the number of lookups that found an unexpired item.
+	Hits uint64
+
+	// Misses is the number of lookups that didn't find an unexpired item.
+	Misses uint64
+
+	// Insertions is the number of values stored in the cache.
+	Insertions uint64
+
+	// Evictions is the number of items removed from the cache, by reason.
+	Evictions map[EvictionReason]uint64
+}
+
+// TotalEvictions returns the number of items removed for any reason.
+func (s Stats) TotalEvictions() uint64 {
+	var n uint64
+	for _, v := range s.Evictions {
+		n += v
+	}
+	return n
+}
+
+// add adds the counters of o to s.
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Insertions += o.Insertions
+	for r, n := range o.Evictions {
+		s.Evictions[r] += n
+	}
+}
+
+// Stats returns a snapshot of the cache's counters. The counters are read
+// individually, so a snapshot taken while the cache is in use may not be
+// perfectly consistent.
+func (c *cache[K, V]) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ResetStats sets all of the cache's counters to zero.
+func (c *cache[K, V]) ResetStats() {
+	c.stats.reset()
+}
+
+// Stats returns a snapshot of the counters of all shards added together.
+func (sc *shardedCache[K, V]) Stats() Stats {
+	sc.mu.RLock()
+	defer sc.unlock()
+	st := Stats{Evictions: make(map[EvictionReason]uint64)}
+	for _, v := range sc.cs {
+		st.add(v.Stats())
+	}
+	return st
+}
+
+// ResetStats sets the counters of all shards to zero.
+func (sc *shardedCache[K, V]) ResetStats() {
+	sc.mu.RLock()
+	defer sc.unlock()
+	for _, v := range sc.cs {
+		v.ResetStats()
+	}
+}
@@ -0,0 +1,71 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0, WithMaxItems[string, int](2))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, time.Nanosecond)
+	tc.Get("a")
+	tc.Get("missing")
+	tc.Replace("a", 10, DefaultExpiration)
+	<-time.After(time.Millisecond)
+	tc.DeleteExpired()
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Set("d", 4, DefaultExpiration)
+	tc.Delete("d")
+
+	st := tc.Stats()
+	if st.Hits != 1 {
+		t.Errorf("Hits is not 1: %d", st.Hits)
+	}
+	if st.Misses != 1 {
+		t.Errorf("Misses is not 1: %d", st.Misses)
+	}
+	if st.Insertions != 5 {
+		t.Errorf("Insertions is not 5: %d", st.Insertions)
+	}
+	want := map[EvictionReason]uint64{
+		ReasonReplaced: 1,
+		ReasonExpired:  1,
+		ReasonCapacity: 1,
+		ReasonDeleted:  1,
+	}
+	for r, n := range want {
+		if st.Evictions[r] != n {
+			t.Errorf("Evictions for %v is not %d: %d", r, n, st.Evictions[r])
+		}
+	}
+	if n := st.TotalEvictions(); n != 4 {
+		t.Errorf("TotalEvictions is not 4: %d", n)
+	}
+
+	tc.ResetStats()
+	st = tc.Stats()
+	if st.Hits != 0 || st.Misses != 0 || st.Insertions != 0 || st.TotalEvictions() != 0 {
+		t.Error("Stats were not reset:", st)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	for i, k := range shardedKeys {
+		tc.Set(k, i, DefaultExpiration)
+		tc.Get(k)
+	}
+	tc.Get("missing")
+	st := tc.Stats()
+	if n := uint64(len(shardedKeys)); st.Hits != n || st.Insertions != n {
+		t.Errorf("Hits and Insertions are not %d: %d, %d", n, st.Hits, st.Insertions)
+	}
+	if st.Misses != 1 {
+		t.Errorf("Misses is not 1: %d", st.Misses)
+	}
+	tc.ResetStats()
+	if st := tc.Stats(); st.Hits != 0 {
+		t.Error("Stats were not reset:", st)
+	}
+}
@@ -0,0 +1,58 @@
+package ttlcache
+
+import (
+	"time"
+)
+
+// Swap sets an item to the cache like Set, replacing any existing item, and
+// returns the value it replaced, if k was found and hadn't expired, from the
+// same critical section: no other write can happen in between.
+func (c *cache[K, V]) Swap(k K, x V, d time.Duration) (old V, had bool) {
+	c.mu.Lock()
+	old, had = c.get(k)
+	c.set(k, x, d)
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+	if had {
+		old = c.decoded(old)
+	}
+	return old, had
+}
+
+// GetAndDelete deletes an item from the cache like Delete, and returns its
+// value, if k was found and hadn't expired, from the same critical section.
+func (c *cache[K, V]) GetAndDelete(k K) (old V, had bool) {
+	c.mu.Lock()
+	old, had = c.get(k)
+	v, deleted := c.delete(k)
+	f := c.onEvicted
+	c.mu.Unlock()
+	if deleted {
+		c.stats.evicted(ReasonDeleted, 1)
+		if f != nil {
+			f(k, v, ReasonDeleted)
+		}
+	}
+	if had {
+		old = c.decoded(old)
+	}
+	return old, had
+}
+
+// Swap sets an item to the cache and returns the value it replaced. Only the
+// shard holding k is locked. See Cache.Swap.
+func (sc *shardedCache[K, V]) Swap(k K, x V, d time.Duration) (old V, had bool) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).Swap(k, x, d)
+}
+
+// GetAndDelete deletes an item from the cache and returns its value. Only the
+// shard holding k is locked. See Cache.GetAndDelete.
+func (sc *shardedCache[K, V]) GetAndDelete(k K) (old V, had bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetAndDelete(k)
+}
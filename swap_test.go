@@ -0,0 +1,59 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwap(t *testing.T) {
+	clock := newFakeClock()
+	for name, tc := range map[string]interface {
+		Swap(string, int, time.Duration) (int, bool)
+		Get(string) (int, bool)
+	}{
+		"standard": New[string, int](time.Minute, 0, WithClock[string, int](clock)),
+		"sharded":  NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock)),
+	} {
+		if old, had := tc.Swap("a", 1, DefaultExpiration); had || old != 0 {
+			t.Errorf("%s: Swap of a missing key returned %d, %v", name, old, had)
+		}
+		if old, had := tc.Swap("a", 2, DefaultExpiration); !had || old != 1 {
+			t.Errorf("%s: Swap returned %d, %v instead of 1, true", name, old, had)
+		}
+		if v, _ := tc.Get("a"); v != 2 {
+			t.Errorf("%s: a is not 2 after Swap: %d", name, v)
+		}
+		tc.Swap("b", 1, time.Second)
+		clock.Advance(2 * time.Second)
+		if old, had := tc.Swap("b", 2, DefaultExpiration); had {
+			t.Errorf("%s: Swap returned the expired value %d", name, old)
+		}
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	var reasons []EvictionReason
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		reasons = append(reasons, reason)
+	})
+	if _, had := tc.GetAndDelete("a"); had {
+		t.Error("GetAndDelete found a missing key")
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	if old, had := tc.GetAndDelete("a"); !had || old != 1 {
+		t.Error("GetAndDelete returned", old, had, "instead of 1, true")
+	}
+	if tc.Has("a") {
+		t.Error("a is still in the cache after GetAndDelete")
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonDeleted {
+		t.Error("The eviction callback wasn't called with ReasonDeleted:", reasons)
+	}
+
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	sc.Set("a", 1, DefaultExpiration)
+	if old, had := sc.GetAndDelete("a"); !had || old != 1 || sc.Has("a") {
+		t.Error("Sharded GetAndDelete returned", old, had)
+	}
+}
@@ -0,0 +1,220 @@
+package ttlcache
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// SetWithTags sets an item to the cache, replacing any existing item, like
+// Set, and tags it with each of tags, so that it can be deleted together with
+// every other item sharing a tag by InvalidateTag, e.g. all the values derived
+// from the same source object. The item keeps its tags until it is removed,
+// including by expiring, or is stored again by any other means than
+// SetWithTags, which lifts them. Tags are copied by Clone.
+func (c *cache[K, V]) SetWithTags(k K, x V, d time.Duration, tags ...string) {
+	c.mu.Lock()
+	c.set(k, x, d)
+	if _, found := c.items[k]; found {
+		c.tag(k, tags)
+	}
+	evicted := c.evictOverflow()
+	f := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(f, evicted, ReasonCapacity)
+}
+
+// InvalidateTag deletes every item tagged with tag by SetWithTags, calling the
+// eviction callback with ReasonDeleted for each, as DeleteMany does, and
+// returns the number of items deleted. Items that have expired but haven't
+// been deleted yet are deleted and counted too.
+func (c *cache[K, V]) InvalidateTag(tag string) int {
+	c.mu.Lock()
+	keys := c.tags.keysOf(tag)
+	evictedItems := c.deleteTagged(keys)
+	f := c.onEvicted
+	c.mu.Unlock()
+	c.stats.evicted(ReasonDeleted, uint64(len(keys)))
+	notifyEvicted(f, evictedItems, ReasonDeleted)
+	return len(keys)
+}
+
+// deleteTagged deletes keys, which must all be in c, and returns the deleted
+// items if there is an eviction callback to pass them to. It must be called
+// with c.mu held.
+func (c *cache[K, V]) deleteTagged(keys []K) []keyAndValue[K, V] {
+	var evictedItems []keyAndValue[K, V]
+	for _, k := range keys {
+		v, _ := c.delete(k)
+		if c.onEvicted != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, v})
+		}
+	}
+	return evictedItems
+}
+
+// tag adds k, which must be in the cache, to the index of each of tags. It
+// must be called with c.mu held.
+func (c *cache[K, V]) tag(k K, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if c.keyTags == nil {
+		c.keyTags = make(map[K][]string)
+	}
+	if c.tags == nil {
+		c.tags = &tagIndex[K]{}
+	}
+	n := len(c.keyTags[k])
+	for _, t := range tags {
+		if !slices.Contains(c.keyTags[k], t) {
+			c.keyTags[k] = append(c.keyTags[k], t)
+		}
+	}
+	c.tags.add(k, c.keyTags[k][n:])
+}
+
+// untag removes k from the index of each of its tags. It must be called with
+// c.mu held.
+func (c *cache[K, V]) untag(k K) {
+	c.tags.remove(k, c.keyTags[k])
+	delete(c.keyTags, k)
+}
+
+// untagAll removes every key of c from the tag index. It must be called with
+// c.mu held.
+func (c *cache[K, V]) untagAll() {
+	for k, tags := range c.keyTags {
+		c.tags.remove(k, tags)
+	}
+	c.keyTags = nil
+}
+
+// tagIndex maps each tag to the keys tagged with it. A standalone cache has
+// one of its own, and the shards of a sharded cache share the one of the
+// sharded cache, so that InvalidateTag finds the items of a tag without
+// visiting every shard. Each cache keeps it in step with its own deletions,
+// evictions and expirations under its own lock, so the index has a lock of
+// its own too, which is always taken last.
+type tagIndex[K comparable] struct {
+	mu   sync.Mutex
+	keys map[string]map[K]struct{}
+}
+
+// add adds k to the keys of each of tags.
+func (x *tagIndex[K]) add(k K, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.keys == nil {
+		x.keys = make(map[string]map[K]struct{})
+	}
+	for _, t := range tags {
+		keys := x.keys[t]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			x.keys[t] = keys
+		}
+		keys[k] = struct{}{}
+	}
+}
+
+// remove removes k from the keys of each of tags.
+func (x *tagIndex[K]) remove(k K, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, t := range tags {
+		keys := x.keys[t]
+		delete(keys, k)
+		if len(keys) == 0 {
+			delete(x.keys, t)
+		}
+	}
+}
+
+// keysOf returns the keys tagged with tag. The index may be nil.
+func (x *tagIndex[K]) keysOf(tag string) []K {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keys := make([]K, 0, len(x.keys[tag]))
+	for k := range x.keys[tag] {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// size returns the number of tags in the index. The index may be nil.
+func (x *tagIndex[K]) size() int {
+	if x == nil {
+		return 0
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.keys)
+}
+
+// SetWithTags sets an item to the cache, tagged with each of tags. See
+// Cache.SetWithTags.
+func (sc *shardedCache[K, V]) SetWithTags(k K, x V, d time.Duration, tags ...string) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).SetWithTags(k, x, d, tags...)
+}
+
+// InvalidateTag deletes every item tagged with tag, and returns the number of
+// items deleted. The tags of all shards are kept in a single index, so only
+// the shards holding items of tag are visited. They are locked together,
+// always in the same order, as by RenameKey, so that the invalidation is
+// atomic: no reader finds an item of tag in one shard after it has been
+// deleted from another. See Cache.InvalidateTag.
+func (sc *shardedCache[K, V]) InvalidateTag(tag string) int {
+	sc.mu.RLock()
+	defer sc.unlock()
+	var shards []uint32
+	for _, k := range sc.tags.keysOf(tag) {
+		shards = append(shards, sc.index(k))
+	}
+	slices.Sort(shards)
+	shards = slices.Compact(shards)
+	for _, i := range shards {
+		sc.cs[i].mu.Lock()
+	}
+	// Items may have been tagged or deleted before their shards were
+	// locked, so the keys are read again. Those tagged since in the other
+	// shards were tagged after the invalidation, and are kept.
+	keys := make(map[uint32][]K, len(shards))
+	for _, k := range sc.tags.keysOf(tag) {
+		if i := sc.index(k); slices.Contains(shards, i) {
+			keys[i] = append(keys[i], k)
+		}
+	}
+	type deleted struct {
+		c       *cache[K, V]
+		n       int
+		f       func(K, V, EvictionReason)
+		evicted []keyAndValue[K, V]
+	}
+	all := make([]deleted, 0, len(shards))
+	n := 0
+	for _, i := range shards {
+		c := sc.cs[i]
+		all = append(all, deleted{c, len(keys[i]), c.onEvicted, c.deleteTagged(keys[i])})
+		n += len(keys[i])
+	}
+	for _, i := range shards {
+		sc.cs[i].mu.Unlock()
+	}
+	for _, d := range all {
+		d.c.stats.evicted(ReasonDeleted, uint64(d.n))
+		notifyEvicted(d.f, d.evicted, ReasonDeleted)
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTags(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	var evicted []string
+	tc.OnEvicted(func(k string, v int, reason EvictionReason) {
+		if reason != ReasonDeleted {
+			t.Errorf("%s was evicted with reason %v instead of %v", k, reason, ReasonDeleted)
+		}
+		evicted = append(evicted, k)
+	})
+	tc.SetWithTags("a", 1, DefaultExpiration, "user:1", "page")
+	tc.SetWithTags("b", 2, DefaultExpiration, "user:1")
+	tc.SetWithTags("c", 3, DefaultExpiration, "user:2", "page", "page")
+	tc.SetWithTags("d", 4, DefaultExpiration, "user:1")
+	tc.Set("d", 5, DefaultExpiration)
+	tc.SetWithTags("e", 6, DefaultExpiration, "user:1")
+	tc.Delete("e")
+	evicted = nil
+
+	if n := tc.InvalidateTag("user:1"); n != 2 {
+		t.Error("InvalidateTag deleted", n, "items instead of 2")
+	}
+	if len(evicted) != 2 {
+		t.Error("Unexpected evictions:", evicted)
+	}
+	for k, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if _, found := tc.Get(k); found != want {
+			t.Errorf("%s found: %v, want %v", k, found, want)
+		}
+	}
+	if n := tc.InvalidateTag("page"); n != 1 {
+		t.Error("InvalidateTag deleted", n, "items instead of 1 after a was deleted")
+	}
+	if n := tc.InvalidateTag("user:1"); n != 0 {
+		t.Error("A second InvalidateTag deleted", n, "items")
+	}
+	if tc.tags.size() != 0 || len(tc.keyTags) != 0 {
+		t.Error("The tag index wasn't emptied:", tc.tags.keys, tc.keyTags)
+	}
+}
+
+func TestShardedTags(t *testing.T) {
+	sc := NewSharded[string, int](time.Millisecond, 0, 4)
+	for i, k := range shardedKeys {
+		d := DefaultExpiration
+		if i%2 == 0 {
+			d = NoExpiration
+		}
+		sc.SetWithTags(k, i, d, "all")
+	}
+	<-time.After(5 * time.Millisecond)
+	sc.DeleteExpired()
+	sc.Reshard(8)
+	want := (len(shardedKeys) + 1) / 2
+	if n := sc.InvalidateTag("all"); n != want {
+		t.Error("InvalidateTag deleted", n, "items instead of", want)
+	}
+	if n := sc.ItemCountIncludingExpired(); n != 0 {
+		t.Error(n, "tagged items are left")
+	}
+}
+
+func TestShardedTagIndex(t *testing.T) {
+	clock := newFakeClock()
+	sc := NewSharded[string, int](DefaultExpiration, 0, 4,
+		WithClock[string, int](clock),
+		WithMaxItems[string, int](2),
+	)
+	for i, k := range shardedKeys {
+		sc.SetWithTags(k, i, time.Minute, "all")
+	}
+	// Every shard holds at most two items, and the others have been evicted.
+	if got, want := len(sc.tags.keysOf("all")), sc.ItemCountIncludingExpired(); got != want {
+		t.Errorf("The tag index holds %d keys instead of %d after evictions", got, want)
+	}
+	keys := sc.Keys()
+	sc.Delete(keys[0])
+	sc.Set(keys[1], 0, NoExpiration)
+	if got, want := len(sc.tags.keysOf("all")), len(keys)-2; got != want {
+		t.Errorf("The tag index holds %d keys instead of %d after a delete and a set", got, want)
+	}
+	clock.Advance(time.Hour)
+	sc.DeleteExpired()
+	if n := sc.tags.size(); n != 0 {
+		t.Error("The tag index holds", n, "tags after every tagged item expired")
+	}
+	if n := sc.InvalidateTag("all"); n != 0 {
+		t.Error("InvalidateTag deleted", n, "items that aren't tagged any more")
+	}
+	if _, found := sc.Get(keys[1]); !found {
+		t.Error("An item that was stored again without tags was invalidated")
+	}
+}
@@ -0,0 +1,59 @@
+package ttlcache
+
+import "time"
+
+// Store is the second tier of a Tiered cache: a larger or shared store, such
+// as a *Cache, a *ShardedCache, or a client for a remote key-value store.
+// Get reports whether k was found; a store that can fail, e.g. over the
+// network, should report a failed lookup as a miss. If the store also has a
+// Delete(k K) method, Tiered.Delete calls it.
+type Store[K comparable, V any] interface {
+	Get(k K) (V, bool)
+	Set(k K, v V, d time.Duration)
+}
+
+// Tiered is a two-tier cache: a small, fast in-process cache (L1) in front of
+// a larger Store (L2). Lookups that miss L1 consult L2 and copy what they find
+// into L1, and writes go to both tiers.
+type Tiered[K comparable, V any] struct {
+	l1 *Cache[K, V]
+	l2 Store[K, V]
+}
+
+// NewTiered returns a Tiered cache with l1 in front of l2. Values found in l2
+// are stored in l1 with l1's default expiration, which is typically shorter
+// than the durations values are stored in l2 for. l1 may still be used
+// directly, e.g. to watch its Stats.
+func NewTiered[K comparable, V any](l1 *Cache[K, V], l2 Store[K, V]) *Tiered[K, V] {
+	return &Tiered[K, V]{l1: l1, l2: l2}
+}
+
+// Get returns the value for k from l1, or else from l2, in which case it is
+// also stored in l1.
+func (t *Tiered[K, V]) Get(k K) (V, bool) {
+	if v, found := t.l1.Get(k); found {
+		return v, true
+	}
+	v, found := t.l2.Get(k)
+	if found {
+		t.l1.SetDefault(k, v)
+	}
+	return v, found
+}
+
+// Set stores v at k in l2 and then in l1, both with the duration d, which each
+// tier interprets as its Set does.
+func (t *Tiered[K, V]) Set(k K, v V, d time.Duration) {
+	t.l2.Set(k, v, d)
+	t.l1.Set(k, v, d)
+}
+
+// Delete removes k from l2, if it has a Delete method, and from l1. Otherwise
+// k is only removed from l1, and may be copied back into it from l2 by a later
+// Get.
+func (t *Tiered[K, V]) Delete(k K) {
+	if d, ok := t.l2.(interface{ Delete(K) }); ok {
+		d.Delete(k)
+	}
+	t.l1.Delete(k)
+}
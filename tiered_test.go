@@ -0,0 +1,60 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+// mapStore is a Store without a Delete method.
+type mapStore map[string]int
+
+func (m mapStore) Get(k string) (int, bool) {
+	v, found := m[k]
+	return v, found
+}
+
+func (m mapStore) Set(k string, v int, d time.Duration) {
+	m[k] = v
+}
+
+func TestTiered(t *testing.T) {
+	l1 := New[string, int](time.Minute, 0)
+	l2 := NewSharded[string, int](time.Hour, 0, 4)
+	tc := NewTiered[string, int](l1, l2)
+
+	l2.Set("a", 1, DefaultExpiration)
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Fatal("a wasn't read through from L2:", x, found)
+	}
+	if _, e, found := l1.GetWithExpiration("a"); !found || time.Until(e) > time.Minute {
+		t.Error("a wasn't backfilled into L1 with its default expiration:", e, found)
+	}
+	if _, found := tc.Get("missing"); found {
+		t.Error("A key in neither tier was found")
+	}
+
+	tc.Set("b", 2, NoExpiration)
+	if _, found := l1.Get("b"); !found {
+		t.Error("b wasn't written to L1")
+	}
+	if _, found := l2.Get("b"); !found {
+		t.Error("b wasn't written to L2")
+	}
+	tc.Delete("b")
+	if _, found := tc.Get("b"); found {
+		t.Error("b was found after it was deleted from both tiers")
+	}
+}
+
+func TestTieredStoreWithoutDelete(t *testing.T) {
+	l2 := mapStore{}
+	tc := NewTiered[string, int](New[string, int](time.Minute, 0), l2)
+	tc.Set("a", 1, DefaultExpiration)
+	if l2["a"] != 1 {
+		t.Error("a wasn't written to L2")
+	}
+	tc.Delete("a")
+	if _, found := tc.Get("a"); !found {
+		t.Error("a wasn't read back from an L2 it can't be deleted from")
+	}
+}
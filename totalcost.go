@@ -0,0 +1,70 @@
+package ttlcache
+
+import (
+	"cmp"
+	"slices"
+)
+
+// TotalCost returns the summed cost of the items of all shards, as computed by
+// the cost function set with WithCost, or 0 without one. Unlike Cost, it reads
+// a counter shared by the shards and doesn't lock any of them.
+func (sc *shardedCache[K, V]) TotalCost() int64 {
+	return sc.totalCost.Load()
+}
+
+// unlockAfterWrite releases the read lock on sc.mu taken by an operation that
+// may have stored items, as unlock does, after evicting items if the operation
+// took the cache over WithMaxTotalCost.
+func (sc *shardedCache[K, V]) unlockAfterWrite() {
+	if sc.maxTotalCost > 0 && sc.totalCost.Load() > sc.maxTotalCost {
+		sc.trimTotalCost()
+	}
+	sc.unlock()
+}
+
+// overTotalCost reports whether the cache is over WithMaxTotalCost.
+func (sc *shardedCache[K, V]) overTotalCost() bool {
+	return sc.totalCost.Load() > sc.maxTotalCost
+}
+
+// trimTotalCost evicts items from the shards with the highest cost until the
+// total cost is back within WithMaxTotalCost. A shard with nothing left to
+// evict, e.g. because its items are pinned, is passed over for the one with
+// the next highest cost. It must be called with sc.mu held for reading, and no
+// shard locks held: it only ever locks one shard at a time.
+func (sc *shardedCache[K, V]) trimTotalCost() {
+	for sc.overTotalCost() {
+		costs := make([]int64, len(sc.cs))
+		order := make([]int, len(sc.cs))
+		for i, c := range sc.cs {
+			c.mu.RLock()
+			costs[i] = c.cost
+			c.mu.RUnlock()
+			order[i] = i
+		}
+		slices.SortFunc(order, func(a, b int) int {
+			return cmp.Compare(costs[b], costs[a])
+		})
+		evictedAny := false
+		for _, i := range order {
+			if costs[i] <= 0 || !sc.overTotalCost() {
+				break
+			}
+			c := sc.cs[i]
+			c.mu.Lock()
+			evicted, n := c.evictWhile(nil, sc.overTotalCost)
+			f := c.onEvicted
+			c.mu.Unlock()
+			notifyEvicted(f, evicted, ReasonCapacity)
+			if n > 0 {
+				evictedAny = true
+				break
+			}
+		}
+		if !evictedAny {
+			// Nothing was left to evict in any shard, e.g. because
+			// a concurrent trim got there first.
+			return
+		}
+	}
+}
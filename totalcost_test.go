@@ -0,0 +1,120 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMaxTotalCost(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 4,
+		WithCost[int, int](func(v int) int64 { return int64(v) }, 0),
+		WithMaxTotalCost[int, int](100),
+		WithHashFunc[int, int](func(k int) uint32 { return uint32(k) }))
+	var evicted []int
+	tc.OnEvicted(func(k, v int, reason EvictionReason) {
+		if reason != ReasonCapacity {
+			t.Error("Evicted with reason", reason)
+		}
+		evicted = append(evicted, k)
+	})
+	// Shard 0 gets a cost of 60, the others 10 each.
+	tc.Set(0, 20, DefaultExpiration)
+	tc.Set(4, 20, DefaultExpiration)
+	tc.Set(8, 20, DefaultExpiration)
+	tc.Set(1, 10, DefaultExpiration)
+	tc.Set(2, 10, DefaultExpiration)
+	tc.Set(3, 10, DefaultExpiration)
+	if got := tc.TotalCost(); got != 90 || len(evicted) != 0 {
+		t.Fatalf("Total cost is %d with %v evicted, want 90 and nothing", got, evicted)
+	}
+
+	// Going over the total evicts from the fullest shard, not the one
+	// written to.
+	tc.Set(5, 25, DefaultExpiration)
+	if got := tc.TotalCost(); got > 100 {
+		t.Error("Total cost is over the maximum:", got)
+	}
+	if len(evicted) != 1 || evicted[0] != 0 {
+		t.Error("Didn't evict the least-recently-used item of the fullest shard:", evicted)
+	}
+	if got := tc.TotalCost(); got != tc.Cost() {
+		t.Errorf("TotalCost is %d but the shards hold %d", got, tc.Cost())
+	}
+
+	tc.OnEvicted(nil)
+	tc.Delete(4)
+	tc.Flush()
+	if got := tc.TotalCost(); got != 0 {
+		t.Error("Total cost is not 0 after Flush:", got)
+	}
+}
+
+func TestMaxTotalCostPinnedShard(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 2,
+		WithCost[int, int](func(v int) int64 { return int64(v) }, 0),
+		WithMaxTotalCost[int, int](100),
+		WithHashFunc[int, int](func(k int) uint32 { return uint32(k) }))
+	var evicted []int
+	tc.OnEvicted(func(k, v int, reason EvictionReason) {
+		evicted = append(evicted, k)
+	})
+	// Shard 0 is the fullest, but holds only pinned items.
+	tc.Set(0, 30, DefaultExpiration)
+	tc.Set(2, 30, DefaultExpiration)
+	tc.Pin(0)
+	tc.Pin(2)
+	tc.Set(1, 20, DefaultExpiration)
+	tc.Set(3, 30, DefaultExpiration)
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Error("Didn't move on to the next fullest shard:", evicted)
+	}
+	if got := tc.TotalCost(); got > 100 {
+		t.Error("Total cost is over the maximum:", got)
+	}
+}
+
+func TestMaxTotalCostStandardCache(t *testing.T) {
+	tc := New[int, int](DefaultExpiration, 0,
+		WithCost[int, int](func(v int) int64 { return int64(v) }, 0),
+		WithMaxTotalCost[int, int](100))
+	if tc.policy != nil {
+		t.Error("WithMaxTotalCost gave the standard cache an eviction policy")
+	}
+}
+
+func TestMaxTotalCostConcurrent(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 8,
+		WithCost[int, int](func(v int) int64 { return 1 }, 0),
+		WithMaxTotalCost[int, int](50),
+		WithHashFunc[int, int](IntegerHash[int]))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				tc.Set(i*1000+j, j, DefaultExpiration)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := tc.TotalCost(); got > 50 || got != tc.Cost() {
+		t.Errorf("Total cost is %d and the shards hold %d, want both at most 50", got, tc.Cost())
+	}
+}
+
+func TestTotalCostReshardAndClone(t *testing.T) {
+	tc := NewSharded[int, int](DefaultExpiration, 0, 4,
+		WithCost[int, int](func(v int) int64 { return int64(v) }, 0),
+		WithHashFunc[int, int](IntegerHash[int]))
+	for i := 1; i <= 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.Reshard(3)
+	if got := tc.TotalCost(); got != 55 {
+		t.Error("Total cost is not 55 after Reshard:", got)
+	}
+	if got := tc.Clone().TotalCost(); got != 55 {
+		t.Error("Total cost of the clone is not 55:", got)
+	}
+}
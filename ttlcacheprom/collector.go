@@ -0,0 +1,86 @@
+// Package ttlcacheprom exports the statistics of a ttlcache cache as
+// Prometheus metrics. It lives in its own module so that users of ttlcache
+// who don't need it aren't forced to depend on the Prometheus client.
+package ttlcacheprom
+
+import (
+	"github.com/begmaroman/go-ttlcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsSource is implemented by *ttlcache.Cache and *ttlcache.ShardedCache.
+type StatsSource interface {
+	Stats() ttlcache.Stats
+	ItemCountIncludingExpired() int
+	Cost() int64
+}
+
+type collector struct {
+	c StatsSource
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	hitRatio   *prometheus.Desc
+	insertions *prometheus.Desc
+	evictions  *prometheus.Desc
+	items      *prometheus.Desc
+	cost       *prometheus.Desc
+}
+
+// NewCollector returns a prometheus.Collector that exposes the statistics of
+// c with metric names prefixed by name:
+//
+//	<name>_hits_total        counter
+//	<name>_misses_total      counter
+//	<name>_hit_ratio         gauge, hits / (hits + misses)
+//	<name>_insertions_total  counter
+//	<name>_evictions_total   counter, labelled by reason
+//	<name>_items             gauge, including expired items not yet cleaned up
+//	<name>_cost              gauge, see ttlcache.WithCost
+//
+// The metrics are read from c.Stats() on each scrape. Since the counters are
+// kept with atomics the scrape doesn't block cache operations, apart from
+// briefly taking the read lock to count the items with
+// ItemCountIncludingExpired, which takes constant time (for a sharded cache,
+// once per shard).
+func NewCollector(name string, c StatsSource) prometheus.Collector {
+	return &collector{
+		c:          c,
+		hits:       prometheus.NewDesc(name+"_hits_total", "Number of lookups that found an unexpired item.", nil, nil),
+		misses:     prometheus.NewDesc(name+"_misses_total", "Number of lookups that didn't find an unexpired item.", nil, nil),
+		hitRatio:   prometheus.NewDesc(name+"_hit_ratio", "Ratio of hits to lookups.", nil, nil),
+		insertions: prometheus.NewDesc(name+"_insertions_total", "Number of values stored in the cache.", nil, nil),
+		evictions:  prometheus.NewDesc(name+"_evictions_total", "Number of items removed from the cache.", []string{"reason"}, nil),
+		items:      prometheus.NewDesc(name+"_items", "Number of items in the cache.", nil, nil),
+		cost:       prometheus.NewDesc(name+"_cost", "Summed cost of the items in the cache.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.hitRatio
+	ch <- c.insertions
+	ch <- c.evictions
+	ch <- c.items
+	ch <- c.cost
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.c.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(st.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(st.Misses))
+	var ratio float64
+	if lookups := st.Hits + st.Misses; lookups > 0 {
+		ratio = float64(st.Hits) / float64(lookups)
+	}
+	ch <- prometheus.MustNewConstMetric(c.hitRatio, prometheus.GaugeValue, ratio)
+	ch <- prometheus.MustNewConstMetric(c.insertions, prometheus.CounterValue, float64(st.Insertions))
+	for r, n := range st.Evictions {
+		ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(n), r.String())
+	}
+	ch <- prometheus.MustNewConstMetric(c.items, prometheus.GaugeValue, float64(c.c.ItemCountIncludingExpired()))
+	ch <- prometheus.MustNewConstMetric(c.cost, prometheus.GaugeValue, float64(c.c.Cost()))
+}
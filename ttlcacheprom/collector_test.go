@@ -0,0 +1,58 @@
+package ttlcacheprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/begmaroman/go-ttlcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	tc := ttlcache.New[string, int](ttlcache.DefaultExpiration, 0)
+	tc.Set("a", 1, ttlcache.DefaultExpiration)
+	tc.Set("b", 2, ttlcache.DefaultExpiration)
+	tc.Get("a")
+	tc.Get("missing")
+	tc.Delete("b")
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewCollector("test_cache", tc)); err != nil {
+		t.Fatal("Couldn't register collector:", err)
+	}
+	expected := `
+# HELP test_cache_cost Summed cost of the items in the cache.
+# TYPE test_cache_cost gauge
+test_cache_cost 0
+# HELP test_cache_evictions_total Number of items removed from the cache.
+# TYPE test_cache_evictions_total counter
+test_cache_evictions_total{reason="deleted"} 1
+# HELP test_cache_hit_ratio Ratio of hits to lookups.
+# TYPE test_cache_hit_ratio gauge
+test_cache_hit_ratio 0.5
+# HELP test_cache_hits_total Number of lookups that found an unexpired item.
+# TYPE test_cache_hits_total counter
+test_cache_hits_total 1
+# HELP test_cache_insertions_total Number of values stored in the cache.
+# TYPE test_cache_insertions_total counter
+test_cache_insertions_total 2
+# HELP test_cache_items Number of items in the cache.
+# TYPE test_cache_items gauge
+test_cache_items 1
+# HELP test_cache_misses_total Number of lookups that didn't find an unexpired item.
+# TYPE test_cache_misses_total counter
+test_cache_misses_total 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorSharded(t *testing.T) {
+	tc := ttlcache.NewSharded[string, int](ttlcache.DefaultExpiration, 0, 4)
+	tc.Set("a", 1, ttlcache.DefaultExpiration)
+	if n := testutil.CollectAndCount(NewCollector("test_cache", tc), "test_cache_items"); n != 1 {
+		t.Errorf("Collected %d item metrics instead of 1", n)
+	}
+}
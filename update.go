@@ -0,0 +1,49 @@
+package ttlcache
+
+import (
+	"time"
+)
+
+// Update atomically replaces the value of item k with the result of f. f is
+// called under the cache's write lock with the current value of k and true, or
+// with the zero value of V and false if k is missing or has expired. If f
+// returns true, its result is stored with the duration d, interpreted as in
+// Set; if it returns false, k is deleted, calling the eviction callback with
+// ReasonDeleted if it was found. f must not call back into the cache.
+func (c *cache[K, V]) Update(k K, d time.Duration, f func(old V, found bool) (V, bool)) {
+	c.mu.Lock()
+	stored, found := c.get(k)
+	var old V
+	if found {
+		old = c.decoded(stored)
+	}
+	v, keep := f(old, found)
+	if !keep {
+		deleted := false
+		if found {
+			_, deleted = c.delete(k)
+		}
+		ef := c.onEvicted
+		c.mu.Unlock()
+		if deleted {
+			c.stats.evicted(ReasonDeleted, 1)
+			if ef != nil {
+				ef(k, stored, ReasonDeleted)
+			}
+		}
+		return
+	}
+	c.set(k, v, d)
+	evicted := c.evictOverflow()
+	ef := c.onEvicted
+	c.mu.Unlock()
+	notifyEvicted(ef, evicted, ReasonCapacity)
+}
+
+// Update atomically replaces the value of item k with the result of f, under
+// the lock of the shard holding k. See Cache.Update.
+func (sc *shardedCache[K, V]) Update(k K, d time.Duration, f func(old V, found bool) (V, bool)) {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	sc.bucket(k).Update(k, d, f)
+}
@@ -0,0 +1,73 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type counter struct {
+	Hits  int
+	Names []string
+}
+
+func TestUpdate(t *testing.T) {
+	tc := New[string, counter](DefaultExpiration, 0)
+	tc.Update("a", time.Hour, func(old counter, found bool) (counter, bool) {
+		if found {
+			t.Error("a was found before it was stored")
+		}
+		return counter{Hits: 1}, true
+	})
+	tc.Update("a", DefaultExpiration, func(old counter, found bool) (counter, bool) {
+		if !found || old.Hits != 1 {
+			t.Error("a was not passed to f:", old, found)
+		}
+		old.Hits++
+		old.Names = append(old.Names, "x")
+		return old, true
+	})
+	x, expiration, found := tc.GetWithExpiration("a")
+	if !found || x.Hits != 2 || len(x.Names) != 1 {
+		t.Error("a was not updated:", x)
+	}
+	if !expiration.IsZero() {
+		t.Error("a was not stored with the duration given to the last Update:", expiration)
+	}
+
+	var reason EvictionReason
+	tc.OnEvicted(func(k string, v counter, r EvictionReason) {
+		reason = r
+	})
+	tc.Update("a", DefaultExpiration, func(old counter, found bool) (counter, bool) {
+		return old, false
+	})
+	if tc.Has("a") || reason != ReasonDeleted {
+		t.Error("a was not deleted when f returned false")
+	}
+	tc.Update("missing", DefaultExpiration, func(old counter, found bool) (counter, bool) {
+		return old, false
+	})
+	if tc.Has("missing") {
+		t.Error("missing was stored when f returned false")
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	tc := NewSharded[string, counter](DefaultExpiration, 0, 4)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tc.Update("a", DefaultExpiration, func(old counter, found bool) (counter, bool) {
+				old.Hits++
+				return old, true
+			})
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("a"); x.Hits != 100 {
+		t.Error("a was not updated 100 times:", x.Hits)
+	}
+}
@@ -0,0 +1,66 @@
+package ttlcache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewChecked is like New, but returns an error instead of quietly falling back
+// to a default when given a parameter that is most likely a mistake: a
+// negative default expiration other than NoExpiration, a negative cleanup
+// interval, or a negative limit given to an option.
+func NewChecked[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, opts ...Option[K, V]) (*Cache[K, V], error) {
+	cfg := newConfig(opts)
+	if err := validate(defaultExpiration, cleanupInterval, cfg); err != nil {
+		return nil, err
+	}
+	return newCacheWithJanitor[K, V](defaultExpiration, cleanupInterval, map[K]Item[V]{}, cfg), nil
+}
+
+// NewShardedChecked is like NewSharded, but validates its parameters like
+// NewChecked, and also returns an error if the number of shards is less than
+// one.
+func NewShardedChecked[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, shards int, opts ...Option[K, V]) (*ShardedCache[K, V], error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("invalid number of shards %d: must be at least 1", shards)
+	}
+	cfg := newConfig(opts)
+	if err := validate(defaultExpiration, cleanupInterval, cfg); err != nil {
+		return nil, err
+	}
+	return newShardedCacheWithJanitor(newShardedCache[K, V](shards, defaultExpiration, cfg), cleanupInterval), nil
+}
+
+// validate checks the parameters shared by the checked constructors.
+func validate[K comparable, V any](de, ci time.Duration, cfg config[K, V]) error {
+	var errs []error
+	if de < 0 && de != NoExpiration {
+		errs = append(errs, fmt.Errorf("invalid default expiration %v: must be positive, DefaultExpiration or NoExpiration", de))
+	}
+	if ci < 0 {
+		errs = append(errs, fmt.Errorf("invalid cleanup interval %v: must not be negative", ci))
+	}
+	if cfg.maxItems < 0 {
+		errs = append(errs, fmt.Errorf("invalid maximum number of items %d: must not be negative", cfg.maxItems))
+	}
+	if cfg.maxCost < 0 {
+		errs = append(errs, fmt.Errorf("invalid maximum cost %d: must not be negative", cfg.maxCost))
+	}
+	if cfg.maxTotalCost < 0 {
+		errs = append(errs, fmt.Errorf("invalid maximum total cost %d: must not be negative", cfg.maxTotalCost))
+	}
+	if cfg.maxValueCost < 0 {
+		errs = append(errs, fmt.Errorf("invalid maximum value cost %d: must not be negative", cfg.maxValueCost))
+	}
+	if cfg.sampleSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid eviction sample size %d: must not be negative", cfg.sampleSize))
+	}
+	if cfg.jitter < 0 {
+		errs = append(errs, fmt.Errorf("invalid expiration jitter %v: must not be negative", cfg.jitter))
+	}
+	if cfg.cleanupBatch < 0 {
+		errs = append(errs, fmt.Errorf("invalid cleanup batch size %d: must not be negative", cfg.cleanupBatch))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewChecked(t *testing.T) {
+	tc, err := NewChecked[string, int](time.Minute, time.Minute, WithMaxItems[string, int](10))
+	if err != nil {
+		t.Fatal("Valid parameters were rejected:", err)
+	}
+	tc.Close()
+	for name, de := range map[string]time.Duration{"DefaultExpiration": DefaultExpiration, "NoExpiration": NoExpiration} {
+		if _, err := NewChecked[string, int](de, 0); err != nil {
+			t.Errorf("%s was rejected: %v", name, err)
+		}
+	}
+
+	for name, f := range map[string]func() error{
+		"default expiration": func() error {
+			_, err := NewChecked[string, int](-time.Minute, 0)
+			return err
+		},
+		"cleanup interval": func() error {
+			_, err := NewChecked[string, int](0, -time.Minute)
+			return err
+		},
+		"max items": func() error {
+			_, err := NewChecked[string, int](0, 0, WithMaxItems[string, int](-1))
+			return err
+		},
+		"max cost": func() error {
+			_, err := NewChecked[string, int](0, 0, WithCost[string, int](func(int) int64 { return 1 }, -1))
+			return err
+		},
+		"max value cost": func() error {
+			_, err := NewChecked[string, int](0, 0, WithMaxValueCost[string, int](-1))
+			return err
+		},
+		"eviction sample size": func() error {
+			_, err := NewChecked[string, int](0, 0, WithEvictSampleSize[string, int](-1))
+			return err
+		},
+		"jitter": func() error {
+			_, err := NewChecked[string, int](0, 0, WithExpirationJitter[string, int](-time.Second))
+			return err
+		},
+		"shards": func() error {
+			_, err := NewShardedChecked[string, int](0, 0, 0)
+			return err
+		},
+		"sharded cleanup interval": func() error {
+			_, err := NewShardedChecked[string, int](0, -1, 4)
+			return err
+		},
+	} {
+		if err := f(); err == nil {
+			t.Errorf("An invalid %s was accepted", name)
+		}
+	}
+}
+
+func TestNewShardedChecked(t *testing.T) {
+	tc, err := NewShardedChecked[string, int](DefaultExpiration, 0, 3)
+	if err != nil {
+		t.Fatal("Valid parameters were rejected:", err)
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Error("a was not found")
+	}
+}
+
+func TestNewShardedZeroShards(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		tc := NewSharded[string, int](DefaultExpiration, 0, n)
+		tc.Set("a", 1, DefaultExpiration)
+		if x, found := tc.Get("a"); !found || x != 1 {
+			t.Errorf("a was not found in a cache created with %d shards", n)
+		}
+		if n := tc.ShardCount(); n != 1 {
+			t.Errorf("Shard count is not 1: %d", n)
+		}
+	}
+}
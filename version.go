@@ -0,0 +1,63 @@
+package ttlcache
+
+import (
+	"time"
+)
+
+// GetWithVersion gets an item from the cache like Get, and also returns its
+// version (see Item.Version). The version changes whenever a new value is
+// stored at k, so it can be passed to CompareVersionAndSwap to update the
+// item only if nobody else has in the meantime, whether or not V is
+// comparable.
+func (c *cache[K, V]) GetWithVersion(k K) (V, uint64, bool) {
+	if c.lockedReads() {
+		item, found := c.getAndTrack(k)
+		if !found {
+			return item.Object, 0, false
+		}
+		return c.decoded(item.Object), item.Version, true
+	}
+	c.mu.RLock()
+	item, found := c.items[k]
+	if !found || (item.Expiration > 0 && c.now() > item.Expiration) {
+		c.mu.RUnlock()
+		c.stats.misses.Add(1)
+		if found && c.deleteOnGet {
+			c.deleteIfExpired(k)
+		}
+		var zero V
+		return zero, 0, false
+	}
+	if c.policy != nil {
+		c.policy.access(k)
+	}
+	c.mu.RUnlock()
+	c.stats.hits.Add(1)
+	return c.decoded(item.Object), item.Version, true
+}
+
+// CompareVersionAndSwap sets x, with the given duration, as the value of item
+// k only if k is in the cache, hasn't expired, and its version is still
+// version. It reports whether the swap happened. As with Replace, the old
+// value is passed to the eviction callback with ReasonReplaced.
+func (c *cache[K, V]) CompareVersionAndSwap(k K, version uint64, x V, d time.Duration) bool {
+	return c.swapIf(k, x, d, func(item Item[V]) bool {
+		return item.Version == version
+	})
+}
+
+// GetWithVersion gets an item from the cache along with its version. See
+// Cache.GetWithVersion.
+func (sc *shardedCache[K, V]) GetWithVersion(k K) (V, uint64, bool) {
+	sc.mu.RLock()
+	defer sc.unlock()
+	return sc.bucket(k).GetWithVersion(k)
+}
+
+// CompareVersionAndSwap sets x as the value of item k only if its version is
+// still version. See Cache.CompareVersionAndSwap.
+func (sc *shardedCache[K, V]) CompareVersionAndSwap(k K, version uint64, x V, d time.Duration) bool {
+	sc.mu.RLock()
+	defer sc.unlockAfterWrite()
+	return sc.bucket(k).CompareVersionAndSwap(k, version, x, d)
+}
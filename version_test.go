@@ -0,0 +1,99 @@
+package ttlcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetWithVersion(t *testing.T) {
+	tc := New[string, []int](DefaultExpiration, 0)
+	if _, _, found := tc.GetWithVersion("foo"); found {
+		t.Error("Found foo when it doesn't exist")
+	}
+	tc.Set("foo", []int{1}, DefaultExpiration)
+	_, v1, found := tc.GetWithVersion("foo")
+	if !found || v1 == 0 {
+		t.Error("foo wasn't given a version:", v1)
+	}
+
+	tc.Touch("foo", time.Hour)
+	tc.GetAndRefresh("foo", time.Hour)
+	if _, v, _ := tc.GetWithVersion("foo"); v != v1 {
+		t.Errorf("The version changed from %d to %d without a new value", v1, v)
+	}
+
+	tc.Replace("foo", []int{2}, DefaultExpiration)
+	_, v2, _ := tc.GetWithVersion("foo")
+	if v2 <= v1 {
+		t.Errorf("The version didn't increase on Replace: %d, then %d", v1, v2)
+	}
+	tc.Set("foo", []int{3}, DefaultExpiration)
+	if _, v3, _ := tc.GetWithVersion("foo"); v3 <= v2 {
+		t.Errorf("The version didn't increase on Set: %d, then %d", v2, v3)
+	}
+}
+
+func TestVersionIncrement(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("foo", 1, DefaultExpiration)
+	_, v1, _ := tc.GetWithVersion("foo")
+	Increment(tc, "foo", 1)
+	if _, v2, _ := tc.GetWithVersion("foo"); v2 <= v1 {
+		t.Errorf("The version didn't increase on Increment: %d, then %d", v1, v2)
+	}
+}
+
+func TestNewFromVersion(t *testing.T) {
+	tc := NewFrom[string, int](DefaultExpiration, 0, map[string]Item[int]{
+		"foo": {Object: 1, Version: 10},
+	})
+	tc.Set("bar", 2, DefaultExpiration)
+	if _, v, _ := tc.GetWithVersion("bar"); v <= 10 {
+		t.Error("A version lower than that of an existing item was assigned:", v)
+	}
+}
+
+func TestCompareVersionAndSwap(t *testing.T) {
+	tc := New[string, []int](DefaultExpiration, 0)
+	if tc.CompareVersionAndSwap("foo", 0, nil, DefaultExpiration) {
+		t.Error("Swapped foo when it doesn't exist")
+	}
+	tc.Set("foo", []int{1}, DefaultExpiration)
+	_, v, _ := tc.GetWithVersion("foo")
+	if tc.CompareVersionAndSwap("foo", v+1, []int{2}, DefaultExpiration) {
+		t.Error("Swapped foo with the wrong version")
+	}
+	if !tc.CompareVersionAndSwap("foo", v, []int{2}, DefaultExpiration) {
+		t.Error("Couldn't swap foo with the right version")
+	}
+	if tc.CompareVersionAndSwap("foo", v, []int{3}, DefaultExpiration) {
+		t.Error("Swapped foo twice with the same version")
+	}
+	if x, _ := tc.Get("foo"); len(x) != 1 || x[0] != 2 {
+		t.Error("foo is not [2]:", x)
+	}
+}
+
+func TestCompareVersionAndSwapConcurrent(t *testing.T) {
+	tc := NewSharded[string, []int](DefaultExpiration, 0, 4)
+	tc.Set("foo", nil, DefaultExpiration)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				x, v, _ := tc.GetWithVersion("foo")
+				nx := append(append([]int(nil), x...), i)
+				if tc.CompareVersionAndSwap("foo", v, nx, DefaultExpiration) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if x, _ := tc.Get("foo"); len(x) != 50 {
+		t.Errorf("foo has %d elements instead of 50", len(x))
+	}
+}
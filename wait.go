@@ -0,0 +1,65 @@
+package ttlcache
+
+import (
+	"context"
+	"time"
+)
+
+// WaitForEviction blocks until k is no longer in the cache, because it has
+// expired or been removed, and returns nil, or until ctx is done, and returns
+// ctx.Err(). It returns right away if k is missing already. Rather than
+// polling, it waits for the cache's eviction events (see Subscribe) and for
+// the expiration time of k, as measured by the cache's clock, so that with a
+// fake clock (see WithClock) it returns once the clock is advanced past it.
+// Storing a new value at k before it expires, e.g. with Set, doesn't end the
+// wait, and neither does replacing it with Replace; k must be found missing.
+func (c *cache[K, V]) WaitForEviction(ctx context.Context, k K) error {
+	return waitForEviction(ctx, k, clockOrReal(c.clock), c.Subscribe, c.Unsubscribe, c.TTL)
+}
+
+// WaitForEviction blocks until k is no longer in the cache, or until ctx is
+// done. See Cache.WaitForEviction.
+func (sc *shardedCache[K, V]) WaitForEviction(ctx context.Context, k K) error {
+	return waitForEviction(ctx, k, clockOrReal(sc.clock), sc.Subscribe, sc.Unsubscribe, sc.TTL)
+}
+
+func waitForEviction[K comparable, V any](ctx context.Context, k K, clock Clock, subscribe func() <-chan Event[K, V], unsubscribe func(<-chan Event[K, V]), ttl func(K) (time.Duration, bool)) error {
+	events := subscribe()
+	defer unsubscribe(events)
+	for {
+		d, found := ttl(k)
+		if !found {
+			return nil
+		}
+		if err := waitForChange(ctx, k, clock, events, d); err != nil {
+			return err
+		}
+	}
+}
+
+// waitForChange waits, for at most d unless it is NoExpiration, until an
+// event is published that may have removed k, and returns nil, or until ctx
+// is done.
+func waitForChange[K comparable, V any](ctx context.Context, k K, clock Clock, events <-chan Event[K, V], d time.Duration) error {
+	var tick <-chan time.Time
+	if d != NoExpiration {
+		// Items only expire once their expiration time has passed.
+		t := clock.NewTicker(d + 1)
+		defer t.Stop()
+		tick = t.C()
+	}
+	for {
+		select {
+		case e := <-events:
+			// An event for k may have been dropped if the buffer was
+			// full.
+			if e.Key == k || len(events) == cap(events) {
+				return nil
+			}
+		case <-tick:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
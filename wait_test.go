@@ -0,0 +1,81 @@
+package ttlcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForEviction(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	ctx := context.Background()
+	if err := tc.WaitForEviction(ctx, "missing"); err != nil {
+		t.Error("Waiting for a missing key failed:", err)
+	}
+
+	tc.Set("a", 1, NoExpiration)
+	done := make(chan error, 1)
+	go func() {
+		done <- tc.WaitForEviction(ctx, "a")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	tc.Set("b", 2, NoExpiration)
+	tc.Delete("b")
+	select {
+	case err := <-done:
+		t.Fatal("The wait ended while a was still in the cache:", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	tc.Delete("a")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("Waiting for a failed:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The wait didn't end when a was deleted")
+	}
+
+	tc.Set("c", 3, NoExpiration)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := tc.WaitForEviction(ctx, "c"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Waiting for c didn't time out:", err)
+	}
+}
+
+func TestWaitForEvictionWithFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	sc := NewSharded[string, int](time.Minute, 0, 4, WithClock[string, int](clock))
+	sc.Set("a", 1, DefaultExpiration)
+	done := make(chan error, 1)
+	go func() {
+		done <- sc.WaitForEviction(context.Background(), "a")
+	}()
+	// Wait for the ticker to be created before advancing the clock.
+	for {
+		clock.mu.Lock()
+		n := len(clock.tickers)
+		clock.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(30 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatal("The wait ended before a expired:", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Minute)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("Waiting for a failed:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The wait didn't end when a expired")
+	}
+}
@@ -0,0 +1,89 @@
+package ttlcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Warm loads the given keys into the cache with loader, using up to
+// concurrency goroutines (at least one). loader returns the value to store for
+// a key and the duration to store it with, interpreted as in Set. Keys that
+// are already in the cache, and haven't expired, are skipped, and loads of
+// keys that GetOrLoad is loading at the same time are shared with it.
+//
+// Warm waits for all loads to finish. If any of them fail, the failed keys are
+// not stored, and the errors are returned joined together with errors.Join,
+// each annotated with its key.
+func (c *cache[K, V]) Warm(keys []K, concurrency int, loader func(K) (V, time.Duration, error)) error {
+	return warm(keys, concurrency, func(k K) error {
+		return warmKey[K, V](c, &c.loads, k, loader)
+	})
+}
+
+// warmKey loads k into t with loader for Warm, unless it is present already,
+// deduplicating the load with g.
+func warmKey[K comparable, V any](t loadTarget[K, V], g *loadGroup[K, V], k K, loader func(K) (V, time.Duration, error)) error {
+	if t.Has(k) {
+		return nil
+	}
+	_, err := g.do(k, func() (V, error) {
+		// A GetOrLoad may join this load, so return the value that another
+		// load stored in the meantime rather than the zero value.
+		if v, found := t.peek(k); found {
+			return v, nil
+		}
+		v, d, err := loader(k)
+		if err != nil {
+			return v, err
+		}
+		t.Set(k, v, d)
+		return v, nil
+	})
+	return err
+}
+
+// Warm loads the given keys into the cache with loader, using up to
+// concurrency goroutines. See Cache.Warm.
+func (sc *shardedCache[K, V]) Warm(keys []K, concurrency int, loader func(K) (V, time.Duration, error)) error {
+	return warm(keys, concurrency, func(k K) error {
+		return warmKey[K, V](sc, &sc.loads, k, loader)
+	})
+}
+
+// warm calls load for each of keys from up to concurrency goroutines, and
+// joins the errors it returns.
+func warm[K comparable](keys []K, concurrency int, load func(K) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(keys) {
+		concurrency = len(keys)
+	}
+	work := make(chan K)
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for k := range work {
+				if err := load(k); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("loading %v: %w", k, err))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, k := range keys {
+		work <- k
+	}
+	close(work)
+	wg.Wait()
+	return errors.Join(errs...)
+}
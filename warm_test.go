@@ -0,0 +1,75 @@
+package ttlcache
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	tc := New[int, string](DefaultExpiration, 0)
+	tc.Set(0, "existing", DefaultExpiration)
+	var calls, running, maxRunning int32
+	errOdd := errors.New("odd")
+	keys := make([]int, 20)
+	for i := range keys {
+		keys[i] = i
+	}
+	err := tc.Warm(keys, 4, func(k int) (string, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		if k%2 == 1 {
+			return "", 0, errOdd
+		}
+		return strconv.Itoa(k), DefaultExpiration, nil
+	})
+
+	if n := atomic.LoadInt32(&calls); n != 19 {
+		t.Errorf("loader was called %d times instead of 19", n)
+	}
+	if n := atomic.LoadInt32(&maxRunning); n > 4 {
+		t.Errorf("%d loads ran at once, more than the concurrency of 4", n)
+	}
+	if !errors.Is(err, errOdd) {
+		t.Error("Warm didn't return the loader's errors:", err)
+	}
+	if x, _ := tc.Get(0); x != "existing" {
+		t.Error("An existing item was reloaded:", x)
+	}
+	for i := 2; i < 20; i += 2 {
+		if x, found := tc.Get(i); !found || x != strconv.Itoa(i) {
+			t.Errorf("%d wasn't warmed: %q", i, x)
+		}
+	}
+	if n := tc.ItemCount(); n != 10 {
+		t.Errorf("Item count is not 10: %d", n)
+	}
+}
+
+func TestShardedWarm(t *testing.T) {
+	tc := NewSharded[string, int](DefaultExpiration, 0, 4)
+	err := tc.Warm(shardedKeys, 0, func(k string) (int, time.Duration, error) {
+		return len(k), NoExpiration, nil
+	})
+	if err != nil {
+		t.Error("Warm returned an error:", err)
+	}
+	for _, k := range shardedKeys {
+		if x, found := tc.Get(k); !found || x != len(k) {
+			t.Errorf("%s wasn't warmed: %d", k, x)
+		}
+	}
+	if err := tc.Warm(nil, 4, nil); err != nil {
+		t.Error("Warming no keys returned an error:", err)
+	}
+}